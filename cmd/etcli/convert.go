@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	easyhttp "github.com/wwqdrh/easytest/httptest"
+
+	"github.com/wwqdrh/logger"
+)
+
+// etcli convert [flags] file
+func convertCmd(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := fs.String("from", "basic", "输入格式: basic、curl")
+	to := fs.String("to", "basic", "输出格式: basic、postman、curl")
+	out := fs.String("out", "", "输出文件, 默认输出到标准输出")
+	name := fs.String("name", "", "collection名称, 默认为输入文件名")
+	envFile := fs.String("env", "", "转换为curl时使用的环境变量, json对象或者postman环境文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("需要指定一个输入文件")
+	}
+	input := fs.Arg(0)
+
+	var spec *easyhttp.BasicSpecInfo
+	var err error
+	switch *from {
+	case "basic":
+		spec, err = easyhttp.NewBasicSpecInfoFromFile(input, nil)
+	case "curl":
+		spec, err = curlSpec(input)
+	default:
+		return fmt.Errorf("不支持的输入格式%s", *from)
+	}
+	if err != nil {
+		return err
+	}
+
+	var res interface{}
+	switch *to {
+	case "basic":
+		res = spec
+	case "postman":
+		collection := spec.ToPostman()
+		for _, warning := range collection.Warnings() {
+			logger.DefaultLogger.Warn(warning)
+		}
+		collection.Info.Name = *name
+		if collection.Info.Name == "" {
+			collection.Info.Name = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		}
+		res = collection
+	case "curl":
+		// 没有指定env时原样保留{{ }}变量, 执行前替换为实际的值
+		render := func(s string) string { return s }
+		if *envFile != "" {
+			env, err := easyhttp.NewEnvFromFile(*envFile)
+			if err != nil {
+				return err
+			}
+			ctx := easyhttp.NewHttpContext()
+			for key, value := range env {
+				ctx.Setenv(key, value)
+			}
+			render = ctx.Render
+		}
+		commands := []string{}
+		for _, item := range *spec {
+			// 钩子不是独立的请求, 按照顺序输出会在item之前执行after_all
+			if item.Hook != "" {
+				logger.DefaultLogger.Warn(fmt.Sprintf("%s 钩子(%s)无法转换为curl, 已忽略", item.Name, item.Hook))
+				continue
+			}
+			commands = append(commands, item.Curl(render))
+		}
+		return writeText(*out, strings.Join(commands, "\n\n")+"\n")
+	default:
+		return fmt.Errorf("不支持的输出格式%s", *to)
+	}
+
+	return writeOutput(*out, res)
+}
+
+// 文件中的一个或者多个curl命令, -代表标准输入
+func curlSpec(input string) (*easyhttp.BasicSpecInfo, error) {
+	var data []byte
+	var err error
+	if input == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	items, err := easyhttp.ParseCurl(string(data))
+	if err != nil {
+		return nil, err
+	}
+	spec := easyhttp.BasicSpecInfo(items)
+	return &spec, nil
+}
+
+// 格式化为json, out为空时输出到标准输出
+func writeOutput(out string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return writeText(out, string(data))
+}
+
+func writeText(out string, s string) error {
+	if out == "" {
+		_, err := os.Stdout.WriteString(s)
+		return err
+	}
+	return ioutil.WriteFile(out, []byte(s), 0644)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	easyhttp "github.com/wwqdrh/easytest/httptest"
+
+	"github.com/wwqdrh/logger"
+)
+
+// etcli import openapi|har [flags] file
+func importCmd(args []string) error {
+	if len(args) == 0 {
+		return errors.New("需要指定导入的格式(openapi、har), 例如: etcli import openapi spec.yaml")
+	}
+	format := args[0]
+
+	fs := flag.NewFlagSet("import "+format, flag.ExitOnError)
+	out := fs.String("out", "", "输出文件, 默认输出到标准输出")
+	baseUrl := fs.String("base-url", "", "openapi: 请求地址前缀, 默认使用文档中的servers")
+	hosts := fs.String("host", "", "har: 只保留这些host的请求, 多个使用逗号分隔, 支持*.example.com")
+	methods := fs.String("method", "", "har: 只保留这些method的请求, 多个使用逗号分隔")
+	keepAssets := fs.Bool("keep-assets", false, "har: 保留静态资源")
+	expectBody := fs.Bool("expect-body", false, "har: 为json响应的顶层字段生成断言")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("需要指定一个输入文件")
+	}
+
+	switch format {
+	case "openapi":
+		doc, err := easyhttp.NewOpenAPIFromFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		spec := doc.ToBasic(*baseUrl)
+		for _, item := range *spec {
+			for _, field := range item.Form {
+				if strings.HasPrefix(field.File, easyhttp.OpenAPIFilePlaceholder) {
+					logger.DefaultLogger.Warn(fmt.Sprintf("%s: 文件字段%s使用了占位路径%s, 执行前需要替换为实际的文件", item.Name, field.Key, field.File))
+				}
+			}
+		}
+		return writeOutput(*out, spec)
+	case "har":
+		har, err := easyhttp.NewHARFromFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return writeOutput(*out, har.ToBasic(&easyhttp.HAROption{
+			Hosts:      splitList(*hosts),
+			Methods:    splitList(*methods),
+			KeepAssets: *keepAssets,
+			ExpectBody: *expectBody,
+		}))
+	}
+	return fmt.Errorf("不支持导入的格式%s", format)
+}
+
+// 逗号分隔的列表, 忽略空值
+func splitList(s string) []string {
+	res := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
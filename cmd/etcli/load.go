@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	easyhttp "github.com/wwqdrh/easytest/httptest"
+)
+
+// etcli load [flags] spec
+func loadCmd(args []string) error {
+	fs := flag.NewFlagSet("load", flag.ExitOnError)
+	concurrency := fs.Int("c", 10, "并发的worker数量")
+	requests := fs.Int("n", 0, "总的请求数")
+	duration := fs.Duration("z", 0, "持续时间, 例如30s, 与-n同时指定时先达到的为准")
+	rps := fs.Float64("rps", 0, "每秒发出的请求数, 默认不限制")
+	ramp := fs.Duration("ramp", 0, "在这段时间内从0线性增加到-rps")
+	items := fs.String("item", "", "只执行这些名称的item, 逗号分隔, 钩子仍然执行")
+	tagExpr := fs.String("tags", "", "标签表达式, 只执行满足的item")
+	envFile := fs.String("env", "", "json对象文件, 每个worker的初始环境变量")
+	format := fs.String("format", "text", "输出格式: text、json")
+	out := fs.String("out", "", "输出文件, 默认输出到标准输出")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("需要指定一个spec文件")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("不支持的输出格式%s", *format)
+	}
+
+	option := &easyhttp.LoadOption{
+		Concurrency: *concurrency,
+		Requests:    *requests,
+		Duration:    *duration,
+		RPS:         *rps,
+		RampUp:      *ramp,
+	}
+	if *envFile != "" {
+		data, err := ioutil.ReadFile(*envFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &option.Env); err != nil {
+			return fmt.Errorf("%s: %w", *envFile, err)
+		}
+	}
+
+	specInfo, err := easyhttp.NewBasicParserSpecInfoFromFile(fs.Arg(0), nil)
+	if err != nil {
+		return err
+	}
+	report, err := specInfo.Runner().Only(splitList(*items)...).FilterTags(*tagExpr).Load(option)
+	if report != nil {
+		var writeErr error
+		if *format == "json" {
+			writeErr = writeOutput(*out, report)
+		} else {
+			writeErr = writeText(*out, report.String())
+		}
+		if err == nil {
+			err = writeErr
+		}
+	}
+	return err
+}
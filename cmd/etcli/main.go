@@ -2,35 +2,61 @@ package main
 
 import (
 	_ "embed"
-	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"os"
 	"strings"
-	"testing"
+	"time"
 
 	easyhttp "github.com/wwqdrh/easytest/httptest"
 
-	"net/http/httptest"
-
 	"github.com/wwqdrh/logger"
 )
 
 var (
-	jsonfile = flag.String("json", "api.json", "用于http检查的json文件")
+	jsonfile = flag.String("json", "api.json", "用于http检查的spec文件, 支持json、yaml以及.http、.rest文件")
 	check    = flag.Bool("check", false, "检查当前版本功能是否正常")
+
+	postmanfile = flag.String("postman", "", "postman collection(v2.1)文件, 指定时执行该文件")
+	envfile     = flag.String("env", "", "postman环境文件")
+
+	openapifile = flag.String("openapi", "", "openapi3文档, 用于契约校验以及统计覆盖率")
+	contract    = flag.Bool("contract", true, "指定openapi时根据文档校验每次请求与响应, -contract=false时只用于统计覆盖率")
+	coverage    = flag.String("coverage", "", "执行结束后输出接口覆盖率(需要指定openapi): text、json")
+	coverageOut = flag.String("coverage-out", "", "覆盖率报告的输出文件, 默认输出到标准输出")
+
+	only = flag.String("only", "", "只执行这些名称的item, 逗号分隔")
+	skip = flag.String("skip", "", "跳过这些名称的item, 逗号分隔")
+	tags = flag.String("tags", "", "标签表达式, 只执行满足的item, 例如\"smoke && !slow\"")
+
+	updateSnapshots = flag.Bool("update-snapshots", false, "使用当前响应更新设置了snapshot的item的快照")
+	report          = flag.String("report", "", "执行结束后将每次请求的结果(状态码、耗时、失败原因等)以json写入该文件")
+
+	verbose     = flag.Bool("v", false, "输出每次请求的请求行、状态码、环境变量的变化以及expect的结果")
+	veryVerbose = flag.Bool("vv", false, "在-v的基础上输出请求头、响应头以及body")
+	traceFormat = flag.String("trace-format", "text", "-v、-vv的输出格式: text、json(每行一个json)")
+	traceOut    = flag.String("trace-out", "", "-v、-vv的输出文件, 默认输出到标准错误")
 )
 
 var (
 	checkUrl string
+
+	openapiDoc *easyhttp.OpenAPI
 )
 
 //go:embed api.json
 var testapi []byte
 
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 	if *jsonfile == "" {
 		flag.Usage()
@@ -39,27 +65,45 @@ func main() {
 
 	if *check {
 		checkRun()
+	} else if *postmanfile != "" {
+		postmanRun()
 	} else {
 		basicRun()
 	}
 }
 
-func checkRun() {
-	// mock 实现
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.Contains(r.URL.Path, "/api/user/userinfo") {
-			if !strings.Contains(r.Header.Get("Authorization"), "123456") {
-				w.WriteHeader(500)
-				return
-			}
-		}
-		body, _ := json.Marshal(map[string]interface{}{
-			"msg":         "ok",
-			"accessToken": "123456",
-		})
-		_, err := w.Write(body)
+// 子命令
+func runCommand(name string, args []string) {
+	var err error
+	switch name {
+	case "convert":
+		err = convertCmd(args)
+	case "import":
+		err = importCmd(args)
+	case "load":
+		err = loadCmd(args)
+	case "mock":
+		err = mockCmd(args)
+	case "record":
+		err = recordCmd(args)
+	case "replay":
+		err = replayCmd(args)
+	default:
+		err = fmt.Errorf("未知的命令%s", name)
+	}
+	if err != nil {
 		logger.DefaultLogger.Error(err.Error())
-	}))
+		os.Exit(1)
+	}
+}
+
+// 根据api.json中的example启动mock server
+func checkRun() {
+	specInfo, err := easyhttp.NewBasicSpecInfo(testapi, nil)
+	if err != nil {
+		fatal(err)
+	}
+	ts := easyhttp.NewMockServer(specInfo)
 	defer ts.Close()
 
 	checkUrl = ts.URL
@@ -86,28 +130,268 @@ func getJsonStr() ([]byte, error) {
 }
 
 func basicRun() {
-	jsonData, err := getJsonStr()
-	if err != nil {
-		panic(err)
-	}
-
 	var specInfo *easyhttp.BasicParserSpecInfo
+	var err error
 	if checkUrl != "" {
+		jsonData, err := getJsonStr()
+		if err != nil {
+			panic(err)
+		}
 		specInfo, err = easyhttp.NewBasicParserSpecInfo(jsonData, func(item *easyhttp.BasicItem) {
 			item.Url = checkUrl + getPath(item.Url)
 		})
+		if err != nil {
+			fatal(err)
+		}
 	} else {
-		specInfo, err = easyhttp.NewBasicParserSpecInfo(jsonData, nil)
+		// 从文件加载, 上传文件等相对路径基于spec文件所在目录
+		specInfo, err = easyhttp.NewBasicParserSpecInfoFromFile(*jsonfile, nil)
 	}
 
 	if err != nil {
+		fatal(err)
+	}
+
+	ctx, err := newContext()
+	if err != nil {
+		fatal(err)
+	}
+	runner := specInfo.Runner().Only(splitList(*only)...).Skip(splitList(*skip)...).FilterTags(*tags)
+	ok := runTest(func(t easyhttp.TestingT) {
+		if err := runner.Run(t, ctx); err != nil {
+			t.Errorf("%s", err.Error())
+		}
+	})
+	reportViolations(ctx)
+	// 存在失败时以非0退出, 便于在CI中使用
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func postmanRun() {
+	specInfo, err := easyhttp.NewPostmanSpecInfoFromFile(*postmanfile, nil)
+	if err != nil {
+		fatal(err)
+	}
+
+	if *envfile != "" {
+		env, err := easyhttp.NewPostmanEnvironmentFromFile(*envfile)
+		if err != nil {
+			fatal(err)
+		}
+		specInfo.WithEnvironment(env)
+	}
+	specInfo.Only(splitList(*only)...).Skip(splitList(*skip)...).FilterTags(*tags)
+	for _, warning := range specInfo.Warnings() {
+		logger.DefaultLogger.Warn(warning)
+	}
+
+	ctx, err := newContext()
+	if err != nil {
+		fatal(err)
+	}
+	ok := runTest(func(t easyhttp.TestingT) {
+		if err := specInfo.StartHandleWithContext(t, ctx); err != nil {
+			t.Errorf("%s", err.Error())
+		}
+	})
+	reportViolations(ctx)
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// 加载spec、环境等失败时以非0退出
+func fatal(err error) {
+	logger.DefaultLogger.Error(err.Error())
+	os.Exit(1)
+}
+
+// 测试之外执行spec, 断言失败时输出错误
+type cliT struct {
+	failed bool
+}
+
+var errFailNow = errors.New("fail now")
+
+func (t *cliT) Errorf(format string, args ...interface{}) {
+	t.failed = true
+	logger.DefaultLogger.Error(fmt.Sprintf(format, args...))
+}
+
+func (t *cliT) FailNow() {
+	t.failed = true
+	panic(errFailNow)
+}
+
+func (t *cliT) Log(args ...interface{}) {
+	logger.DefaultLogger.Info(fmt.Sprint(args...))
+}
+
+// 返回是否全部通过, FailNow以及DoParser失败时停止执行
+func runTest(f func(t easyhttp.TestingT)) (ok bool) {
+	t := &cliT{}
+	defer func() {
+		if r := recover(); r != nil && r != errFailNow {
+			msg, isFailure := r.(string)
+			if !isFailure {
+				panic(r)
+			}
+			t.Errorf("%s", msg)
+		}
+		ok = !t.failed
+	}()
+	f(t)
+	return
+}
+
+// 指定openapi文档时加载用于统计覆盖率, 同时指定contract时开启契约校验, 指定update-snapshots时更新快照, 指定-v、-vv时追踪请求
+func newContext() (*easyhttp.HttpContext, error) {
+	ctx := easyhttp.NewHttpContext()
+	if *updateSnapshots {
+		ctx.WithUpdateSnapshots()
+	}
+	if *verbose || *veryVerbose {
+		tracer, err := newTracer()
+		if err != nil {
+			return nil, err
+		}
+		ctx.WithTrace(tracer)
+	}
+	if *openapifile == "" {
+		return ctx, nil
+	}
+	doc, err := easyhttp.NewOpenAPIFromFile(*openapifile)
+	if err != nil {
+		return nil, err
+	}
+	openapiDoc = doc
+	if !*contract {
+		return ctx, nil
+	}
+	return ctx.WithContract(doc), nil
+}
+
+func newTracer() (*easyhttp.Tracer, error) {
+	var w io.Writer = os.Stderr
+	if *traceOut != "" {
+		// 进程结束时关闭
+		file, err := os.Create(*traceOut)
+		if err != nil {
+			return nil, err
+		}
+		w = file
+	}
+	tracer := easyhttp.NewTracer(w)
+	tracer.Verbose = *veryVerbose
+	switch *traceFormat {
+	case "text":
+	case "json":
+		tracer.JSON = true
+	default:
+		return nil, fmt.Errorf("不支持的trace-format: %s", *traceFormat)
+	}
+	return tracer, nil
+}
+
+func reportViolations(ctx *easyhttp.HttpContext) {
+	reportFailures(ctx)
+	reportTiming(ctx)
+	if *report != "" {
+		if err := writeOutput(*report, ctx.Results()); err != nil {
+			logger.DefaultLogger.Error(err.Error())
+		}
+	}
+	if err := reportCoverage(ctx); err != nil {
 		logger.DefaultLogger.Error(err.Error())
+	}
+
+	violations := ctx.Violations()
+	for _, item := range violations {
+		logger.DefaultLogger.Error(item)
+	}
+	if len(violations) > 0 {
+		logger.DefaultLogger.Error(fmt.Sprintf("%d个请求不满足openapi文档", len(violations)))
+		os.Exit(1)
+	}
+}
+
+// 失败的步骤输出为curl命令, 便于复现或者附在bug报告中
+func reportFailures(ctx *easyhttp.HttpContext) {
+	failed, skipped := 0, 0
+	for _, result := range ctx.Results() {
+		switch {
+		case result.Skipped:
+			skipped++
+			logger.DefaultLogger.Warn(fmt.Sprintf("%s 跳过: %s", result.Title, result.SkipReason))
+		case result.Failed:
+			failed++
+			logger.DefaultLogger.Error(fmt.Sprintf("%s 失败, 复现命令:\n%s", result.Title, result.Curl()))
+		}
+	}
+	logger.DefaultLogger.Info(fmt.Sprintf("共%d个请求, 通过%d个, 失败%d个, 跳过%d个", len(ctx.Results()), len(ctx.Results())-failed-skipped, failed, skipped))
+
+	// 存在标签时按照标签分组输出
+	summaries := easyhttp.SummarizeByTag(ctx.Results())
+	if len(summaries) == 0 || len(summaries) == 1 && summaries[0].Tag == "" {
 		return
 	}
+	for _, summary := range summaries {
+		tag := summary.Tag
+		if tag == "" {
+			tag = "(无标签)"
+		}
+		logger.DefaultLogger.Info(fmt.Sprintf("%s: 共%d个, 通过%d个, 失败%d个, 跳过%d个", tag, summary.Total, summary.Passed, summary.Failed, summary.Skipped))
+	}
+}
 
-	if err := specInfo.StartHandle(&testing.T{}); err != nil {
-		logger.DefaultLogger.Error(err.Error())
+// 输出平均耗时以及最慢的请求
+func reportTiming(ctx *easyhttp.HttpContext) {
+	var total time.Duration
+	var slowest *easyhttp.StepResult
+	count := 0
+	for _, result := range ctx.Results() {
+		if result.Timing == nil {
+			continue
+		}
+		count++
+		total += result.Timing.Total
+		if slowest == nil || result.Timing.Total > slowest.Timing.Total {
+			slowest = result
+		}
+	}
+	if count == 0 {
+		return
+	}
+	logger.DefaultLogger.Info(fmt.Sprintf("平均耗时%.1fms, 最慢: %s %.1fms(ttfb %.1fms)",
+		ms(total/time.Duration(count)), slowest.Title, ms(slowest.Timing.Total), ms(slowest.Timing.TTFB)))
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func reportCoverage(ctx *easyhttp.HttpContext) error {
+	if *coverage == "" {
+		return nil
+	}
+	if openapiDoc == nil {
+		return errors.New("统计覆盖率需要指定openapi文档")
+	}
+
+	report := openapiDoc.Coverage(ctx.Results())
+	switch *coverage {
+	case "json":
+		return writeOutput(*coverageOut, report)
+	case "text":
+		if *coverageOut == "" {
+			fmt.Print(report.String())
+			return nil
+		}
+		return ioutil.WriteFile(*coverageOut, []byte(report.String()), 0644)
 	}
+	return fmt.Errorf("不支持的覆盖率格式%s", *coverage)
 }
 
 func getPath(urlstr string) string {
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+
+	easyhttp "github.com/wwqdrh/easytest/httptest"
+	"github.com/wwqdrh/logger"
+)
+
+// etcli mock [flags] spec, 参数也可以写在文件后面
+func mockCmd(args []string) error {
+	fs := flag.NewFlagSet("mock", flag.ExitOnError)
+	host := fs.String("host", "127.0.0.1", "监听的地址")
+	port := fs.Int("port", 8000, "监听的端口")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return errors.New("需要指定一个spec文件")
+	}
+
+	specInfo, err := easyhttp.NewBasicSpecInfoFromFile(files[0], nil)
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("%s:%d", *host, *port)
+	logger.DefaultLogger.Info(fmt.Sprintf("mock server: http://%s", addr))
+	return http.ListenAndServe(addr, easyhttp.NewMockHandler(specInfo))
+}
+
+// 解析写在位置参数前后的flag, 返回位置参数
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	res := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return res, nil
+		}
+		res = append(res, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+
+	easyhttp "github.com/wwqdrh/easytest/httptest"
+	"github.com/wwqdrh/logger"
+)
+
+// etcli record --target http://svc --listen :9000
+func recordCmd(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	target := fs.String("target", "", "目标服务的地址, 例如http://127.0.0.1:8000")
+	listen := fs.String("listen", ":9000", "代理监听的地址")
+	out := fs.String("out", "recorded.json", "生成的spec文件")
+	fixtures := fs.String("fixtures", "fixtures.json", "回放使用的fixture文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target == "" {
+		fs.Usage()
+		return errors.New("需要指定target")
+	}
+
+	recorder, err := easyhttp.NewRecorder(*target)
+	if err != nil {
+		return err
+	}
+	// 每次请求之后重新写入, 中断时不丢失已经录制的内容
+	var mu sync.Mutex
+	recorder.OnRecord(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := writeOutput(*out, recorder.Spec()); err != nil {
+			logger.DefaultLogger.Error(err.Error())
+		}
+		if err := writeOutput(*fixtures, recorder.Fixtures()); err != nil {
+			logger.DefaultLogger.Error(err.Error())
+		}
+	})
+	logger.DefaultLogger.Info(fmt.Sprintf("录制%s, 代理地址: %s, spec: %s, fixtures: %s", *target, *listen, *out, *fixtures))
+	return http.ListenAndServe(*listen, recorder)
+}
+
+// etcli replay [--listen :9000] fixtures.json
+func replayCmd(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	listen := fs.String("listen", ":9000", "监听的地址")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		return errors.New("需要指定一个fixture文件")
+	}
+
+	fixtures, err := easyhttp.NewFixturesFromFile(files[0])
+	if err != nil {
+		return err
+	}
+	logger.DefaultLogger.Info(fmt.Sprintf("回放%d个请求, 地址: %s", len(fixtures), *listen))
+	return http.ListenAndServe(*listen, easyhttp.NewReplayHandler(fixtures))
+}
@@ -0,0 +1,137 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+////////////////////
+// 请求体的构造方式
+// 1、raw, 原样发送body字符串(默认)
+// 2、urlencoded, 表单键值对
+// 3、multipart, 表单键值对以及文件上传
+// 4、binary, 读取文件内容原样发送
+// 5、base64, body为base64编码的数据, 解码后发送
+////////////////////
+
+const (
+	BodyModeRaw        = "raw"
+	BodyModeUrlencoded = "urlencoded"
+	BodyModeMultipart  = "multipart"
+	BodyModeBinary     = "binary"
+	BodyModeBase64     = "base64"
+)
+
+// 表单中的一个字段, File不为空时表示上传文件(仅multipart)
+type FormField struct {
+	Key         string `json:"key"`
+	Value       string `json:"value,omitempty"`
+	File        string `json:"file,omitempty"`
+	ContentType string `json:"content-type,omitempty"`
+}
+
+func NewUrlencodedBody(fields []*FormField) (io.Reader, string) {
+	values := url.Values{}
+	for _, item := range fields {
+		values.Add(item.Key, item.Value)
+	}
+	return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded"
+}
+
+// dir为文件相对路径的基准目录
+func NewMultipartBody(dir string, fields []*FormField) (io.Reader, string, error) {
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+
+	for _, item := range fields {
+		if item.File == "" {
+			if item.ContentType == "" {
+				if err := writer.WriteField(item.Key, item.Value); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(item.Key)))
+			header.Set("Content-Type", item.ContentType)
+			part, err := writer.CreatePart(header)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write([]byte(item.Value)); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		data, err := ioutil.ReadFile(resolvePath(dir, item.File))
+		if err != nil {
+			return nil, "", err
+		}
+		contentType := item.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(item.File))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(item.Key), escapeQuotes(filepath.Base(item.File))))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf, writer.FormDataContentType(), nil
+}
+
+func NewBinaryBody(dir string, file string) (io.Reader, string, error) {
+	data, err := ioutil.ReadFile(resolvePath(dir, file))
+	if err != nil {
+		return nil, "", err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(file))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return bytes.NewReader(data), contentType, nil
+}
+
+func NewBase64Body(data string) (io.Reader, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(raw), nil
+}
+
+// 相对路径基于dir解析
+func resolvePath(dir, file string) string {
+	if filepath.IsAbs(file) || dir == "" {
+		return file
+	}
+	return filepath.Join(dir, file)
+}
+
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
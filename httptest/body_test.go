@@ -0,0 +1,62 @@
+package httptest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPBodyMode(t *testing.T) {
+	received := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/user/avatar":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			file, header, err := r.FormFile("avatar")
+			if err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			data, _ := ioutil.ReadAll(file)
+			received["uid"] = r.FormValue("uid")
+			received["avatar"] = header.Filename + ":" + header.Header.Get("Content-Type") + ":" + string(data)
+		case "/api/user/profile":
+			received["profile-content-type"] = r.Header.Get("Content-Type")
+			received["name"] = r.PostFormValue("name")
+		case "/api/user/raw":
+			data, _ := ioutil.ReadAll(r.Body)
+			received["raw"] = r.Header.Get("Content-Type") + ":" + string(data)
+		case "/api/user/base64":
+			data, _ := ioutil.ReadAll(r.Body)
+			received["base64"] = r.Header.Get("Content-Type") + ":" + string(data)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/upload/upload_collection.json", func(item *BasicItem) {
+		item.Url = ts.URL + getPath(item.Url)
+	})
+	require.Nil(t, err)
+	require.Nil(t, specInfo.StartHandle(t))
+
+	assert.Equal(t, "1", received["uid"])
+	assert.Equal(t, "avatar.png:image/png:\x89PNG\r\n\x1a\nfake-avatar", received["avatar"])
+	assert.Equal(t, "application/x-www-form-urlencoded", received["profile-content-type"])
+	assert.Equal(t, "ving", received["name"])
+	assert.Equal(t, "image/png:\x89PNG\r\n\x1a\nfake-avatar", received["raw"])
+	assert.Equal(t, "text/plain:hello easytest", received["base64"])
+}
+
+func TestHTTPBodyModeUnknown(t *testing.T) {
+	item := &BasicItem{Name: "unknown", BodyMode: "graphql"}
+	_, _, err := item.requestBody(NewHttpContext().Render)
+	assert.NotNil(t, err)
+}
@@ -1,122 +1,123 @@
 package httptest
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
-	"testing"
 )
 
-type PostmanSpecInfo struct {
-	Info struct {
-		Name string `json:"name"`
-	} `json:"info"`
-	Item []*PostmanItem `json:"item"`
-}
-
-type PostmanItem struct {
-	Name  string `json:"name"`
-	Event []struct {
-		Listen string `json:"listen"`
-		Script struct {
-			Exec []string `json:"exec"`
-			Type string   `json:"type"`
-		} `json:"script"`
-	} `json:"event"`
-	Request struct {
-		Auth struct {
-			Type string `json:"type"`
-		} `json:"auth"`
-		Method string `json:"method"`
-		Header []struct {
-			Key   string `json:"key"`
-			Value string `json:"value"`
-			Type  string `json:"type"`
-		} `json:"header"`
-		Body struct {
-			Mode    string `json:"mode"`
-			Raw     string `json:"raw"`
-			Options struct {
-				Raw struct {
-					Language string `json:"language"`
-				} `json:"raw"`
-			} `json:"options"`
-		} `json:"body"`
-		Url struct {
-			Raw      string   `json:"raw"`
-			Protocol string   `json:"protocol"`
-			Host     []string `json:"host"`
-			Port     string   `json:"port"`
-			Path     []string `json:"path"`
-		} `json:"url"`
-	} `json:"request"`
-	Response []interface{} `json:"response"`
-}
-
 type BasicSpecInfo []*BasicItem
 
 type BasicParserSpecInfo BasicSpecInfo
 
 type BasicItem struct {
-	Name        string   `json:"name"`
-	Url         string   `json:"url"`
-	Method      string   `json:"method"`
-	Body        string   `json:"body"`
-	ContentType string   `json:"content-type"`
-	Header      []string `json:"header"`
-	Expect      []string `json:"expect"`
-	Event       []string `json:"event"`
-}
-
-func NewPostmanSpecInfo(data []byte, patch func(item *PostmanItem)) (*PostmanSpecInfo, error) {
-	var res PostmanSpecInfo
-	if err := json.Unmarshal(data, &res); err != nil {
-		return nil, err
-	}
+	Name        string       `json:"name"`
+	Url         string       `json:"url"`
+	Method      string       `json:"method"`
+	Body        string       `json:"body,omitempty"`
+	BodyMode    string       `json:"body-mode,omitempty"`
+	Form        []*FormField `json:"form,omitempty"`
+	File        string       `json:"file,omitempty"`
+	ContentType string       `json:"content-type,omitempty"`
+	Header      []string     `json:"header,omitempty"`
+	Expect      []string     `json:"expect,omitempty"`
+	Event       []string     `json:"event,omitempty"`
+	Schema      *JSONSchema  `json:"schema,omitempty"`   // 响应体需要满足的json schema
+	Insecure    bool         `json:"insecure,omitempty"` // 跳过https证书校验
+	Extends     string       `json:"extends,omitempty"`  // 继承的模板名称
+	Data        *SpecData    `json:"data,omitempty"`     // 数据驱动, 每一行执行一次
+	Foreach     string       `json:"foreach,omitempty"`  // 遍历表达式返回的数组, 例如$env.createdIds
+	Repeat      int          `json:"repeat,omitempty"`   // 重复执行的次数, 与while一起使用时为最大次数
+	While       string       `json:"while,omitempty"`    // 每次执行之后表达式成立时继续执行
+	Hook        string       `json:"hook,omitempty"`     // 作为钩子执行: before_all、after_all、before_each、after_each
+	Skip        bool         `json:"skip,omitempty"`     // 跳过, 记录为跳过而不是通过
+	Only        bool         `json:"only,omitempty"`     // 存在only的item时只执行这些item
+	If          string       `json:"if,omitempty"`       // 表达式成立时才执行, 例如$env.featureX == true
+	Tags        []string     `json:"tags,omitempty"`     // 标签, 用于筛选以及分组统计
 
-	if patch != nil {
-		for _, item := range res.Item {
-			patch(item)
-		}
-	}
-	return &res, nil
+	Snapshot        bool     `json:"snapshot,omitempty"`         // 与__snapshots__中保存的响应比较, 第一次执行时保存
+	SnapshotIgnore  []string `json:"snapshot-ignore,omitempty"`  // 快照中忽略的字段, 例如$.data.createdAt、$..id
+	SnapshotHeaders []string `json:"snapshot-headers,omitempty"` // 快照中保存的响应头, 默认为Content-Type
+
+	Example *MockResponse `json:"example,omitempty"` // mock server返回的示例响应
+
+	dir string // spec文件所在目录, 用于解析相对路径
 }
 
-func (s *PostmanSpecInfo) StartHandle(t *testing.T) error {
-	ctx := NewHttpContext()
-	for _, item := range s.Item {
-		opt := s.specReq2option(item)
-		ctx.Do(t, item.Name, opt)
+// 根据body-mode构造请求体, 返回请求体以及对应的content-type, render用于替换body与表单中的{{ }}变量
+func (item *BasicItem) requestBody(render func(string) string) (io.Reader, string, error) {
+	switch strings.ToLower(item.BodyMode) {
+	case "", BodyModeRaw:
+		return strings.NewReader(render(item.Body)), item.ContentType, nil
+	case BodyModeUrlencoded:
+		body, contentType := NewUrlencodedBody(renderForm(item.Form, render))
+		if item.ContentType != "" {
+			contentType = item.ContentType
+		}
+		return body, contentType, nil
+	case BodyModeMultipart:
+		// multipart的content-type需要携带boundary, 不允许覆盖
+		return NewMultipartBody(item.dir, renderForm(item.Form, render))
+	case BodyModeBinary:
+		body, contentType, err := NewBinaryBody(item.dir, item.File)
+		if err != nil {
+			return nil, "", err
+		}
+		if item.ContentType != "" {
+			contentType = item.ContentType
+		}
+		return body, contentType, nil
+	case BodyModeBase64:
+		body, err := NewBase64Body(item.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		contentType := item.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		return body, contentType, nil
 	}
-	return nil
+	return nil, "", fmt.Errorf("%s: 不支持的body-mode %s", item.Name, item.BodyMode)
 }
 
-func (s *PostmanSpecInfo) specReq2option(item *PostmanItem) *HandleOption {
-	contentType := ""
-	switch item.Request.Body.Options.Raw.Language {
-	case "json":
-		contentType = "application/json"
+func renderForm(fields []*FormField, render func(string) string) []*FormField {
+	res := make([]*FormField, 0, len(fields))
+	for _, item := range fields {
+		field := *item
+		field.Value = render(item.Value)
+		res = append(res, &field)
 	}
+	return res
+}
 
-	header := map[string]string{}
-	for _, item := range item.Request.Header {
-		header[item.Key] = item.Value
+// data可以为json或者yaml
+func NewBasicSpecInfo(data []byte, patch func(item *BasicItem)) (*BasicSpecInfo, error) {
+	items, err := loadSpec(data, "", "")
+	if err != nil {
+		return nil, err
 	}
 
-	return &HandleOption{
-		Url:         strings.Join(item.Request.Url.Host, "."),
-		Method:      item.Request.Method,
-		ContentType: contentType,
-		Header:      header,
-		Body:        strings.NewReader(item.Request.Body.Raw),
+	res := BasicSpecInfo(items)
+	if patch != nil {
+		for _, item := range res {
+			patch(item)
+		}
 	}
+	return &res, nil
 }
 
-func NewBasicSpecInfo(data []byte, patch func(item *BasicItem)) (*BasicSpecInfo, error) {
-	var res BasicSpecInfo
-	if err := json.Unmarshal(data, &res); err != nil {
+func NewBasicSpecInfoFromFile(path string, patch func(item *BasicItem)) (*BasicSpecInfo, error) {
+	items, err := loadSpecFile(path)
+	if err != nil {
 		return nil, err
 	}
-
+	res := BasicSpecInfo(items)
 	if patch != nil {
 		for _, item := range res {
 			patch(item)
@@ -125,41 +126,53 @@ func NewBasicSpecInfo(data []byte, patch func(item *BasicItem)) (*BasicSpecInfo,
 	return &res, nil
 }
 
-func (s *BasicSpecInfo) StartHandle(t *testing.T) error {
-	ctx := NewHttpContext()
-	for _, item := range *s {
-		opt := s.specReq2option(item)
-		ctx.Do(t, item.Name, opt)
-	}
-	return nil
+func (s *BasicSpecInfo) StartHandle(t TestingT) error {
+	return s.StartHandleWithContext(t, NewHttpContext())
 }
 
-func (s *BasicSpecInfo) specReq2option(item *BasicItem) *HandleOption {
+// 使用指定的ctx执行, 例如需要预置环境变量或者开启契约校验时
+func (s *BasicSpecInfo) StartHandleWithContext(t TestingT, ctx *HttpContext) error {
+	return s.Runner().Run(t, ctx)
+}
+
+// 替换变量、构造请求体, 转为请求参数
+func (item *BasicItem) option(ctx *HttpContext) (*HandleOption, error) {
 	header := map[string]string{}
 	for _, item := range item.Header {
-		pairs := strings.Split(item, ":")
+		// 值中可能包含:, 例如Referer: http://...
+		pairs := strings.SplitN(item, ":", 2)
 		if len(pairs) == 2 {
 			header[strings.TrimSpace(pairs[0])] = strings.TrimSpace(pairs[1])
 		}
 	}
 
+	body, contentType, err := item.requestBody(ctx.Render)
+	if err != nil {
+		return nil, err
+	}
+
 	return &HandleOption{
 		Url:         item.Url,
 		Method:      item.Method,
-		ContentType: item.ContentType,
+		ContentType: contentType,
 		Header:      header,
-		Body:        strings.NewReader(item.Body),
+		Body:        body,
 		Expect:      item.Expect,
 		Event:       item.Event,
-	}
+		Schema:      item.Schema,
+		Insecure:    item.Insecure,
+		Tags:        item.Tags,
+	}, nil
 }
 
+// data可以为json或者yaml
 func NewBasicParserSpecInfo(data []byte, patch func(item *BasicItem)) (*BasicParserSpecInfo, error) {
-	var res BasicParserSpecInfo
-	if err := json.Unmarshal(data, &res); err != nil {
+	items, err := loadSpec(data, "", "")
+	if err != nil {
 		return nil, err
 	}
 
+	res := BasicParserSpecInfo(items)
 	if patch != nil {
 		for _, item := range res {
 			patch(item)
@@ -168,31 +181,122 @@ func NewBasicParserSpecInfo(data []byte, patch func(item *BasicItem)) (*BasicPar
 	return &res, nil
 }
 
-func (s *BasicParserSpecInfo) StartHandle(t *testing.T) error {
-	ctx := NewHttpContext()
-	for _, item := range *s {
-		opt := s.specReq2option(item)
-		ctx.DoParser(t, item.Name, opt)
+func NewBasicParserSpecInfoFromFile(path string, patch func(item *BasicItem)) (*BasicParserSpecInfo, error) {
+	items, err := loadSpecFile(path)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	res := BasicParserSpecInfo(items)
+	if patch != nil {
+		for _, item := range res {
+			patch(item)
+		}
+	}
+	return &res, nil
 }
 
-func (s *BasicParserSpecInfo) specReq2option(item *BasicItem) *HandleOption {
-	header := map[string]string{}
-	for _, item := range item.Header {
-		pairs := strings.Split(item, ":")
-		if len(pairs) == 2 {
-			header[strings.TrimSpace(pairs[0])] = strings.TrimSpace(pairs[1])
+func (s *BasicParserSpecInfo) StartHandle(t TestingT) error {
+	return s.StartHandleWithContext(t, NewHttpContext())
+}
+
+// 使用指定的ctx执行, 例如需要预置环境变量或者开启契约校验时
+func (s *BasicParserSpecInfo) StartHandleWithContext(t TestingT, ctx *HttpContext) error {
+	return s.Runner().Run(t, ctx)
+}
+
+// 根据扩展名解析spec文件, 相对路径(上传文件、include)基于文件所在目录
+func loadSpecFile(path string) ([]*BasicItem, error) {
+	data, dir, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	items, err := loadSpec(data, filepath.Ext(path), dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return items, nil
+}
+
+// 解析spec: .http、.rest为REST Client格式, .yaml、.yml为yaml, .json为json, 其他扩展名根据内容判断json或者yaml
+// yaml转为json后解析, 两种格式使用相同的字段
+func unmarshalSpec(data []byte, ext string) (*specDocument, error) {
+	ext = strings.ToLower(ext)
+	switch ext {
+	case ".http", ".rest":
+		items, err := ParseHttpFile(data)
+		if err != nil {
+			return nil, err
+		}
+		return &specDocument{Items: items}, nil
+	case ".yaml", ".yml":
+	case ".json":
+	default:
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+			ext = ".json"
 		}
 	}
 
-	return &HandleOption{
-		Url:         item.Url,
-		Method:      item.Method,
-		ContentType: item.ContentType,
-		Header:      header,
-		Body:        strings.NewReader(item.Body),
-		Expect:      item.Expect,
-		Event:       item.Event,
+	if ext != ".json" {
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	// 数组形式只包含item, 对象形式可以包含include、templates
+	var doc specDocument
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		return &doc, nil
+	}
+	if err := json.Unmarshal(data, &doc.Items); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// body可以为字符串, 也可以直接写为json(yaml)对象或者数组, 后者序列化为json并默认使用application/json
+func (item *BasicItem) UnmarshalJSON(data []byte) error {
+	type plain BasicItem
+	aux := struct {
+		*plain
+		Body json.RawMessage `json:"body,omitempty"`
+	}{plain: (*plain)(item)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	body := bytes.TrimSpace(aux.Body)
+	switch {
+	case len(body) == 0 || string(body) == "null":
+		item.Body = ""
+	case body[0] == '"':
+		return json.Unmarshal(body, &item.Body)
+	default:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, body); err != nil {
+			return err
+		}
+		item.Body = buf.String()
+		if item.ContentType == "" && (body[0] == '{' || body[0] == '[') {
+			item.ContentType = "application/json"
+		}
+	}
+	return nil
+}
+
+// 读取spec文件, 返回文件内容以及所在目录
+func readSpecFile(path string) ([]byte, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, "", err
 	}
+	return data, filepath.Dir(path), nil
 }
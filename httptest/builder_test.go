@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strings"
 	"testing"
 
 	"net/http/httptest"
@@ -27,8 +26,12 @@ func TestHTTPFromPostmanJson(t *testing.T) {
 	postmanJsonData, err := ioutil.ReadAll(postmanJsonFile)
 	require.Nil(t, err)
 
+	tsUrl, err := url.Parse(ts.URL)
+	require.Nil(t, err)
 	specInfo, err := NewPostmanSpecInfo(postmanJsonData, func(item *PostmanItem) {
-		item.Request.Url.Host = strings.Split(ts.URL, ".")
+		item.Request.Url.Protocol = tsUrl.Scheme
+		item.Request.Url.Host = []string{tsUrl.Hostname()}
+		item.Request.Url.Port = tsUrl.Port()
 	})
 	require.Nil(t, err)
 
@@ -99,6 +102,59 @@ func TestHTTPFromBasicParserJson(t *testing.T) {
 	specInfo.StartHandle(t)
 }
 
+func TestBasicSpecInfoYaml(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/user/userinfo" && r.Header.Get("Authorization") != "bearer 132" {
+			w.WriteHeader(500)
+			return
+		}
+		if r.URL.Path == "/api/user/register" && r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(400)
+			return
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"msg":         "ok",
+			"accessToken": "132",
+		})
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	// 按照扩展名识别为yaml
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/gomall.basic_collection.yaml", func(item *BasicItem) {
+		item.Url = ts.URL + getPath(item.Url)
+	})
+	require.Nil(t, err)
+	require.Len(t, *specInfo, 3)
+
+	register, login, userinfo := (*specInfo)[0], (*specInfo)[1], (*specInfo)[2]
+	require.Equal(t, `{"gender":1,"mobile":"15212230311","name":"ving","password":"123456"}`, register.Body)
+	require.Equal(t, "application/json", register.ContentType)
+	require.Equal(t, "{\n    \"name\": \"ving\",\n    \"password\": \"123456\"\n}\n", login.Body)
+	require.Equal(t, []string{"$env.token=$json.accessToken"}, login.Event)
+	require.Equal(t, []string{"msg"}, userinfo.Schema.Required)
+
+	ctx := NewHttpContext()
+	require.Nil(t, specInfo.StartHandleWithContext(t, ctx))
+	for _, result := range ctx.Results() {
+		require.False(t, result.Failed, result.Title)
+	}
+
+	// 没有扩展名时根据内容判断, json中同样可以直接写对象
+	data, err := ioutil.ReadFile("./testdata/gomall.basic_collection.yaml")
+	require.Nil(t, err)
+	parserInfo, err := NewBasicParserSpecInfo(data, nil)
+	require.Nil(t, err)
+	require.Equal(t, register.Body, (*parserInfo)[0].Body)
+
+	jsonInfo, err := NewBasicSpecInfo([]byte(`[{"name": "a", "body": {"b": [1, 2]}}, {"name": "b", "body": "raw"}]`), nil)
+	require.Nil(t, err)
+	require.Equal(t, `{"b":[1,2]}`, (*jsonInfo)[0].Body)
+	require.Equal(t, "raw", (*jsonInfo)[1].Body)
+	require.Empty(t, (*jsonInfo)[1].ContentType)
+}
+
 func getPath(urlstr string) string {
 	u, err := url.Parse(urlstr)
 	if err != nil {
@@ -0,0 +1,535 @@
+package httptest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+////////////////////
+// curl命令与spec互相转换
+// 1、解析curl命令行: -X -H -d/--data-raw --data-urlencode -F -u -b -k等
+// 2、item或者执行失败的请求渲染为可以直接执行的curl命令
+////////////////////
+
+// 解析一个或多个curl命令, 每个curl开始一个新的命令
+func ParseCurl(command string) ([]*BasicItem, error) {
+	words, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*BasicItem{}
+	var args []string
+	flush := func() error {
+		if args == nil {
+			return nil
+		}
+		item, err := parseCurlArgs(args)
+		if err != nil {
+			return err
+		}
+		res = append(res, item)
+		args = nil
+		return nil
+	}
+	for _, word := range words {
+		if !word.quoted && word.value == "curl" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			args = []string{}
+			continue
+		}
+		if args == nil {
+			return nil, fmt.Errorf("命令需要以curl开头: %s", word.value)
+		}
+		// 多个命令之间的分隔符
+		if !word.quoted && (word.value == ";" || word.value == "&&") {
+			continue
+		}
+		args = append(args, word.value)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, errors.New("没有找到curl命令")
+	}
+	return res, nil
+}
+
+// 需要参数的选项, 短选项与长选项
+var curlValueFlags = map[string]string{
+	"-X": "--request", "-H": "--header", "-d": "--data", "-F": "--form", "-u": "--user", "-b": "--cookie",
+	"-A": "--user-agent", "-e": "--referer", "-o": "--output", "-m": "--max-time", "-w": "--write-out",
+	"-x": "--proxy", "-T": "--upload-file", "-c": "--cookie-jar", "-E": "--cert", "-r": "--range",
+}
+
+// 带参数但是与请求无关的长选项
+var curlIgnoredValueFlags = map[string]bool{
+	"--output": true, "--max-time": true, "--write-out": true, "--proxy": true, "--connect-timeout": true,
+	"--retry": true, "--cookie-jar": true, "--cert": true, "--key": true, "--cacert": true, "--resolve": true,
+	"--range": true, "--limit-rate": true, "--max-redirs": true,
+}
+
+func parseCurlArgs(args []string) (*BasicItem, error) {
+	item := &BasicItem{}
+	var (
+		method     string
+		data       []string
+		urlencoded []*FormField
+		rawEncoded bool // --data-urlencode中没有name的部分, 只能作为raw发送
+		get        bool
+	)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := arg, "", false
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			if index := strings.Index(arg, "="); index > 0 {
+				name, value, hasValue = arg[:index], arg[index+1:], true
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			if long, ok := curlValueFlags[arg[:2]]; ok {
+				name = long
+				if len(arg) > 2 {
+					value, hasValue = arg[2:], true
+				}
+			} else {
+				// 组合的短选项, 例如-sSLk, 需要参数的选项之后为参数, 例如-sXPOST、-sX POST
+				long := ""
+				for j, c := range arg[1:] {
+					if flag, ok := curlValueFlags["-"+string(c)]; ok {
+						long = flag
+						if rest := arg[1+j+utf8.RuneLen(c):]; rest != "" {
+							value, hasValue = rest, true
+						}
+						break
+					}
+					switch c {
+					case 'k':
+						item.Insecure = true
+					case 'G':
+						get = true
+					case 'I':
+						method = "HEAD"
+					}
+				}
+				if long == "" {
+					continue
+				}
+				name = long
+			}
+		default:
+			if item.Url != "" {
+				return nil, fmt.Errorf("重复的url: %s", arg)
+			}
+			item.Url = arg
+			continue
+		}
+
+		needValue := curlIgnoredValueFlags[name]
+		switch name {
+		case "--request", "--header", "--data", "--data-raw", "--data-binary", "--data-ascii", "--data-urlencode",
+			"--form", "--form-string", "--user", "--cookie", "--user-agent", "--referer", "--url", "--upload-file":
+			needValue = true
+		}
+		if needValue && !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s缺少参数", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--request":
+			method = strings.ToUpper(value)
+		case "--url":
+			item.Url = value
+		case "--header":
+			pairs := strings.SplitN(value, ":", 2)
+			if len(pairs) != 2 {
+				continue
+			}
+			key, val := strings.TrimSpace(pairs[0]), strings.TrimSpace(pairs[1])
+			if strings.EqualFold(key, "Content-Type") {
+				item.ContentType = val
+				continue
+			}
+			item.Header = append(item.Header, key+": "+val)
+		case "--user-agent":
+			item.Header = append(item.Header, "User-Agent: "+value)
+		case "--referer":
+			item.Header = append(item.Header, "Referer: "+value)
+		case "--user":
+			item.Header = append(item.Header, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+		case "--cookie":
+			// 不包含=时表示cookie文件
+			if !strings.Contains(value, "=") {
+				return nil, fmt.Errorf("不支持从文件读取cookie: %s", value)
+			}
+			item.Header = append(item.Header, "Cookie: "+value)
+		case "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				item.BodyMode = BodyModeBinary
+				item.File = value[1:]
+				continue
+			}
+			if name != "--data-binary" {
+				// 与curl一致, -d会去掉换行
+				value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			field, raw := parseCurlUrlencode(value)
+			if field == nil {
+				rawEncoded = true
+				data = append(data, raw)
+				continue
+			}
+			urlencoded = append(urlencoded, field)
+			data = append(data, raw)
+		case "--form", "--form-string":
+			field, err := parseCurlForm(value, name == "--form-string")
+			if err != nil {
+				return nil, err
+			}
+			item.BodyMode = BodyModeMultipart
+			item.Form = append(item.Form, field)
+		case "--upload-file":
+			item.BodyMode = BodyModeBinary
+			item.File = value
+			if method == "" {
+				method = "PUT"
+			}
+		case "--insecure":
+			item.Insecure = true
+		case "--get":
+			get = true
+		case "--head":
+			method = "HEAD"
+		}
+	}
+
+	if item.Url == "" {
+		return nil, errors.New("curl命令缺少url")
+	}
+
+	if get && len(data) > 0 {
+		sep := "?"
+		if strings.Contains(item.Url, "?") {
+			sep = "&"
+		}
+		item.Url += sep + strings.Join(data, "&")
+		data = nil
+		urlencoded = nil
+	}
+	switch {
+	case len(urlencoded) > 0 && len(urlencoded) == len(data) && !rawEncoded:
+		item.BodyMode = BodyModeUrlencoded
+		item.Form = urlencoded
+	case len(data) > 0:
+		item.Body = strings.Join(data, "&")
+		if item.ContentType == "" {
+			item.ContentType = "application/x-www-form-urlencoded"
+		}
+	}
+
+	if method == "" {
+		method = "GET"
+		if item.Body != "" || item.BodyMode != "" {
+			method = "POST"
+		}
+	}
+	item.Method = strings.ToLower(method)
+	if u, err := url.Parse(item.Url); err == nil {
+		item.Name = method + " " + u.Path
+	}
+	return item, nil
+}
+
+// --data-urlencode的几种形式: content =content name=content, 返回表单字段以及编码后的内容
+func parseCurlUrlencode(value string) (*FormField, string) {
+	index := strings.Index(value, "=")
+	switch {
+	case index < 0:
+		return nil, url.QueryEscape(value)
+	case index == 0:
+		return nil, url.QueryEscape(value[1:])
+	}
+	name, content := value[:index], value[index+1:]
+	return &FormField{Key: name, Value: content}, name + "=" + url.QueryEscape(content)
+}
+
+// -F name=value;type=text/plain 或者 name=@file;type=image/png
+func parseCurlForm(value string, literal bool) (*FormField, error) {
+	index := strings.Index(value, "=")
+	if index <= 0 {
+		return nil, fmt.Errorf("无法解析的表单字段: %s", value)
+	}
+	field := &FormField{Key: value[:index]}
+	content := value[index+1:]
+	if literal {
+		field.Value = content
+		return field, nil
+	}
+
+	parts := strings.Split(content, ";")
+	content = parts[0]
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(strings.TrimSpace(part), "type=") {
+			field.ContentType = strings.TrimPrefix(strings.TrimSpace(part), "type=")
+		}
+	}
+	if strings.HasPrefix(content, "@") {
+		field.File = content[1:]
+	} else {
+		field.Value = content
+	}
+	return field, nil
+}
+
+type shellWord struct {
+	value  string
+	quoted bool // 包含引号的部分, 例如'curl'不作为命令
+}
+
+// 按照shell的规则分词, 支持单引号、双引号、$'...'以及反斜杠续行
+func splitShellWords(s string) ([]shellWord, error) {
+	res := []shellWord{}
+	var cur strings.Builder
+	inWord, quoted := false, false
+	finish := func() {
+		if inWord {
+			res = append(res, shellWord{value: cur.String(), quoted: quoted})
+		}
+		cur.Reset()
+		inWord, quoted = false, false
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(runes) && (runes[i+1] == '\n' || runes[i+1] == '\r') {
+				// 续行
+				i++
+				if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+					i++
+				}
+				continue
+			}
+			if i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+				inWord = true
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			finish()
+		case c == '\'':
+			end := strings.IndexRune(string(runes[i+1:]), '\'')
+			if end < 0 {
+				return nil, errors.New("单引号没有闭合")
+			}
+			rest := []rune(string(runes[i+1:])[:end])
+			cur.WriteString(string(rest))
+			i += len(rest) + 1
+			inWord, quoted = true, true
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			n, err := readAnsiQuoted(runes[i+2:], &cur)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord, quoted = true, true
+		case c == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				cur.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("双引号没有闭合")
+			}
+			inWord, quoted = true, true
+		default:
+			cur.WriteRune(c)
+			inWord = true
+		}
+	}
+	finish()
+	return res, nil
+}
+
+// $'...'中的转义, 返回消耗的字符数(包含结束的单引号)
+func readAnsiQuoted(runes []rune, cur *strings.Builder) (int, error) {
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		if c == '\'' {
+			return i, nil
+		}
+		if c != '\\' || i+1 >= len(runes) {
+			cur.WriteRune(c)
+			continue
+		}
+		i++
+		switch runes[i] {
+		case 'n':
+			cur.WriteRune('\n')
+		case 't':
+			cur.WriteRune('\t')
+		case 'r':
+			cur.WriteRune('\r')
+		case 'x', 'u':
+			size := 2
+			if runes[i] == 'u' {
+				size = 4
+			}
+			if i+size < len(runes) {
+				if code, err := strconv.ParseUint(string(runes[i+1:i+1+size]), 16, 32); err == nil {
+					if size == 2 {
+						cur.WriteByte(byte(code))
+					} else {
+						cur.WriteRune(rune(code))
+					}
+					i += size
+					continue
+				}
+			}
+			cur.WriteRune('\\')
+			cur.WriteRune(runes[i])
+		default:
+			cur.WriteRune(runes[i])
+		}
+	}
+	return 0, errors.New("$'没有闭合")
+}
+
+// 单引号包裹, 内部的单引号转义为'\”
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// item渲染为curl命令, render用于替换{{ }}变量
+func (item *BasicItem) Curl(render func(string) string) string {
+	method := strings.ToUpper(item.Method)
+	if method == "" {
+		method = "GET"
+	}
+	parts := []string{}
+	prefix := ""
+	if method != "GET" {
+		parts = append(parts, "-X "+method)
+	}
+	if item.Insecure {
+		parts = append(parts, "-k")
+	}
+	for _, header := range item.Header {
+		parts = append(parts, "-H "+shellQuote(render(header)))
+	}
+
+	switch strings.ToLower(item.BodyMode) {
+	case BodyModeUrlencoded:
+		if item.ContentType != "" {
+			parts = append(parts, "-H "+shellQuote("Content-Type: "+item.ContentType))
+		}
+		for _, field := range item.Form {
+			parts = append(parts, "--data-urlencode "+shellQuote(field.Key+"="+render(field.Value)))
+		}
+	case BodyModeMultipart:
+		for _, field := range item.Form {
+			value := render(field.Value)
+			if field.File != "" {
+				value = "@" + resolvePath(item.dir, field.File)
+			}
+			if field.ContentType != "" {
+				value += ";type=" + field.ContentType
+			}
+			parts = append(parts, "-F "+shellQuote(field.Key+"="+value))
+		}
+	case BodyModeBinary:
+		if item.ContentType != "" {
+			parts = append(parts, "-H "+shellQuote("Content-Type: "+item.ContentType))
+		}
+		parts = append(parts, "--data-binary "+shellQuote("@"+resolvePath(item.dir, item.File)))
+	case BodyModeBase64:
+		contentType := item.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		parts = append(parts, "-H "+shellQuote("Content-Type: "+contentType), "--data-binary @-")
+		prefix = "echo " + shellQuote(strings.TrimSpace(item.Body)) + " | base64 -d | "
+	default:
+		if item.ContentType != "" {
+			parts = append(parts, "-H "+shellQuote("Content-Type: "+item.ContentType))
+		}
+		if item.Body != "" {
+			parts = append(parts, "--data-raw "+shellQuote(render(item.Body)))
+		}
+	}
+	return prefix + curlJoin(shellQuote(render(item.Url)), parts)
+}
+
+// 执行过的请求渲染为curl命令, 变量已经替换
+func (r *StepResult) Curl() string {
+	parts := []string{}
+	prefix := ""
+	if r.Method != "GET" {
+		parts = append(parts, "-X "+r.Method)
+	}
+	if r.insecure {
+		parts = append(parts, "-k")
+	}
+	keys := make([]string, 0, len(r.header))
+	for key := range r.header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range r.header[key] {
+			parts = append(parts, "-H "+shellQuote(key+": "+value))
+		}
+	}
+	if len(r.body) > 0 {
+		if utf8.Valid(r.body) {
+			parts = append(parts, "--data-raw "+shellQuote(string(r.body)))
+		} else {
+			parts = append(parts, "--data-binary @-")
+			prefix = "echo " + shellQuote(base64.StdEncoding.EncodeToString(r.body)) + " | base64 -d | "
+		}
+	}
+	return prefix + curlJoin(shellQuote(r.Url), parts)
+}
+
+func curlJoin(u string, parts []string) string {
+	var b strings.Builder
+	b.WriteString("curl " + u)
+	for _, item := range parts {
+		b.WriteString(" \\\n  " + item)
+	}
+	return b.String()
+}
+
+// 记录请求内容, 用于渲染curl
+func (r *StepResult) setRequest(req *http.Request, body []byte, insecure bool) {
+	r.header = req.Header.Clone()
+	r.body = body
+	r.insecure = insecure
+}
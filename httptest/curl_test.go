@@ -0,0 +1,163 @@
+package httptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCurl(t *testing.T) {
+	items, err := ParseCurl(`curl 'https://api.example.com/api/login?from=web' \
+  -H 'accept: application/json' \
+  -H 'content-type: application/json' \
+  -b 'sid=1; theme=dark' \
+  -u 'ving:123456' \
+  --data-raw $'{"name":"ving","note":"it\'s\\n你好"}' \
+  --compressed -k`)
+	require.Nil(t, err)
+	require.Len(t, items, 1)
+	login := items[0]
+	assert.Equal(t, "POST /api/login", login.Name)
+	assert.Equal(t, "post", login.Method)
+	assert.Equal(t, "https://api.example.com/api/login?from=web", login.Url)
+	assert.Equal(t, "application/json", login.ContentType)
+	assert.Equal(t, []string{"accept: application/json", "Cookie: sid=1; theme=dark", "Authorization: Basic dmluZzoxMjM0NTY="}, login.Header)
+	assert.Equal(t, `{"name":"ving","note":"it's\n你好"}`, login.Body)
+	assert.True(t, login.Insecure)
+
+	items, err = ParseCurl(`curl -XPUT "http://127.0.0.1:8000/api/profile" --data-urlencode "name=ving wang" --data-urlencode 'mobile=152 1223'
+curl http://127.0.0.1:8000/api/avatar -F uid=1 -F 'avatar=@avatar.png;type=image/png'
+curl -G http://127.0.0.1:8000/api/search -d q=go -d page=2
+curl http://127.0.0.1:8000/api/form -d 'a=1
+b=2' -d c=3
+curl --request=PATCH --url http://127.0.0.1:8000/api/raw --data-binary @body.bin`)
+	require.Nil(t, err)
+	require.Len(t, items, 5)
+
+	assert.Equal(t, "put", items[0].Method)
+	assert.Equal(t, BodyModeUrlencoded, items[0].BodyMode)
+	assert.Equal(t, []*FormField{{Key: "name", Value: "ving wang"}, {Key: "mobile", Value: "152 1223"}}, items[0].Form)
+
+	assert.Equal(t, "post", items[1].Method)
+	assert.Equal(t, BodyModeMultipart, items[1].BodyMode)
+	assert.Equal(t, []*FormField{{Key: "uid", Value: "1"}, {Key: "avatar", File: "avatar.png", ContentType: "image/png"}}, items[1].Form)
+
+	assert.Equal(t, "get", items[2].Method)
+	assert.Equal(t, "http://127.0.0.1:8000/api/search?q=go&page=2", items[2].Url)
+	assert.Empty(t, items[2].Body)
+
+	assert.Equal(t, "a=1b=2&c=3", items[3].Body)
+	assert.Equal(t, "application/x-www-form-urlencoded", items[3].ContentType)
+
+	assert.Equal(t, "patch", items[4].Method)
+	assert.Equal(t, BodyModeBinary, items[4].BodyMode)
+	assert.Equal(t, "body.bin", items[4].File)
+
+	// 组合的短选项中需要参数的选项
+	items, err = ParseCurl(`curl -sX POST http://h/x -kH 'A: b'
+curl -sSXDELETE http://h/y`)
+	require.Nil(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "post", items[0].Method)
+	assert.Equal(t, "http://h/x", items[0].Url)
+	assert.Equal(t, []string{"A: b"}, items[0].Header)
+	assert.True(t, items[0].Insecure)
+	assert.Equal(t, "delete", items[1].Method)
+
+	for _, command := range []string{`curl 'http://a`, `curl -H 'a: b'`, `wget http://a`, `curl http://a -b cookies.txt`} {
+		_, err := ParseCurl(command)
+		assert.NotNil(t, err, command)
+	}
+}
+
+func TestBasicItemCurl(t *testing.T) {
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", "http://127.0.0.1:8000")
+	ctx.Setenv("token", "it's-secret")
+
+	items := []*BasicItem{
+		{Url: "{{baseUrl}}/api/user", Method: "post", Header: []string{"Authorization: bearer {{token}}"}, ContentType: "application/json", Body: `{"name":"{{ token }}"}`},
+		{Url: "{{baseUrl}}/api/profile", Method: "put", BodyMode: BodyModeUrlencoded, Form: []*FormField{{Key: "name", Value: "ving wang"}}},
+		{Url: "{{baseUrl}}/api/avatar", Method: "post", BodyMode: BodyModeMultipart, Form: []*FormField{{Key: "uid", Value: "1"}, {Key: "avatar", File: "avatar.png", ContentType: "image/png"}}},
+		{Url: "https://127.0.0.1/api/ping", Method: "get", Insecure: true},
+	}
+
+	assert.Equal(t, `curl 'http://127.0.0.1:8000/api/user' \
+  -X POST \
+  -H 'Authorization: bearer it'\''s-secret' \
+  -H 'Content-Type: application/json' \
+  --data-raw '{"name":"it'\''s-secret"}'`, items[0].Curl(ctx.Render))
+	assert.Equal(t, `echo 'aGVsbG8=' | base64 -d | curl 'http://127.0.0.1:8000/api/raw' \
+  -X POST \
+  -H 'Content-Type: text/plain' \
+  --data-binary @-`, (&BasicItem{Url: "{{baseUrl}}/api/raw", Method: "post", BodyMode: BodyModeBase64, Body: "aGVsbG8=", ContentType: "text/plain"}).Curl(ctx.Render))
+
+	// 渲染的命令可以重新解析
+	for _, item := range items {
+		parsed, err := ParseCurl(item.Curl(ctx.Render))
+		require.Nil(t, err)
+		require.Len(t, parsed, 1)
+		assert.Equal(t, ctx.Render(item.Url), parsed[0].Url)
+		assert.Equal(t, item.Method, parsed[0].Method)
+		assert.Equal(t, item.BodyMode, parsed[0].BodyMode)
+		assert.Equal(t, item.Insecure, parsed[0].Insecure)
+		assert.Equal(t, len(item.Form), len(parsed[0].Form))
+	}
+}
+
+func TestBasicItemCurlWithEnvFile(t *testing.T) {
+	item := &BasicItem{Url: "{{baseUrl}}/api/user?uid={{uid}}", Header: []string{"Authorization: bearer {{token}}"}}
+
+	// json对象
+	env, err := NewEnvFromFile("./testdata/data/env.json")
+	require.Nil(t, err)
+	ctx := NewHttpContext()
+	for key, value := range env {
+		ctx.Setenv(key, value)
+	}
+	assert.Equal(t, `curl 'http://127.0.0.1:8000/api/user?uid=7' \
+  -H 'Authorization: bearer {{token}}'`, item.Curl(ctx.Render))
+
+	// postman环境文件, 没有启用的变量不使用
+	env, err = NewEnvFromFile("./testdata/postman/shop.postman_environment.json")
+	require.Nil(t, err)
+	assert.Equal(t, "env-token", env["token"])
+	assert.NotContains(t, env, "disabledVar")
+	ctx = NewHttpContext()
+	for key, value := range env {
+		ctx.Setenv(key, value)
+	}
+	assert.Equal(t, `curl '{{baseUrl}}/api/user?uid=7' \
+  -H 'Authorization: bearer env-token'`, item.Curl(ctx.Render))
+}
+
+func TestStepResultCurl(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"msg": "fail"}`))
+	}))
+	defer ts.Close()
+
+	ctx := NewHttpContext()
+	ctx.Setenv("token", "123")
+	// 失败的断言不影响当前测试
+	ctx.Do(&testing.T{}, "userinfo", &HandleOption{
+		Url:      ts.URL + "/api/user/userinfo",
+		Method:   "post",
+		Header:   map[string]string{"Authorization": "bearer {{token}}"},
+		Body:     strings.NewReader(`{"id": 1}`),
+		Expect:   []string{`$res.$body.$json.msg == "ok"`},
+		Insecure: true,
+	})
+	require.Len(t, ctx.Results(), 1)
+	result := ctx.Results()[0]
+	assert.True(t, result.Failed)
+	assert.Equal(t, `curl '`+ts.URL+`/api/user/userinfo' \
+  -X POST \
+  -k \
+  -H 'Authorization: bearer 123' \
+  --data-raw '{"id": 1}'`, result.Curl())
+}
@@ -0,0 +1,124 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+////////////////////
+// 数据驱动: item按照数据逐行执行, 每一行作为单独的执行记录
+// 1、data为csv或者json数组文件的路径
+// 2、data为内联的数组: 对象数组, 或者第一行为表头的二维数组
+// 3、当前行通过{{ row.x }}、$row.x读取
+////////////////////
+
+type SpecData struct {
+	File   string                   // 数据文件, 相对于spec文件所在目录
+	Inline []map[string]interface{} // 内联的数据
+}
+
+func (d *SpecData) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &d.File)
+	}
+
+	var rows []interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return errors.New("data需要为文件路径或者数组")
+	}
+	inline, err := tableRows(rows)
+	if err != nil {
+		return err
+	}
+	d.Inline = inline
+	return nil
+}
+
+func (d *SpecData) MarshalJSON() ([]byte, error) {
+	if d.File != "" {
+		return json.Marshal(d.File)
+	}
+	return json.Marshal(d.Inline)
+}
+
+// 读取数据, dir为spec文件所在目录
+func (d *SpecData) Rows(dir string) ([]map[string]interface{}, error) {
+	if d.File == "" {
+		return d.Inline, nil
+	}
+
+	path := d.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, _, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return csvRows(data)
+	}
+
+	var rows []interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("%s: 需要为json数组: %w", d.File, err)
+	}
+	return tableRows(rows)
+}
+
+// 第一行为表头
+func csvRows(data []byte) ([]map[string]interface{}, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	res := make([]map[string]interface{}, 0, len(records)-1)
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = record[i]
+		}
+		res = append(res, row)
+	}
+	return res, nil
+}
+
+// 对象数组, 或者第一行为表头的二维数组
+func tableRows(rows []interface{}) ([]map[string]interface{}, error) {
+	res := make([]map[string]interface{}, 0, len(rows))
+	var header []interface{}
+	for i, item := range rows {
+		switch item := item.(type) {
+		case map[string]interface{}:
+			res = append(res, item)
+		case []interface{}:
+			if i == 0 {
+				header = item
+				continue
+			}
+			if header == nil || len(item) != len(header) {
+				return nil, fmt.Errorf("data第%d行与表头的列数不一致", i+1)
+			}
+			row := make(map[string]interface{}, len(header))
+			for j, name := range header {
+				row[fmt.Sprint(name)] = item[j]
+			}
+			res = append(res, row)
+		default:
+			return nil, fmt.Errorf("data第%d行需要为对象或者数组", i+1)
+		}
+	}
+	return res, nil
+}
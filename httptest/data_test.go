@@ -0,0 +1,86 @@
+package httptest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecData(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Mobile string                 `json:"mobile"`
+			Extra  map[string]interface{} `json:"extra"`
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &req); err != nil {
+			w.WriteHeader(500)
+			return
+		}
+		if len(req.Mobile) != 11 || req.Mobile[10] < '0' || req.Mobile[10] > '9' {
+			w.WriteHeader(400)
+		} else if req.Extra["gender"] == nil {
+			w.WriteHeader(500)
+		}
+		body, _ := json.Marshal(map[string]interface{}{"mobile": req.Mobile})
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	specInfo, err := NewBasicParserSpecInfoFromFile("./testdata/data/register.yaml", nil)
+	require.Nil(t, err)
+
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, specInfo.StartHandleWithContext(t, ctx))
+
+	results := ctx.Results()
+	require.Len(t, results, 5)
+	titles := []string{}
+	for _, result := range results {
+		titles = append(titles, result.Title)
+		assert.False(t, result.Failed, result.Title)
+	}
+	assert.Equal(t, []string{"注册参数校验 #1", "注册参数校验 #2", "注册参数校验 #3", "注册 #1", "注册 #2"}, titles)
+	assert.Equal(t, 400, results[0].Status)
+	assert.Equal(t, 200, results[4].Status)
+
+	// 执行结束后不再读取到行数据
+	assert.Equal(t, "{{ row.mobile }}", ctx.Render("{{ row.mobile }}"))
+}
+
+func TestSpecDataDocument(t *testing.T) {
+	specInfo, err := NewBasicSpecInfo([]byte(`{
+		"data": [{"id": 1}, {"id": 2}],
+		"items": [
+			{"name": "a", "url": "/a/{{ row.id }}"},
+			{"name": "b", "url": "/b", "data": [["id"], [3]]}
+		]
+	}`), nil)
+	require.Nil(t, err)
+
+	rows, err := (*specInfo)[0].Data.Rows("")
+	require.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"id": 1.0}, {"id": 2.0}}, rows)
+	rows, err = (*specInfo)[1].Data.Rows("")
+	require.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{{"id": 3.0}}, rows)
+
+	data, err := json.Marshal((*specInfo)[1])
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"data":[{"id":3}]`)
+
+	for _, content := range []string{
+		`[{"name": "a", "data": 1}]`,
+		`[{"name": "a", "data": [["id"], [1, 2]]}]`,
+		`[{"name": "a", "data": [1]}]`,
+	} {
+		_, err := NewBasicSpecInfo([]byte(content), nil)
+		assert.NotNil(t, err, content)
+	}
+}
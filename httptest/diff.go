@@ -0,0 +1,243 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+////////////////////
+// json的结构化比较
+// 1、按照路径列出不同之处: ~修改、-缺少、+多出
+// 2、路径使用jsonpath的写法, 例如$.data.items[0].id
+// 3、差异过多、值过长时截断, 长字符串只显示第一处不同附近的内容
+// 4、输出到终端时按照差异类型着色, 报告中为纯文本
+////////////////////
+
+const (
+	diffChanged = '~' // 值不同
+	diffMissing = '-' // 期望中存在, 实际中缺少
+	diffAdded   = '+' // 期望中不存在, 实际中多出
+)
+
+const (
+	maxDiffEntries     = 20  // 最多显示的差异数量
+	maxDiffValueLength = 200 // 值的最大长度(字符数)
+	diffContextLength  = 40  // 长字符串在第一处不同前后显示的字符数
+)
+
+var jsonPathKeyReg = regexp.MustCompile(`^[A-Za-z_$][\w$-]*$`)
+
+// 标准输出为终端时着色, 设置NO_COLOR时不着色
+var colorOutput = func() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}()
+
+type diffEntry struct {
+	kind     byte
+	path     string
+	expected interface{}
+	actual   interface{}
+}
+
+func (d diffEntry) String() string {
+	switch d.kind {
+	case diffMissing:
+		return fmt.Sprintf("- %s: %s", d.path, truncateText(compactJSON(d.expected)))
+	case diffAdded:
+		return fmt.Sprintf("+ %s: %s", d.path, truncateText(compactJSON(d.actual)))
+	}
+	expected, actual := diffValues(d.expected, d.actual)
+	return fmt.Sprintf("~ %s: %s -> %s", d.path, expected, actual)
+}
+
+// 每个差异一行, 超过maxDiffEntries时截断
+func formatDiff(entries []diffEntry) string {
+	lines := []string{}
+	for i, entry := range entries {
+		if i == maxDiffEntries {
+			lines = append(lines, fmt.Sprintf("... 还有%d处不同", len(entries)-i))
+			break
+		}
+		lines = append(lines, entry.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 渲染期望值与实际值, 都是长字符串时只保留第一处不同附近的内容
+func diffValues(expected, actual interface{}) (string, string) {
+	exp, ok1 := expected.(string)
+	act, ok2 := actual.(string)
+	if !ok1 || !ok2 || (utf8.RuneCountInString(exp) <= maxDiffValueLength && utf8.RuneCountInString(act) <= maxDiffValueLength) {
+		return truncateText(compactJSON(expected)), truncateText(compactJSON(actual))
+	}
+
+	expRunes, actRunes := []rune(exp), []rune(act)
+	index := 0
+	for index < len(expRunes) && index < len(actRunes) && expRunes[index] == actRunes[index] {
+		index++
+	}
+	start := index - diffContextLength
+	if start < 0 {
+		start = 0
+	}
+	return stringContext(expRunes, start, index+diffContextLength), stringContext(actRunes, start, index+diffContextLength)
+}
+
+func stringContext(runes []rune, start int, end int) string {
+	if end > len(runes) {
+		end = len(runes)
+	}
+	if start > end {
+		start = end
+	}
+	res := compactJSON(string(runes[start:end]))
+	if start > 0 {
+		res = "..." + res
+	}
+	if end < len(runes) {
+		res += fmt.Sprintf("...(共%d个字符)", len(runes))
+	}
+	return res
+}
+
+// 超过maxDiffValueLength时截断
+func truncateText(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxDiffValueLength {
+		return s
+	}
+	return string(runes[:maxDiffValueLength]) + fmt.Sprintf("...(共%d个字符)", len(runes))
+}
+
+// 按照每行开头的-、+、~着色
+func colorizeDiff(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		color := ""
+		switch {
+		case strings.HasPrefix(trimmed, "- "):
+			color = "\033[31m"
+		case strings.HasPrefix(trimmed, "+ "):
+			color = "\033[32m"
+		case strings.HasPrefix(trimmed, "~ "):
+			color = "\033[33m"
+		}
+		if color != "" {
+			lines[i] = color + line + "\033[0m"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 输出到终端时着色
+func terminalText(text string) string {
+	if colorOutput {
+		return colorizeDiff(text)
+	}
+	return text
+}
+
+// 比较json解析后的值, 对象按照key排序输出
+func jsonDiff(path string, expected, actual interface{}) []diffEntry {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(exp)+len(act))
+		for key := range exp {
+			keys = append(keys, key)
+		}
+		for key := range act {
+			if _, ok := exp[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		res := []diffEntry{}
+		for _, key := range keys {
+			child := jsonPathChild(path, key)
+			expVal, expOk := exp[key]
+			actVal, actOk := act[key]
+			switch {
+			case !actOk:
+				res = append(res, diffEntry{kind: diffMissing, path: child, expected: expVal})
+			case !expOk:
+				res = append(res, diffEntry{kind: diffAdded, path: child, actual: actVal})
+			default:
+				res = append(res, jsonDiff(child, expVal, actVal)...)
+			}
+		}
+		return res
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			break
+		}
+		res := []diffEntry{}
+		for i := 0; i < len(exp) || i < len(act); i++ {
+			child := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(act):
+				res = append(res, diffEntry{kind: diffMissing, path: child, expected: exp[i]})
+			case i >= len(exp):
+				res = append(res, diffEntry{kind: diffAdded, path: child, actual: act[i]})
+			default:
+				res = append(res, jsonDiff(child, exp[i], act[i])...)
+			}
+		}
+		return res
+	}
+
+	if equalJSON(expected, actual) {
+		return nil
+	}
+	return []diffEntry{{kind: diffChanged, path: path, expected: expected, actual: actual}}
+}
+
+func jsonPathChild(path string, key string) string {
+	if jsonPathKeyReg.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+// json.Number按照数值比较, 1与1.0相同
+func equalJSON(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	x, ok1 := a.(json.Number)
+	y, ok2 := b.(json.Number)
+	if !ok1 || !ok2 {
+		return false
+	}
+	fx, err1 := x.Float64()
+	fy, err2 := y.Float64()
+	return err1 == nil && err2 == nil && fx == fy
+}
+
+func compactJSON(v interface{}) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
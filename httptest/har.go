@@ -0,0 +1,445 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+////////////////////
+// 将浏览器或者代理录制的HAR(1.2)转为spec
+// 1、按照host、method过滤, 丢弃静态资源以及CORS预检请求
+// 2、响应中的值(例如token)在之后的请求中出现时, 转为$env事件以及{{ }}变量
+// 3、ExpectBody时为json响应的顶层字段生成断言, 保存到环境变量的值除外
+// 4、保留请求的Cookie, Set-Cookie设置的值在之后的请求中出现时同样转为环境变量
+////////////////////
+
+type HAR struct {
+	Log struct {
+		Version string      `json:"version"`
+		Entries []*HAREntry `json:"entries"`
+	} `json:"log"`
+}
+
+type HAREntry struct {
+	StartedDateTime string `json:"startedDateTime"`
+	ResourceType    string `json:"_resourceType"` // chrome导出的资源类型
+	Request         struct {
+		Method      string          `json:"method"`
+		Url         string          `json:"url"`
+		Headers     []*HARNameValue `json:"headers"`
+		QueryString []*HARNameValue `json:"queryString"`
+		PostData    *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Params   []*struct {
+				Name        string `json:"name"`
+				Value       string `json:"value"`
+				FileName    string `json:"fileName"`
+				ContentType string `json:"contentType"`
+			} `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int             `json:"status"`
+		Headers []*HARNameValue `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HAROption struct {
+	Hosts      []string // 只保留这些host的请求, 支持*.example.com, 为空时不过滤
+	Methods    []string // 只保留这些method的请求, 为空时不过滤
+	KeepAssets bool     // 保留静态资源
+	ExpectBody bool     // 为json响应的顶层字符串、数字、布尔值生成断言
+}
+
+func NewHAR(data []byte) (*HAR, error) {
+	var res HAR
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func NewHARFromFile(path string) (*HAR, error) {
+	data, _, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewHAR(data)
+}
+
+// 浏览器自动添加或者由客户端计算的请求头, 不写入spec
+var harSkipHeaders = map[string]bool{
+	"host":            true,
+	"content-length":  true,
+	"connection":      true,
+	"accept-encoding": true,
+}
+
+var harAssetTypes = map[string]bool{
+	"stylesheet": true,
+	"script":     true,
+	"image":      true,
+	"font":       true,
+	"media":      true,
+	"manifest":   true,
+}
+
+var harAssetExts = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".map": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".ico": true, ".webp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".eot": true,
+	".mp4": true, ".mp3": true, ".webm": true,
+}
+
+// 响应中长度不小于该值的字符串才认为可能是token、id
+const harMinTokenLength = 8
+
+func (h *HAR) ToBasic(option *HAROption) *BasicSpecInfo {
+	if option == nil {
+		option = &HAROption{}
+	}
+
+	res := BasicSpecInfo{}
+	entries := []*HAREntry{}
+	for _, entry := range h.Log.Entries {
+		if !option.keep(entry) {
+			continue
+		}
+		res = append(res, entry.toBasic())
+		entries = append(entries, entry)
+	}
+	harCorrelate(res, entries)
+	if option.ExpectBody {
+		for i, item := range res {
+			item.Expect = append(item.Expect, entries[i].bodyExpects(item.Event)...)
+		}
+	}
+	return &res
+}
+
+func (o *HAROption) keep(entry *HAREntry) bool {
+	u, err := url.Parse(entry.Request.Url)
+	if err != nil {
+		return false
+	}
+	method := strings.ToUpper(entry.Request.Method)
+
+	if len(o.Hosts) > 0 && !matchHost(o.Hosts, u) {
+		return false
+	}
+	if len(o.Methods) > 0 {
+		found := false
+		for _, item := range o.Methods {
+			if strings.EqualFold(strings.TrimSpace(item), method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	// CORS预检请求
+	if method == "OPTIONS" && harHeader(entry.Request.Headers, "Access-Control-Request-Method") != "" {
+		return false
+	}
+	if !o.KeepAssets && entry.isAsset(u) {
+		return false
+	}
+	return true
+}
+
+func matchHost(hosts []string, u *url.URL) bool {
+	for _, item := range hosts {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch {
+		case item == strings.ToLower(u.Host), item == strings.ToLower(u.Hostname()):
+			return true
+		case strings.HasPrefix(item, "*.") && strings.HasSuffix(strings.ToLower(u.Hostname()), item[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+func (e *HAREntry) isAsset(u *url.URL) bool {
+	if harAssetTypes[strings.ToLower(e.ResourceType)] {
+		return true
+	}
+	if harAssetExts[strings.ToLower(path.Ext(u.Path))] {
+		return true
+	}
+	mimeType := strings.ToLower(e.Response.Content.MimeType)
+	for _, prefix := range []string{"image/", "font/", "audio/", "video/", "text/css", "text/javascript", "application/javascript"} {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func harHeader(headers []*HARNameValue, name string) string {
+	for _, item := range headers {
+		if strings.EqualFold(item.Name, name) {
+			return item.Value
+		}
+	}
+	return ""
+}
+
+func (e *HAREntry) toBasic() *BasicItem {
+	item := &BasicItem{
+		Url:    e.Request.Url,
+		Method: strings.ToLower(e.Request.Method),
+	}
+	if u, err := url.Parse(e.Request.Url); err == nil {
+		item.Name = strings.ToUpper(e.Request.Method) + " " + u.Path
+	}
+
+	for _, header := range e.Request.Headers {
+		name := strings.ToLower(header.Name)
+		// http2的伪首部以及sec-fetch等浏览器首部
+		if harSkipHeaders[name] || strings.HasPrefix(name, ":") || strings.HasPrefix(name, "sec-") {
+			continue
+		}
+		if name == "content-type" {
+			continue
+		}
+		item.Header = append(item.Header, header.Name+": "+header.Value)
+	}
+
+	if postData := e.Request.PostData; postData != nil {
+		mimeType := strings.ToLower(strings.TrimSpace(strings.Split(postData.MimeType, ";")[0]))
+		switch {
+		case mimeType == "multipart/form-data" && len(postData.Params) > 0:
+			item.BodyMode = BodyModeMultipart
+			for _, param := range postData.Params {
+				field := &FormField{Key: param.Name, Value: param.Value, ContentType: param.ContentType}
+				// HAR中不包含文件内容, 只保留文件名
+				if param.FileName != "" {
+					field.Value = ""
+					field.File = param.FileName
+				}
+				item.Form = append(item.Form, field)
+			}
+		case mimeType == "application/x-www-form-urlencoded" && postData.Text == "" && len(postData.Params) > 0:
+			item.BodyMode = BodyModeUrlencoded
+			item.ContentType = postData.MimeType
+			for _, param := range postData.Params {
+				item.Form = append(item.Form, &FormField{Key: param.Name, Value: param.Value})
+			}
+		default:
+			item.Body = postData.Text
+			item.ContentType = postData.MimeType
+		}
+	}
+
+	if e.Response.Status > 0 {
+		item.Expect = []string{fmt.Sprintf("$res.$status == %d", e.Response.Status)}
+	}
+	return item
+}
+
+// 响应体顶层字段的断言, 跳过events中保存到环境变量的字段
+func (e *HAREntry) bodyExpects(events []string) []string {
+	if !isJSONMedia(e.Response.Content.MimeType) || e.Response.Content.Encoding != "" {
+		return nil
+	}
+	var body map[string]interface{}
+	if err := decodeJSON([]byte(e.Response.Content.Text), &body); err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	res := []string{}
+	for _, key := range keys {
+		path := dslPath("$res.$body.$json", []string{key})
+		saved := false
+		for _, event := range events {
+			saved = saved || strings.HasSuffix(event, " = "+path)
+		}
+		if saved {
+			continue
+		}
+		switch value := body[key].(type) {
+		case string:
+			res = append(res, path+" == "+dslString(value))
+		case json.Number, bool:
+			res = append(res, fmt.Sprintf("%s == %v", path, value))
+		}
+	}
+	return res
+}
+
+// 响应中的一个值
+type harValue struct {
+	index int    // 所在的item
+	path  string // DSL取值路径
+	hint  string // 变量名的来源, 为空时使用path中最后一个字段名
+	name  string // 环境变量名, 在请求中使用时分配
+}
+
+// 关联前后请求: 响应中的值出现在之后的请求中时, 在响应处添加事件保存到环境变量, 请求中替换为变量
+func harCorrelate(items BasicSpecInfo, entries []*HAREntry) {
+	values := map[string]*harValue{}
+	names := map[string]bool{}
+	for i, item := range items {
+		// 长的值优先替换, 避免被其中包含的短值破坏
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(a, b int) bool {
+			if len(keys[a]) != len(keys[b]) {
+				return len(keys[a]) > len(keys[b])
+			}
+			return keys[a] < keys[b]
+		})
+		for _, key := range keys {
+			value := values[key]
+			item.replace(key, func() string { return value.use(items, names) })
+		}
+
+		entry := entries[i]
+		for _, cookie := range entry.setCookies() {
+			if len(cookie.Value) < harMinTokenLength {
+				continue
+			}
+			pattern := dslString(`(?:^|\n)` + regexp.QuoteMeta(cookie.Name) + `=([^;]*)`)
+			values[cookie.Value] = &harValue{index: i, path: fmt.Sprintf(`@match($res.$header."Set-Cookie", %s)`, pattern), hint: cookie.Name}
+		}
+		if !isJSONMedia(entry.Response.Content.MimeType) || entry.Response.Content.Encoding != "" {
+			continue
+		}
+		var body interface{}
+		if err := json.Unmarshal([]byte(entry.Response.Content.Text), &body); err != nil {
+			continue
+		}
+		harCollect(body, nil, func(segments []string, str string) {
+			if len(str) < harMinTokenLength {
+				return
+			}
+			values[str] = &harValue{index: i, path: dslPath("$res.$body.$json", segments)}
+		})
+	}
+}
+
+// 第一次使用时分配变量名并在来源处添加事件
+func (v *harValue) use(items BasicSpecInfo, names map[string]bool) string {
+	if v.name != "" {
+		return v.name
+	}
+	base := "value"
+	segments := strings.Split(v.path, ".")
+	if v.hint != "" {
+		base, segments = harVarName(v.hint), nil
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		segment := strings.Trim(segments[i], `"`)
+		if _, err := strconv.Atoi(segment); err != nil && segment != "" && !strings.HasPrefix(segment, "$") {
+			base = harVarName(segment)
+			break
+		}
+	}
+	v.name = base
+	for i := 2; names[v.name]; i++ {
+		v.name = fmt.Sprintf("%s_%d", base, i)
+	}
+	names[v.name] = true
+
+	source := items[v.index]
+	source.Event = append(source.Event, fmt.Sprintf("$env.%s = %s", v.name, v.path))
+	return v.name
+}
+
+// 响应中Set-Cookie设置的cookie
+func (e *HAREntry) setCookies() []*http.Cookie {
+	header := http.Header{}
+	for _, item := range e.Response.Headers {
+		if strings.EqualFold(item.Name, "Set-Cookie") {
+			header.Add("Set-Cookie", item.Value)
+		}
+	}
+	return (&http.Response{Header: header}).Cookies()
+}
+
+func harVarName(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' && b.Len() > 0 {
+			b.WriteRune(c)
+		}
+	}
+	if b.Len() == 0 {
+		return "value"
+	}
+	return b.String()
+}
+
+// 遍历json中的字符串
+func harCollect(value interface{}, segments []string, f func(segments []string, str string)) {
+	switch val := value.(type) {
+	case string:
+		f(append([]string{}, segments...), val)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			harCollect(val[key], append(segments, key), f)
+		}
+	case []interface{}:
+		for i, item := range val {
+			harCollect(item, append(segments, strconv.Itoa(i)), f)
+		}
+	}
+}
+
+// 将请求中出现的value替换为{{变量}}, 返回是否出现
+func (item *BasicItem) replace(value string, name func() string) bool {
+	found := strings.Contains(item.Url, value) || strings.Contains(item.Body, value)
+	for _, header := range item.Header {
+		found = found || strings.Contains(header, value)
+	}
+	for _, field := range item.Form {
+		found = found || strings.Contains(field.Value, value)
+	}
+	if !found {
+		return false
+	}
+
+	ref := "{{" + name() + "}}"
+	item.Url = strings.Replace(item.Url, value, ref, -1)
+	item.Body = strings.Replace(item.Body, value, ref, -1)
+	for i, header := range item.Header {
+		item.Header[i] = strings.Replace(header, value, ref, -1)
+	}
+	for _, field := range item.Form {
+		field.Value = strings.Replace(field.Value, value, ref, -1)
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package httptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHARToBasic(t *testing.T) {
+	har, err := NewHARFromFile("./testdata/har/journey.har")
+	require.Nil(t, err)
+
+	spec := *har.ToBasic(&HAROption{Hosts: []string{"api.example.com"}})
+	require.Len(t, spec, 3)
+
+	login := spec[0]
+	assert.Equal(t, "POST /api/login", login.Name)
+	assert.Equal(t, "post", login.Method)
+	assert.Equal(t, []string{"accept: application/json"}, login.Header)
+	assert.Equal(t, "application/json", login.ContentType)
+	assert.Equal(t, []string{"$res.$status == 200"}, login.Expect)
+	assert.Equal(t, []string{
+		"$env.accessToken = $res.$body.$json.data.accessToken",
+		"$env.id = $res.$body.$json.data.user.id",
+	}, login.Event)
+
+	user := spec[1]
+	assert.Equal(t, "https://api.example.com/api/users/{{id}}?detail=1", user.Url)
+	assert.Equal(t, []string{"authorization: Bearer {{accessToken}}", "cookie: sid=1"}, user.Header)
+	assert.Equal(t, []string{"$env.cart = $res.$body.$json.cart", "$env.id_2 = $res.$body.$json.id"}, user.Event)
+
+	cart := spec[2]
+	assert.Equal(t, "https://api.example.com/api/carts/{{cart}}/items", cart.Url)
+	assert.Equal(t, BodyModeUrlencoded, cart.BodyMode)
+	assert.Equal(t, []*FormField{{Key: "sku", Value: "100"}, {Key: "owner", Value: "{{id_2}}"}}, cart.Form)
+	assert.Equal(t, []string{"$res.$status == 201"}, cart.Expect)
+
+	// 不过滤host, 静态资源与预检请求仍然被丢弃
+	all := *har.ToBasic(nil)
+	require.Len(t, all, 4)
+	assert.Equal(t, "POST /collect", all[1].Name)
+	assert.Equal(t, "{{id}}", all[1].Body)
+
+	// 保存到环境变量的字段不生成断言
+	expects := *har.ToBasic(&HAROption{Hosts: []string{"api.example.com"}, ExpectBody: true})
+	assert.Equal(t, []string{"$res.$status == 200", "$res.$body.$json.code == 0"}, expects[0].Expect)
+	assert.Equal(t, []string{"$res.$status == 200", `$res.$body.$json.name == "ving"`}, expects[1].Expect)
+	assert.Equal(t, []string{"$res.$status == 201", "$res.$body.$json.ok == true"}, expects[2].Expect)
+
+	assert.Len(t, *har.ToBasic(&HAROption{Methods: []string{"get"}, KeepAssets: true}), 3)
+	assert.Len(t, *har.ToBasic(&HAROption{Hosts: []string{"*.example.com"}, Methods: []string{"post"}}), 2)
+}
+
+func TestHARSpecRun(t *testing.T) {
+	const token = "eyJhbGciOiJIUzI1NiJ9.e30.sig"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/login" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(401)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/login":
+			w.Write([]byte(`{"code":0,"data":{"accessToken":"` + token + `","user":{"id":"u-20230001"}}}`))
+		case "/api/users/u-20230001":
+			w.Write([]byte(`{"id":"u-20230001","cart":"c-88887777"}`))
+		case "/api/carts/c-88887777/items":
+			require.Nil(t, r.ParseForm())
+			if r.PostForm.Get("owner") != "u-20230001" {
+				w.WriteHeader(400)
+				return
+			}
+			w.WriteHeader(201)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	har, err := NewHARFromFile("./testdata/har/journey.har")
+	require.Nil(t, err)
+	data, err := json.Marshal(har.ToBasic(&HAROption{Hosts: []string{"api.example.com"}}))
+	require.Nil(t, err)
+
+	spec, err := NewBasicSpecInfo(data, func(item *BasicItem) {
+		item.Url = strings.Replace(item.Url, "https://api.example.com", ts.URL, 1)
+	})
+	require.Nil(t, err)
+	ctx := NewHttpContext()
+	require.Nil(t, spec.StartHandleWithContext(t, ctx))
+	for _, item := range ctx.Results() {
+		assert.NotEqual(t, 401, item.Status, item.Title)
+	}
+}
+
+func TestHARCookieSession(t *testing.T) {
+	har, err := NewHAR([]byte(`{"log": {"version": "1.2", "entries": [
+		{
+			"request": {"method": "POST", "url": "https://api.example.com/login", "headers": []},
+			"response": {"status": 200, "headers": [
+				{"name": "Set-Cookie", "value": "lang=zh; Path=/"},
+				{"name": "Set-Cookie", "value": "session=s-20230001; Path=/; HttpOnly"}
+			], "content": {"mimeType": "text/plain", "text": "ok"}}
+		},
+		{
+			"request": {"method": "GET", "url": "https://api.example.com/profile", "headers": [
+				{"name": "Cookie", "value": "lang=zh; session=s-20230001"}
+			]},
+			"response": {"status": 200, "headers": [], "content": {"mimeType": "text/plain", "text": "ving"}}
+		}
+	]}}`))
+	require.Nil(t, err)
+
+	spec := *har.ToBasic(nil)
+	require.Len(t, spec, 2)
+	assert.Equal(t, []string{`$env.session = @match($res.$header."Set-Cookie", "(?:^|\\n)session=([^;]*)")`}, spec[0].Event)
+	assert.Equal(t, []string{"Cookie: lang=zh; session={{session}}"}, spec[1].Header)
+
+	// 执行时使用服务端新生成的session
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "lang", Value: "zh", Path: "/"})
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s-20240002", Path: "/"})
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "s-20240002" {
+			w.WriteHeader(401)
+		}
+	}))
+	defer ts.Close()
+	for _, item := range spec {
+		item.Url = strings.Replace(item.Url, "https://api.example.com", ts.URL, 1)
+	}
+	ctx := NewHttpContext()
+	require.Nil(t, spec.StartHandleWithContext(t, ctx))
+	require.Len(t, ctx.Results(), 2)
+	assert.Equal(t, 200, ctx.Results()[1].Status)
+}
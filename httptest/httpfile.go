@@ -0,0 +1,251 @@
+package httptest
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+////////////////////
+// VS Code REST Client、JetBrains HTTP Client使用的.http文件
+// 1、###分隔请求, @var = value定义文件变量, # @name命名请求
+// 2、请求行 + 请求头 + 空行 + 请求体, < ./file引用文件作为请求体
+// 3、扩展的注释指令: # @expect、# @event、# @insecure、# @skip、# @only、# @if、# @foreach、# @repeat、# @while、# @snapshot
+////////////////////
+
+var (
+	httpFileVarReg       = regexp.MustCompile(`^@([\w.-]+)\s*=\s*(.*)$`)
+	httpFileDirectiveReg = regexp.MustCompile(`^(?:#|//)\s*@([\w-]+)\s*(.*)$`)
+	httpFileRequestReg   = regexp.MustCompile(`^(?i:(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|TRACE|CONNECT)\s+)?(\S+)(?:\s+HTTP/[\d.]+)?$`)
+	httpFileBodyFileReg  = regexp.MustCompile(`^<@?\s+(\S.*)$`)
+)
+
+// 解析.http文件, 文件变量在解析时替换, 未定义的{{ }}变量留到执行时从环境变量中读取
+func ParseHttpFile(data []byte) ([]*BasicItem, error) {
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+
+	res := []*BasicItem{}
+	vars := map[string]string{}
+	start, title := 0, ""
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "###") {
+			continue
+		}
+		item, err := parseHttpRequest(lines[start:i], start+1, vars)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			if item.Name == "" {
+				item.Name = title
+			}
+			res = append(res, item)
+		}
+		if i < len(lines) {
+			start, title = i+1, strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(lines[i]), "#"))
+		}
+	}
+
+	resolveHttpFileVars(vars)
+	render := func(s string) string {
+		return envReg.ReplaceAllStringFunc(s, func(match string) string {
+			if val, ok := vars[strings.TrimSpace(match[2:len(match)-2])]; ok {
+				return val
+			}
+			return match
+		})
+	}
+	for _, item := range res {
+		item.Url = render(item.Url)
+		item.Body = render(item.Body)
+		item.File = render(item.File)
+		item.ContentType = render(item.ContentType)
+		for i, header := range item.Header {
+			item.Header[i] = render(header)
+		}
+		if item.Name == "" {
+			// 去掉开头的{{baseUrl}}等变量, 只保留路径
+			rawurl := item.Url
+			if index := strings.Index(rawurl, "}}"); strings.HasPrefix(rawurl, "{{") && index > 0 {
+				rawurl = rawurl[index+2:]
+			}
+			item.Name = strings.ToUpper(item.Method) + " " + rawurl
+			if u, err := url.Parse(rawurl); err == nil {
+				item.Name = strings.ToUpper(item.Method) + " " + u.Path
+			}
+		}
+	}
+	return res, nil
+}
+
+// 解析###之间的一个请求, 只有变量或者注释时返回nil, lineno为第一行的行号
+func parseHttpRequest(lines []string, lineno int, vars map[string]string) (*BasicItem, error) {
+	item := &BasicItem{}
+	i := 0
+	// 请求行之前: 空行、注释、指令以及变量
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if match := httpFileVarReg.FindStringSubmatch(line); match != nil {
+			vars[match[1]] = strings.TrimSpace(match[2])
+			continue
+		}
+		if match := httpFileDirectiveReg.FindStringSubmatch(line); match != nil {
+			if err := item.httpFileDirective(match[1], strings.TrimSpace(match[2])); err != nil {
+				return nil, fmt.Errorf("第%d行: %s", lineno+i, err.Error())
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		break
+	}
+	if i == len(lines) {
+		return nil, nil
+	}
+
+	match := httpFileRequestReg.FindStringSubmatch(strings.TrimSpace(lines[i]))
+	if match == nil {
+		return nil, fmt.Errorf("第%d行: 不是合法的请求行: %s", lineno+i, strings.TrimSpace(lines[i]))
+	}
+	item.Method = strings.ToLower(match[1])
+	if item.Method == "" {
+		item.Method = "get"
+	}
+	item.Url = match[2]
+	// 多行的query: ?a=1 &b=2
+	for i++; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "?") && !strings.HasPrefix(line, "&") {
+			break
+		}
+		item.Url += line
+	}
+
+	// 请求头, 到空行为止
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			i++
+			break
+		}
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		index := strings.Index(line, ":")
+		if index <= 0 {
+			return nil, fmt.Errorf("第%d行: 不是合法的请求头: %s", lineno+i, line)
+		}
+		name, value := strings.TrimSpace(line[:index]), strings.TrimSpace(line[index+1:])
+		if strings.EqualFold(name, "Content-Type") {
+			item.ContentType = value
+			continue
+		}
+		item.Header = append(item.Header, name+": "+value)
+	}
+
+	// 请求体, 去掉末尾的空行
+	body := lines[i:]
+	for len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "" {
+		body = body[:len(body)-1]
+	}
+	for j, line := range body {
+		if strings.HasPrefix(line, "> {%") || strings.HasPrefix(line, ">> ") {
+			return nil, fmt.Errorf("第%d行: 不支持响应处理脚本, 请使用# @expect、# @event", lineno+i+j)
+		}
+	}
+	// < ./body.json, <@ ./body.json, <与文件名之间需要空白, 例如<a>1</a>为请求体
+	// REST Client中<@会替换文件内容中的变量, 这里两种写法都原样发送文件内容
+	if len(body) == 1 {
+		if match := httpFileBodyFileReg.FindStringSubmatch(strings.TrimSpace(body[0])); match != nil {
+			item.BodyMode = BodyModeBinary
+			item.File = strings.TrimSpace(match[1])
+			return item, nil
+		}
+	}
+	item.Body = strings.Join(body, "\n")
+	return item, nil
+}
+
+func (item *BasicItem) httpFileDirective(name string, value string) error {
+	switch name {
+	case "name":
+		// JetBrains中也可以写作# @name = login
+		item.Name = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	case "expect":
+		if value == "" {
+			return fmt.Errorf("@expect缺少表达式")
+		}
+		item.Expect = append(item.Expect, value)
+	case "event":
+		if value == "" {
+			return fmt.Errorf("@event缺少表达式")
+		}
+		item.Event = append(item.Event, value)
+	case "insecure", "no-cert-check":
+		item.Insecure = true
+	case "skip":
+		item.Skip = true
+	case "only":
+		item.Only = true
+	case "if":
+		if value == "" {
+			return fmt.Errorf("@if缺少表达式")
+		}
+		item.If = value
+	case "foreach":
+		if value == "" {
+			return fmt.Errorf("@foreach缺少表达式")
+		}
+		item.Foreach = value
+	case "while":
+		if value == "" {
+			return fmt.Errorf("@while缺少表达式")
+		}
+		item.While = value
+	case "snapshot":
+		item.Snapshot = true
+	case "snapshot-ignore":
+		if value == "" {
+			return fmt.Errorf("@snapshot-ignore缺少jsonpath")
+		}
+		item.Snapshot = true
+		item.SnapshotIgnore = append(item.SnapshotIgnore, strings.Fields(value)...)
+	case "repeat":
+		count, err := strconv.Atoi(value)
+		if err != nil || count <= 0 {
+			return fmt.Errorf("@repeat需要为正整数: %s", value)
+		}
+		item.Repeat = count
+	}
+	// 其他REST Client指令(@no-redirect、@note等)忽略
+	return nil
+}
+
+// 文件变量中可以引用其他文件变量
+func resolveHttpFileVars(vars map[string]string) {
+	for depth := 0; depth < len(vars); depth++ {
+		changed := false
+		for key, value := range vars {
+			res := envReg.ReplaceAllStringFunc(value, func(match string) string {
+				name := strings.TrimSpace(match[2 : len(match)-2])
+				if val, ok := vars[name]; ok && name != key {
+					return val
+				}
+				return match
+			})
+			if res != value {
+				vars[key] = res
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+}
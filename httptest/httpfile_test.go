@@ -0,0 +1,106 @@
+package httptest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHttpFile(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/http/gomall.http")
+	require.Nil(t, err)
+	items, err := ParseHttpFile(data)
+	require.Nil(t, err)
+	require.Len(t, items, 3)
+
+	login := items[0]
+	assert.Equal(t, "login", login.Name)
+	assert.Equal(t, "post", login.Method)
+	assert.Equal(t, "{{baseUrl}}/api/user/login", login.Url)
+	assert.Equal(t, "application/json", login.ContentType)
+	assert.Equal(t, "{\n    \"name\": \"ving\",\n    \"password\": \"123456\"\n}", login.Body)
+	assert.Equal(t, []string{"$res.$status == 200"}, login.Expect)
+	assert.Equal(t, []string{"$env.token = $res.$body.$json.accessToken"}, login.Event)
+
+	userinfo := items[1]
+	assert.Equal(t, "GET /api/user/userinfo", userinfo.Name)
+	assert.Equal(t, "get", userinfo.Method)
+	assert.Equal(t, []string{"Authorization: bearer {{token}}", "Referer: http://127.0.0.1/home"}, userinfo.Header)
+	assert.Empty(t, userinfo.Expect)
+	assert.Empty(t, userinfo.Body)
+
+	profile := items[2]
+	assert.Equal(t, "修改资料", profile.Name)
+	assert.Equal(t, BodyModeBinary, profile.BodyMode)
+	assert.Equal(t, "./profile.json", profile.File)
+	assert.Equal(t, []string{`$res.$body.$json.msg == "ok"`}, profile.Expect)
+
+	items, err = ParseHttpFile([]byte("@a = {{b}}/x\n@b = http://127.0.0.1\n\n{{a}}\r\n"))
+	require.Nil(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "GET /x", items[0].Name)
+
+	items, err = ParseHttpFile([]byte("POST http://a\nContent-Type: application/xml\n\n<a>1</a>\n###\nPOST http://b\n\n<@ ./body.json\n"))
+	require.Nil(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "", items[0].BodyMode)
+	assert.Equal(t, "<a>1</a>", items[0].Body)
+	assert.Equal(t, BodyModeBinary, items[1].BodyMode)
+	assert.Equal(t, "./body.json", items[1].File)
+
+	items, err = ParseHttpFile([]byte("# @repeat 3\n# @while $res.$body.$json.next != \"\"\nGET http://a?cursor={{cursor}}\n"))
+	require.Nil(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, 3, items[0].Repeat)
+	assert.Equal(t, `$res.$body.$json.next != ""`, items[0].While)
+
+	for _, content := range []string{
+		"GET http://a\nnot a header\n",
+		"# @repeat 0\nGET http://a",
+		"POST http://a\n\n{}\n\n> {%\nclient.test()\n%}",
+		"# @expect\nGET http://a",
+	} {
+		_, err := ParseHttpFile([]byte(content))
+		assert.NotNil(t, err, content)
+	}
+}
+
+func TestHTTPFromHttpFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/user/userinfo":
+			if r.Header.Get("Authorization") != "bearer 132" || r.Header.Get("Referer") == "" || r.URL.Query().Get("detail") != "true" {
+				w.WriteHeader(500)
+				return
+			}
+		case "/api/user/profile":
+			body, _ := ioutil.ReadAll(r.Body)
+			if string(body) != "{\"nickname\": \"ving\"}\n" {
+				w.WriteHeader(500)
+				return
+			}
+		}
+		body, _ := json.Marshal(map[string]interface{}{
+			"msg":         "ok",
+			"accessToken": "132",
+		})
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	// 按照扩展名识别为.http文件
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/http/gomall.http", nil)
+	require.Nil(t, err)
+
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, specInfo.StartHandleWithContext(t, ctx))
+	for _, result := range ctx.Results() {
+		assert.Equal(t, 200, result.Status, result.Title)
+	}
+}
@@ -2,15 +2,19 @@ package httptest
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
-	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/wwqdrh/logger"
@@ -31,10 +35,48 @@ type HttpContext struct {
 	response *http.Response
 
 	enviroment map[string]interface{}
+	row        map[string]interface{} // 数据驱动时当前行的数据
 
 	responseStatus int
 	responseData   string
 	responseJson   map[string]interface{}
+
+	contract   *OpenAPI // 不为空时根据文档校验每次请求与响应
+	violations []string
+
+	updateSnapshots bool         // 使用当前响应更新快照
+	tracer          *Tracer      // 不为空时输出每次请求的详细信息
+	timing          *Timing      // 最近一次请求的耗时
+	client          *http.Client // 不为空时使用该client发送请求, 例如压测时控制速率
+
+	results []*StepResult
+}
+
+// 一次请求的执行记录
+type StepResult struct {
+	Title  string `json:"title"`
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	Status int    `json:"status"`
+	Failed bool   `json:"failed"`
+
+	Skipped    bool     `json:"skipped,omitempty"`
+	SkipReason string   `json:"skip_reason,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Error      string   `json:"error,omitempty"` // 失败的原因, 例如断言的期望值与实际值
+	Timing     *Timing  `json:"timing,omitempty"`
+
+	// 请求内容, 用于渲染curl
+	header   http.Header
+	body     []byte
+	insecure bool
+}
+
+// 执行请求与断言需要的接口, *testing.T满足该接口, 在测试之外执行时(例如etcli)可以自行实现
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+	FailNow()
+	Log(args ...interface{})
 }
 
 type HandleOption struct {
@@ -45,8 +87,20 @@ type HandleOption struct {
 	Body        io.Reader
 	Handle      func(resp *http.Response) error
 
-	Expect []string
-	Event  []string
+	Expect   []string
+	Event    []string
+	Schema   *JSONSchema // 不为空时校验响应体
+	Snapshot *Snapshot   // 不为空时与快照比较
+
+	Insecure bool     // 跳过https证书校验
+	Tags     []string // 记录在执行结果中, 用于按照标签统计
+}
+
+var insecureClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
 }
 
 func NewHttpContext() *HttpContext {
@@ -55,6 +109,22 @@ func NewHttpContext() *HttpContext {
 	}
 }
 
+// 设置后每次请求都会根据openapi文档校验请求与响应, 不需要在expect中声明
+func (c *HttpContext) WithContract(doc *OpenAPI) *HttpContext {
+	c.contract = doc
+	return c
+}
+
+// 已经执行的请求
+func (c *HttpContext) Results() []*StepResult {
+	return c.results
+}
+
+// 不满足openapi文档的请求与响应
+func (c *HttpContext) Violations() []string {
+	return c.violations
+}
+
 func (c *HttpContext) CopyResponse(resp *http.Response) *http.Response {
 	bodyBytes, _ := ioutil.ReadAll(resp.Body)
 	newResponse := *resp
@@ -63,62 +133,147 @@ func (c *HttpContext) CopyResponse(resp *http.Response) *http.Response {
 	return &newResponse
 }
 
-func (c *HttpContext) Do(t *testing.T, title string, option *HandleOption) {
-	c.do(t, title, option)
+func (c *HttpContext) Do(t TestingT, title string, option *HandleOption) {
+	result := c.do(t, title, option)
 	// 处理response expect
-	assert.True(t, HandleExpect(c, option.Expect))
+	failures := c.checkResponse(option, HandleExpect)
 	// 处理event
-	assert.True(t, HandleEvent(c, option.Event))
+	env := c.traceEnvBefore()
+	if !HandleEvent(c, option.Event) {
+		failures = append(failures, "event执行失败")
+	}
+	c.traceEnvChanges(title, env)
+	for _, msg := range failures {
+		t.Errorf("%s: %s", title, terminalText(msg))
+	}
+	if result != nil && len(failures) > 0 {
+		result.Failed = true
+		result.Error = strings.Join(failures, "\n")
+	}
 }
 
-func (c *HttpContext) DoParser(t *testing.T, title string, option *HandleOption) {
-	c.do(t, title, option)
+func (c *HttpContext) DoParser(t TestingT, title string, option *HandleOption) {
+	result := c.do(t, title, option)
 
-	res := ParserHandleExpect(c, option.Expect)
-	if !res {
-		panic(c.request.URL.Path + "测试失败")
+	if failures := c.checkResponse(option, ParserHandleExpect); len(failures) > 0 {
+		if result != nil {
+			result.Failed = true
+			result.Error = strings.Join(failures, "\n")
+		}
+		panic(c.request.URL.Path + "测试失败: " + terminalText(strings.Join(failures, "\n")))
 	}
 
+	env := c.traceEnvBefore()
 	ParserHandleEvent(c, option.Event)
+	c.traceEnvChanges(title, env)
 }
 
-func (c *HttpContext) do(t *testing.T, title string, option *HandleOption) {
-	req, err := http.NewRequest(option.Method, option.Url, option.Body)
+// 校验expect、schema以及快照, 返回失败的说明
+func (c *HttpContext) checkResponse(option *HandleOption, check func(*HttpContext, []string) bool) []string {
+	failures := expectFailures(c, option.Expect, check)
+	if option.Schema != nil {
+		if err := c.ValidateSchema(option.Schema); err != nil {
+			failures = append(failures, "响应体不满足schema:\n  "+strings.Replace(err.Error(), "; ", "\n  ", -1))
+		}
+	}
+	if option.Snapshot != nil {
+		if err := c.MatchSnapshot(option.Snapshot); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	return failures
+}
+
+// 返回本次请求的执行记录, 没有得到响应时为nil
+func (c *HttpContext) do(t TestingT, title string, option *HandleOption) *StepResult {
+	var reqBody []byte
+	if option.Body != nil {
+		data, err := ioutil.ReadAll(option.Body)
+		require.Nil(t, err, title)
+		reqBody = data
+	}
+	req, err := http.NewRequest(strings.ToUpper(option.Method), c.Render(option.Url), bytes.NewReader(reqBody))
 	require.Nil(t, err, title)
 	c.request = req
 	for key, value := range c.ReqHeader(option.Header) {
 		req.Header.Add(key, value)
 	}
+	if option.ContentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", option.ContentType)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	client := http.DefaultClient
+	switch {
+	case c.client != nil:
+		client = c.client
+	case option.Insecure:
+		client = insecureClient
+	}
+	c.traceRequest(title, req, reqBody)
+	recorder := &timingRecorder{}
+	resp, err := client.Do(recorder.trace(req))
+	if err != nil {
+		c.traceError(title, err)
+	}
 	require.Nil(t, err, title)
 	c.response = c.CopyResponse(resp)
+	c.timing = recorder.finish()
 
 	curpRes := c.CopyResponse(resp)
 	// defer curpRes.Body.Close()
 	body, err := ioutil.ReadAll(curpRes.Body)
 	if err != nil {
 		logger.DefaultLogger.Warn(err.Error())
-		return
+		return nil
 	}
+	c.traceResponse(title, resp, body, c.timing)
 	bodyData := string(body)
 	c.responseData = bodyData
 	c.responseStatus = resp.StatusCode
+	result := &StepResult{
+		Title:  title,
+		Method: req.Method,
+		Url:    req.URL.String(),
+		Status: resp.StatusCode,
+		Tags:   option.Tags,
+		Timing: c.timing,
+	}
+	result.setRequest(req, reqBody, option.Insecure)
+	c.results = append(c.results, result)
+
+	if c.contract != nil {
+		err := c.contract.ValidateExchange(req, reqBody, resp, body)
+		if err != nil {
+			c.violations = append(c.violations, err.Error())
+			result.Failed = true
+		}
+		assert.NoError(t, err, title)
+	}
 
 	// 获取json
 	jsonData := map[string]interface{}{}
 	if err := json.Unmarshal(body, &jsonData); err != nil {
 		// logger.DefaultLogger.Warn(err.Error())
-		return
+		return result
 	}
 	c.responseJson = jsonData
 
 	if option.Handle != nil {
 		if err := option.Handle(resp); err != nil {
-			return
+			return result
 		}
 		// require.Nil(t, err, title)
 	}
+	return result
+}
+
+// 校验响应体是否满足schema
+func (c *HttpContext) ValidateSchema(schema *JSONSchema) error {
+	var body interface{}
+	if err := json.Unmarshal([]byte(c.responseData), &body); err != nil {
+		return fmt.Errorf("响应体不是合法的json: %w", err)
+	}
+	return schema.Validate(body)
 }
 
 func (c *HttpContext) Setenv(key string, value interface{}) {
@@ -128,19 +283,83 @@ func (c *HttpContext) Setenv(key string, value interface{}) {
 func (c *HttpContext) ReqHeader(header map[string]string) map[string]string {
 	res := map[string]string{}
 	for key, value := range header {
-		res[key] = value
-		for _, v := range envReg.FindAllStringSubmatch(value, -1) {
-			if len(v) == 2 {
-				envVal := c.enviroment[strings.TrimSpace(v[1])]
-				if envVal != "" {
-					res[key] = strings.Replace(res[key], v[0], fmt.Sprint(envVal), 1)
-				}
-			} else {
-				res[key] = value
+		res[key] = c.Render(value)
+	}
+	return res
+}
+
+// 将字符串中的{{key}}替换为环境变量, 变量不存在时保持原样
+func (c *HttpContext) Render(s string) string {
+	return c.render(s, nil)
+}
+
+// defaults为环境变量中不存在时的备选值, 例如postman中collection级别的变量
+func (c *HttpContext) render(s string, defaults map[string]interface{}) string {
+	return envReg.ReplaceAllStringFunc(s, func(match string) string {
+		key := strings.TrimSpace(match[2 : len(match)-2])
+		key = strings.TrimPrefix(key, "$env.")
+		if strings.HasPrefix(key, "row.") || strings.HasPrefix(key, "$row.") {
+			if val := lookupPath(c.row, key[strings.Index(key, ".")+1:]); val != nil {
+				return renderValue(val)
 			}
+			return match
+		}
+		if val, ok := c.enviroment[key]; ok && val != nil {
+			return fmt.Sprint(val)
+		}
+		if val, ok := defaults[key]; ok && val != nil {
+			return fmt.Sprint(val)
+		}
+		if val, ok := dynamicVariable(key); ok {
+			return val
+		}
+		return match
+	})
+}
+
+// 按照a.b.0多级取值, 不存在时为nil
+func lookupPath(value interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		switch val := value.(type) {
+		case map[string]interface{}:
+			value = val[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(val) {
+				return nil
+			}
+			value = val[index]
+		default:
+			return nil
 		}
 	}
-	return res
+	return value
+}
+
+// 对象、数组渲染为json, 例如数据驱动时的请求体片段
+func renderValue(val interface{}) string {
+	switch val.(type) {
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(val); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(val)
+}
+
+// 动态变量, 每次渲染时重新生成
+func dynamicVariable(key string) (string, bool) {
+	switch key {
+	case "$guid", "$randomUUID":
+		return uuid.New().String(), true
+	case "$timestamp":
+		return fmt.Sprint(time.Now().Unix()), true
+	case "$isoTimestamp":
+		return time.Now().UTC().Format(time.RFC3339), true
+	case "$randomInt":
+		return fmt.Sprint(rand.Intn(1001)), true
+	}
+	return "", false
 }
 
 func (c *HttpContext) Json(resp *http.Response) (map[string]interface{}, error) {
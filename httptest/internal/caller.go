@@ -7,6 +7,9 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +18,8 @@ type IHTTPCtx interface {
 	GetResponse() *http.Response
 	GetEnv(string) interface{}
 	SetEnv(string, interface{})
+	GetRow() map[string]interface{}    // 数据驱动时当前行的数据, 没有时为nil
+	GetTiming() map[string]interface{} // 最近一次请求各个阶段的耗时(毫秒): dns、connect、tls、ttfb、total
 }
 
 type IInstance interface {
@@ -79,34 +84,46 @@ func DoCaller(ctx IHTTPCtx, source string) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	switch v := v.(type) {
-	case ISetInstance:
-		return v.ReadAttr(), nil
-	case IInstance:
-		return v.ReadAttr(), nil
-	default:
-		return v, nil
-	}
+	return readValue(v), nil
 }
 
 func doCall(ctx IHTTPCtx, node *SyntaxNode) (interface{}, error) {
+	if node == nil {
+		return nil, errors.New("解析失败")
+	}
+
 	if node.Type == "expression" && node.Name == "." {
 		return CallerDot(ctx, node.Params)
 	} else if node.Type == "expression" && node.Name == "=" {
 		return CallAssign(ctx, node.Params)
+	} else if node.Type == "expression" && (node.Name == "&&" || node.Name == "||" || node.Name == "!") {
+		return CallerLogic(ctx, node)
+	} else if node.Type == "expression" {
+		return CallerCompare(ctx, node)
 	} else if node.Type == "global" {
 		return CallerGlobal(ctx, node)
 	} else if node.Type == "attr" {
 		return node.Name, nil
 	} else if node.Type == "literial" || node.Type == "variable" {
 		return node.Value, nil
-	} else if node.Type == "callable" && node.Name == "@contain" {
+	} else if node.Type == "callable" {
 		return CallerFuntion(ctx, node)
 	}
 	return nil, errors.New("解析失败")
 }
 
+// 读取IInstance、ISetInstance包装的实际值
+func readValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case ISetInstance:
+		return v.ReadAttr()
+	case IInstance:
+		return v.ReadAttr()
+	default:
+		return v
+	}
+}
+
 // 实现了.取值符的必须实现了IInstance接口或者本身是map数据类型
 func CallerDot(c IHTTPCtx, params []*SyntaxNode) (interface{}, error) {
 	if len(params) != 2 {
@@ -153,7 +170,7 @@ func CallAssign(c IHTTPCtx, params []*SyntaxNode) (interface{}, error) {
 		return nil, err
 	}
 
-	return insVal.SetValue(attrVal), nil
+	return insVal.SetValue(readValue(attrVal)), nil
 }
 
 // 获取当前http请求上下文中的response
@@ -162,14 +179,23 @@ func CallerGlobal(c IHTTPCtx, node *SyntaxNode) (interface{}, error) {
 	case "$res":
 		return NewDynamicIInstance(
 			func(s string) interface{} {
+				response := c.GetResponse()
+				if response == nil {
+					return nil
+				}
 				switch s {
 				case "$body":
-					response := c.GetResponse()
 					body, err := ioutil.ReadAll(response.Body)
 					if err != nil {
 						return nil
 					}
 					return wrapResBody(body)
+				case "$status":
+					return response.StatusCode
+				case "$header":
+					return wrapHeader(response.Header)
+				case "$timing":
+					return wrapDict(c.GetTiming())
 				default:
 					return nil
 				}
@@ -180,6 +206,8 @@ func CallerGlobal(c IHTTPCtx, node *SyntaxNode) (interface{}, error) {
 		return "$body", nil
 	case "$env":
 		return wrapEnv(c), nil
+	case "$row":
+		return wrapDict(c.GetRow()), nil
 	}
 	return nil, errors.New("TODO")
 }
@@ -207,8 +235,131 @@ func CallerFuntion(c IHTTPCtx, node *SyntaxNode) (interface{}, error) {
 		}
 		val2Str = fmt.Sprintf("%#v", val2Str)
 		return strings.Contains(fmt.Sprint(val1), val2Str), nil
+	case "@if":
+		// 条件成立时才对第二个参数求值, 例如 @if($res.$status == 200, $env.token = $res.$body.$json.token)
+		if len(node.Params) != 2 {
+			return nil, errors.New("@if必须有两个参数")
+		}
+		cond, err := doCall(c, node.Params[0])
+		if err != nil {
+			return nil, err
+		}
+		if !Truthy(readValue(cond)) {
+			return nil, nil
+		}
+		val, err := doCall(c, node.Params[1])
+		if err != nil {
+			return nil, err
+		}
+		return readValue(val), nil
+	case "@include":
+		// 字符串包含子串、数组包含元素或者对象包含key
+		if len(node.Params) != 2 {
+			return nil, errors.New("@include必须有两个参数")
+		}
+		params, err := callParams(c, node.Params)
+		if err != nil {
+			return nil, err
+		}
+		return includeValue(params[0], params[1]), nil
+	case "@match":
+		// 正则匹配, 返回指定分组, 默认为第一个分组(没有分组时为整个匹配), 不匹配时返回null
+		// 例如 @match($res.$body.$str, "name=\"csrf\" value=\"(.*?)\"", 1)
+		if len(node.Params) != 2 && len(node.Params) != 3 {
+			return nil, errors.New("@match必须有两个或者三个参数")
+		}
+		params, err := callParams(c, node.Params)
+		if err != nil {
+			return nil, err
+		}
+		return matchValue(params)
+	case "@append":
+		// 追加到数组, 第一个参数为环境变量时直接修改, 例如 @append($env.ids, $res.$body.$json.id)
+		if len(node.Params) < 2 {
+			return nil, errors.New("@append至少需要两个参数")
+		}
+		target, err := doCall(c, node.Params[0])
+		if err != nil {
+			return nil, err
+		}
+		values, err := callParams(c, node.Params[1:])
+		if err != nil {
+			return nil, err
+		}
+		list, ok := toList(readValue(target))
+		if !ok {
+			return nil, fmt.Errorf("@append的第一个参数不是数组: %v", readValue(target))
+		}
+		list = append(list, values...)
+		if setter, ok := target.(ISetInstance); ok {
+			setter.SetValue(list)
+		}
+		return list, nil
 	}
-	return nil, errors.New("TODO")
+	return nil, fmt.Errorf("未定义的函数%s", node.Name)
+}
+
+func matchValue(params []interface{}) (interface{}, error) {
+	pattern, ok := params[1].(string)
+	if !ok {
+		return nil, errors.New("@match的第二个参数需要为字符串")
+	}
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("@match: %w", err)
+	}
+	group := 0
+	if reg.NumSubexp() > 0 {
+		group = 1
+	}
+	if len(params) == 3 {
+		num, ok := toFloat(params[2])
+		if !ok || num < 0 || int(num) > reg.NumSubexp() {
+			return nil, fmt.Errorf("@match的分组%v不存在", params[2])
+		}
+		group = int(num)
+	}
+	if params[0] == nil {
+		return nil, nil
+	}
+	match := reg.FindStringSubmatch(fmt.Sprint(params[0]))
+	if match == nil {
+		return nil, nil
+	}
+	return match[group], nil
+}
+
+// 转为数组, nil视为空数组, 例如第一次@append时环境变量还不存在
+func toList(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case nil:
+		return []interface{}{}, true
+	case []interface{}:
+		// 复制一份, 避免修改原数组
+		return append([]interface{}{}, v...), true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	res := make([]interface{}, 0, rv.Len()+1)
+	for i := 0; i < rv.Len(); i++ {
+		res = append(res, rv.Index(i).Interface())
+	}
+	return res, true
+}
+
+// 依次求出参数的值
+func callParams(c IHTTPCtx, nodes []*SyntaxNode) ([]interface{}, error) {
+	res := make([]interface{}, 0, len(nodes))
+	for _, item := range nodes {
+		val, err := doCall(c, item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, readValue(val))
+	}
+	return res, nil
 }
 
 func wrapResBody(body []byte) IInstance {
@@ -216,11 +367,11 @@ func wrapResBody(body []byte) IInstance {
 		func(s string) interface{} {
 			switch s {
 			case "$json":
-				res := map[string]interface{}{}
+				var res interface{}
 				if err := json.Unmarshal(body, &res); err != nil {
-					return err
+					return nil
 				}
-				return wrapDict(res)
+				return wrapValue(res)
 			case "$str":
 				return fmt.Sprint(string(body))
 			default:
@@ -234,25 +385,90 @@ func wrapResBody(body []byte) IInstance {
 func wrapEnv(ctx IHTTPCtx) IInstance {
 	return NewDynamicIInstance(
 		func(s string) interface{} {
-			return NewDynamicISetInstance(
-				func(i interface{}) interface{} {
-					ctx.SetEnv(s, i)
-					return nil
-				},
-				func() interface{} {
-					return ctx.GetEnv(s)
-				},
-			)
+			return &envInstance{ctx: ctx, name: s}
 		},
 		func() interface{} { return nil },
 	)
 }
 
+// 环境变量, 可以赋值, 值为对象、数组时可以继续多级取值, 例如 $env.user.id
+type envInstance struct {
+	ctx  IHTTPCtx
+	name string
+}
+
+func (e *envInstance) SetValue(value interface{}) interface{} {
+	e.ctx.SetEnv(e.name, value)
+	return nil
+}
+
+func (e *envInstance) ReadAttr() interface{} {
+	return e.ctx.GetEnv(e.name)
+}
+
+func (e *envInstance) GetAttr(s string) interface{} {
+	if ins, ok := wrapValue(e.ReadAttr()).(IInstance); ok {
+		return ins.GetAttr(s)
+	}
+	return nil
+}
+
+// 对象与数组包装为IInstance, 支持多级取值, 例如 $json.data.items.0.id
+func wrapValue(data interface{}) interface{} {
+	switch data := data.(type) {
+	case map[string]interface{}:
+		return wrapDict(data)
+	case []interface{}:
+		return wrapList(data)
+	default:
+		return data
+	}
+}
+
 func wrapDict(data map[string]interface{}) IInstance {
 	return NewDynamicIInstance(
 		func(s string) interface{} {
-			return data[s]
+			return wrapValue(data[s])
+		},
+		func() interface{} { return data },
+	)
+}
+
+func wrapList(data []interface{}) IInstance {
+	return NewDynamicIInstance(
+		func(s string) interface{} {
+			if s == "length" {
+				return len(data)
+			}
+			index, err := strconv.Atoi(s)
+			if err != nil || index < 0 || index >= len(data) {
+				return nil
+			}
+			return wrapValue(data[index])
 		},
 		func() interface{} { return data },
 	)
 }
+
+// 响应头, 不存在的头返回nil, 多个Set-Cookie按行拼接
+func wrapHeader(header http.Header) IInstance {
+	return NewDynamicIInstance(
+		func(s string) interface{} {
+			values := header.Values(s)
+			if len(values) == 0 {
+				return nil
+			}
+			if strings.EqualFold(s, "Set-Cookie") {
+				return strings.Join(values, "\n")
+			}
+			return values[0]
+		},
+		func() interface{} {
+			res := map[string]interface{}{}
+			for key := range header {
+				res[key] = header.Get(key)
+			}
+			return res
+		},
+	)
+}
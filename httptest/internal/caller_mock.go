@@ -76,6 +76,34 @@ func (mr *MockIHTTPCtxMockRecorder) GetResponse() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResponse", reflect.TypeOf((*MockIHTTPCtx)(nil).GetResponse))
 }
 
+// GetRow mocks base method.
+func (m *MockIHTTPCtx) GetRow() map[string]interface{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRow")
+	ret0, _ := ret[0].(map[string]interface{})
+	return ret0
+}
+
+// GetRow indicates an expected call of GetRow.
+func (mr *MockIHTTPCtxMockRecorder) GetRow() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRow", reflect.TypeOf((*MockIHTTPCtx)(nil).GetRow))
+}
+
+// GetTiming mocks base method.
+func (m *MockIHTTPCtx) GetTiming() map[string]interface{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTiming")
+	ret0, _ := ret[0].(map[string]interface{})
+	return ret0
+}
+
+// GetTiming indicates an expected call of GetTiming.
+func (mr *MockIHTTPCtxMockRecorder) GetTiming() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTiming", reflect.TypeOf((*MockIHTTPCtx)(nil).GetTiming))
+}
+
 // SetEnv mocks base method.
 func (m *MockIHTTPCtx) SetEnv(arg0 string, arg1 interface{}) {
 	m.ctrl.T.Helper()
@@ -124,3 +152,68 @@ func (mr *MockIInstanceMockRecorder) GetAttr(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttr", reflect.TypeOf((*MockIInstance)(nil).GetAttr), arg0)
 }
+
+// ReadAttr mocks base method.
+func (m *MockIInstance) ReadAttr() interface{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadAttr")
+	ret0, _ := ret[0].(interface{})
+	return ret0
+}
+
+// ReadAttr indicates an expected call of ReadAttr.
+func (mr *MockIInstanceMockRecorder) ReadAttr() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadAttr", reflect.TypeOf((*MockIInstance)(nil).ReadAttr))
+}
+
+// MockISetInstance is a mock of ISetInstance interface.
+type MockISetInstance struct {
+	ctrl     *gomock.Controller
+	recorder *MockISetInstanceMockRecorder
+}
+
+// MockISetInstanceMockRecorder is the mock recorder for MockISetInstance.
+type MockISetInstanceMockRecorder struct {
+	mock *MockISetInstance
+}
+
+// NewMockISetInstance creates a new mock instance.
+func NewMockISetInstance(ctrl *gomock.Controller) *MockISetInstance {
+	mock := &MockISetInstance{ctrl: ctrl}
+	mock.recorder = &MockISetInstanceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockISetInstance) EXPECT() *MockISetInstanceMockRecorder {
+	return m.recorder
+}
+
+// ReadAttr mocks base method.
+func (m *MockISetInstance) ReadAttr() interface{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadAttr")
+	ret0, _ := ret[0].(interface{})
+	return ret0
+}
+
+// ReadAttr indicates an expected call of ReadAttr.
+func (mr *MockISetInstanceMockRecorder) ReadAttr() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadAttr", reflect.TypeOf((*MockISetInstance)(nil).ReadAttr))
+}
+
+// SetValue mocks base method.
+func (m *MockISetInstance) SetValue(arg0 interface{}) interface{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetValue", arg0)
+	ret0, _ := ret[0].(interface{})
+	return ret0
+}
+
+// SetValue indicates an expected call of SetValue.
+func (mr *MockISetInstanceMockRecorder) SetValue(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetValue", reflect.TypeOf((*MockISetInstance)(nil).SetValue), arg0)
+}
@@ -31,7 +31,16 @@ func (suite *CallerTestSuite) SetupTest() {
 			"accessToken": "12345",
 			"msgzh":       "请求成功",
 			"msgwithline": `"ok"`,
+			"data": map[string]interface{}{
+				"total": 2,
+				"items": []interface{}{
+					map[string]interface{}{"id": 1, "name": "a"},
+					map[string]interface{}{"id": 2, "name": "b"},
+				},
+			},
 		})
+		w.Header().Set("X-Request-Id", "req-1")
+		w.WriteHeader(201)
 		w.Write(body)
 	}))
 
@@ -186,3 +195,124 @@ func (suite *CallerTestSuite) TestContainerWithline() {
 	require.Nil(t, err)
 	fmt.Println(val)
 }
+
+func (suite *CallerTestSuite) TestCallerCompare() {
+	t := suite.T()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockReuqest, err := http.NewRequest("get", suite.mockServer.URL, nil)
+	require.Nil(t, err)
+
+	mock := NewMockIHTTPCtx(ctrl)
+	mock.EXPECT().GetRequest().AnyTimes().Return(mockReuqest)
+	mock.EXPECT().GetResponse().AnyTimes().DoAndReturn(func() *http.Response {
+		mockResponse, err := http.DefaultClient.Do(mockReuqest)
+		require.Nil(t, err)
+		return mockResponse
+	})
+	mock.EXPECT().GetEnv(gomock.Eq("flag")).AnyTimes().Return(true)
+	mock.EXPECT().GetEnv(gomock.Eq("missing")).AnyTimes().Return(nil)
+	mock.EXPECT().GetRow().AnyTimes().Return(map[string]interface{}{"status": "201", "case": map[string]interface{}{"name": "b"}})
+	mock.EXPECT().GetTiming().AnyTimes().Return(map[string]interface{}{"ttfb": 12.5, "total": 20.0})
+
+	var pairs = []struct {
+		source string
+		target interface{}
+	}{
+		{`$res.$status == 201`, true},
+		{`$res.$status != 200`, true},
+		{`$res.$status >= 200 && $res.$status < 300`, true},
+		{`$res.$body.$json.data.total == 2`, true},
+		{`$res.$body.$json.data.items.1.name == "b"`, true},
+		{`$res.$body.$json.data.items.length`, 2},
+		{`$res.$body.$json.data.items.0.id > 1`, false},
+		{`$res.$body.$json.data.nothing == null`, true},
+		{`$res.$header."X-Request-Id" == "req-1"`, true},
+		{`$res.$header.Location == null`, true},
+		{`$env.flag == true && !($env.missing)`, true},
+		{`$env.missing || $res.$status == 500`, false},
+		{`@include($res.$body.$str, "请求成功")`, true},
+		{`@include($res.$body.$json.data, "items")`, true},
+		{`$res.$body.$json.msg == "ok"`, true},
+		{`-1 < 0.5`, true},
+		{`$res.$status == $row.status`, true},
+		{`$row.case.name == $res.$body.$json.data.items.1.name`, true},
+		{`$row.missing == null`, true},
+		{`$res.$timing.total < 500 && $res.$timing.ttfb > 10`, true},
+		{`$res.$timing.dns == null`, true},
+	}
+
+	for _, item := range pairs {
+		val, err := DoCaller(mock, item.source)
+		require.Nil(t, err, item.source)
+		require.Equal(t, item.target, val, item.source)
+	}
+
+	_, err = DoCaller(mock, `@unknown($res.$status)`)
+	require.NotNil(t, err)
+	_, err = DoCaller(mock, `$res.$status == `)
+	require.NotNil(t, err)
+	// 带-的header需要加引号, 不加引号时报错而不是截断为$res.$header.Content
+	for _, source := range []string{`$res.$header.Content-Type == "nope"`, `$res.$header.X-Request-Id == "nope"`} {
+		_, err = DoCaller(mock, source)
+		require.NotNil(t, err, source)
+	}
+}
+
+func (suite *CallerTestSuite) TestCallerCapture() {
+	t := suite.T()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockReuqest, err := http.NewRequest("get", suite.mockServer.URL, nil)
+	require.Nil(t, err)
+
+	mock := NewMockIHTTPCtx(ctrl)
+	mock.EXPECT().GetRequest().AnyTimes().Return(mockReuqest)
+	mock.EXPECT().GetResponse().AnyTimes().DoAndReturn(func() *http.Response {
+		mockResponse, err := http.DefaultClient.Do(mockReuqest)
+		require.Nil(t, err)
+		return mockResponse
+	})
+	mock.EXPECT().GetEnv(gomock.Eq("user")).AnyTimes().Return(map[string]interface{}{"id": 1, "roles": []interface{}{"admin"}})
+	mock.EXPECT().GetEnv(gomock.Eq("ids")).AnyTimes().Return([]string{"a"})
+	mock.EXPECT().GetEnv(gomock.Eq("missing")).AnyTimes().Return(nil)
+	mock.EXPECT().SetEnv(gomock.Eq("ids"), gomock.Eq([]interface{}{"a", 201})).Times(1)
+	mock.EXPECT().SetEnv(gomock.Eq("missing"), gomock.Eq([]interface{}{"12345"})).Times(1)
+	mock.EXPECT().SetEnv(gomock.Eq("token"), gomock.Eq("12345")).Times(1)
+
+	var pairs = []struct {
+		source string
+		target interface{}
+	}{
+		{`@match($res.$body.$str, "\"accessToken\":\"(\d+)\"")`, "12345"},
+		{`@match($res.$body.$str, "\"msg\":\"(o)(k)\"", 2)`, "k"},
+		{`@match($res.$body.$str, "\"msg\":\"ok\"", 0)`, `"msg":"ok"`},
+		{`@match($res.$body.$str, "nothing(\d)")`, nil},
+		{`@match($res.$header."X-Request-Id", "req-(\d+)") == "1"`, true},
+		{`$env.user.id == 1`, true},
+		{`$env.user.roles.0`, "admin"},
+		{`$env.missing.id == null`, true},
+		{`@append($env.ids, $res.$status)`, []interface{}{"a", 201}},
+		{`@append($env.missing, $res.$body.$json.accessToken)`, []interface{}{"12345"}},
+		{`$env.token = @match($res.$body.$str, "\"accessToken\":\"(.*?)\"", 1)`, nil},
+	}
+
+	for _, item := range pairs {
+		val, err := DoCaller(mock, item.source)
+		require.Nil(t, err, item.source)
+		require.Equal(t, item.target, val, item.source)
+	}
+
+	for _, source := range []string{
+		`@match($res.$body.$str, "(")`,
+		`@match($res.$body.$str, "(ok)", 2)`,
+		`@append($env.user, 1)`,
+	} {
+		_, err = DoCaller(mock, source)
+		require.NotNil(t, err, source)
+	}
+}
@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 比较表达式两边的值, 用于断言失败时输出期望值与实际值
+type Comparison struct {
+	Op    string
+	Left  interface{}
+	Right interface{}
+}
+
+// 计算比较表达式两边的值, source不是比较表达式时返回nil
+func DoCompare(ctx IHTTPCtx, source string) (*Comparison, error) {
+	p := NewSimpleParser(NewLexer(source))
+	node, err := p.Parse()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if node == nil || node.Type != "expression" || len(node.Params) != 2 {
+		return nil, nil
+	}
+	switch node.Name {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, nil
+	}
+	params, err := callParams(ctx, node.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &Comparison{Op: node.Name, Left: params[0], Right: params[1]}, nil
+}
+
+// 比较运算 == != < <= > >=
+func CallerCompare(c IHTTPCtx, node *SyntaxNode) (interface{}, error) {
+	if len(node.Params) != 2 {
+		return nil, errors.New("ast error, 参数只能为两个")
+	}
+	params, err := callParams(c, node.Params)
+	if err != nil {
+		return nil, err
+	}
+	left, right := params[0], params[1]
+
+	switch node.Name {
+	case "==":
+		return equalValue(left, right), nil
+	case "!=":
+		return !equalValue(left, right), nil
+	}
+
+	// 大小比较, 都能转为数字时按数字比较, 否则按字符串比较
+	var cmp int
+	leftNum, leftOk := toFloat(left)
+	rightNum, rightOk := toFloat(right)
+	if leftOk && rightOk {
+		switch {
+		case leftNum < rightNum:
+			cmp = -1
+		case leftNum > rightNum:
+			cmp = 1
+		}
+	} else {
+		if left == nil || right == nil {
+			return false, nil
+		}
+		cmp = strings.Compare(fmt.Sprint(left), fmt.Sprint(right))
+	}
+
+	switch node.Name {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("未定义的运算符%s", node.Name)
+}
+
+// 逻辑运算 && || !
+func CallerLogic(c IHTTPCtx, node *SyntaxNode) (interface{}, error) {
+	if node.Name == "!" {
+		if len(node.Params) != 1 {
+			return nil, errors.New("ast error, !只能有一个参数")
+		}
+		val, err := doCall(c, node.Params[0])
+		if err != nil {
+			return nil, err
+		}
+		return !Truthy(readValue(val)), nil
+	}
+
+	if len(node.Params) != 2 {
+		return nil, errors.New("ast error, 参数只能为两个")
+	}
+	left, err := doCall(c, node.Params[0])
+	if err != nil {
+		return nil, err
+	}
+	leftVal := Truthy(readValue(left))
+	// 短路求值
+	if node.Name == "&&" && !leftVal {
+		return false, nil
+	}
+	if node.Name == "||" && leftVal {
+		return true, nil
+	}
+
+	right, err := doCall(c, node.Params[1])
+	if err != nil {
+		return nil, err
+	}
+	return Truthy(readValue(right)), nil
+}
+
+// 将值转为bool, nil、false、0、空字符串为false
+func Truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if num, ok := toFloat(v); ok {
+		return num != 0
+	}
+	return true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		num, err := v.Float64()
+		return num, err == nil
+	case string:
+		num, err := strconv.ParseFloat(v, 64)
+		return num, err == nil
+	}
+	return 0, false
+}
+
+// 数字之间按数值比较(json中的数字为float64), 字符串与数字比较时尝试将字符串转为数字
+func equalValue(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	_, leftStr := left.(string)
+	_, rightStr := right.(string)
+	if !(leftStr && rightStr) {
+		leftNum, leftOk := toFloat(left)
+		rightNum, rightOk := toFloat(right)
+		if leftOk && rightOk {
+			return leftNum == rightNum
+		}
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// 字符串包含子串、数组包含元素或者对象包含key
+func includeValue(container, item interface{}) bool {
+	switch container := container.(type) {
+	case []interface{}:
+		for _, v := range container {
+			if equalValue(v, item) {
+				return true
+			}
+		}
+		return false
+	case map[string]interface{}:
+		_, ok := container[fmt.Sprint(item)]
+		return ok
+	case nil:
+		return false
+	}
+	return strings.Contains(fmt.Sprint(container), fmt.Sprint(item))
+}
@@ -23,12 +23,24 @@ const (
 	BODY
 	JSON
 	HEADER
+	STATUS
+	ROW
+	TIMING
 
 	// 全局函数
 	CONTAIN
+	FUNC // 其他@开头的函数, Raw为函数名
 
 	// 操作符
 	EQ
+	NE
+	LT
+	LE
+	GT
+	GE
+	AND
+	OR
+	NOT
 	ASSIGN_OPERATOR
 	DOT
 
@@ -40,6 +52,8 @@ const (
 	// 字面量
 	NUM        // 数字
 	REAL       // 浮点数
+	BOOL       // true false
+	NULL       // null
 	INDENTIFER // 变量
 
 	// 其他标识符
@@ -56,8 +70,20 @@ var tokenMap = map[Tag]string{
 	BODY:            "$body",
 	JSON:            "$json",
 	HEADER:          "$header",
+	STATUS:          "$status",
+	ROW:             "$row",
+	TIMING:          "$timing",
 	CONTAIN:         "@contain",
+	FUNC:            "func",
 	EQ:              "==",
+	NE:              "!=",
+	LT:              "<",
+	LE:              "<=",
+	GT:              ">",
+	GE:              ">=",
+	AND:             "&&",
+	OR:              "||",
+	NOT:             "!",
 	ASSIGN_OPERATOR: "=",
 	DOT:             ".",
 	LEFT_PATREN:     "(",
@@ -65,6 +91,8 @@ var tokenMap = map[Tag]string{
 	COMMA:           ",",
 	NUM:             "num",
 	REAL:            "real",
+	BOOL:            "bool",
+	NULL:            "null",
 	INDENTIFER:      "indentifer",
 	EOF:             "EOF",
 	ERROR:           "syntax error",
@@ -79,6 +107,9 @@ var keyWord = []KeyWord{
 	NewKeyWord(JSON),
 	NewKeyWord(BODY),
 	NewKeyWord(HEADER),
+	NewKeyWord(STATUS),
+	NewKeyWord(ROW),
+	NewKeyWord(TIMING),
 	NewKeyWord(CONTAIN),
 }
 
@@ -198,7 +229,7 @@ func (l *Lexer) Scan() (Token, error) {
 			return NewToken(ERROR), err
 		}
 
-		if l.peek == ' ' || l.peek == '\t' {
+		if l.peek == ' ' || l.peek == '\t' || l.peek == '\r' {
 			continue
 		} else if l.peek == '\n' {
 			l.line += 1
@@ -219,12 +250,8 @@ func (l *Lexer) Scan() (Token, error) {
 
 		return keyword.Tag, nil
 	case '@':
-		// 说明是函数
-		keyword, err := l.ScanKeyword()
-		if err != nil {
-			return NewToken(ERROR), err
-		}
-		return keyword.Tag, nil
+		// 说明是函数, 非内置关键字的函数使用FUNC
+		return l.ScanFunction()
 	case '(':
 		return NewToken(LEFT_PATREN), nil
 	case ')':
@@ -237,69 +264,46 @@ func (l *Lexer) Scan() (Token, error) {
 		// 字符串
 		return l.ScanString()
 	case '=':
+		if ok, _ := l.ReadCharacter('='); ok {
+			return NewToken(EQ), nil
+		}
 		l.Lexeme = "="
 		l.lexemeStack = append(l.lexemeStack, "=")
 		return NewToken(ASSIGN_OPERATOR), nil
-	}
-
-	// 判断是否是数字
-	if unicode.IsNumber(rune(l.peek)) {
-		var v int
-		var err error
-		for {
-			num, err := strconv.Atoi(string(l.peek))
-			if err != nil {
-				if err := l.UnRead(); err != nil {
-					break
-				}
-				break
-			} else {
-				l.Lexeme += string(l.peek)
-			}
-			v = v*10 + num
-			if l.Readch() == io.EOF {
-				token := NewToken(NUM)
-				token.Raw = v
-				return token, nil
-			}
+	case '!':
+		if ok, _ := l.ReadCharacter('='); ok {
+			return NewToken(NE), nil
 		}
-
-		if l.peek != '.' {
-			// 整型
-			l.lexemeStack = append(l.lexemeStack, fmt.Sprint(v))
-			token := NewToken(NUM)
-			token.Raw = v
-			return token, err
+		return NewToken(NOT), nil
+	case '<':
+		if ok, _ := l.ReadCharacter('='); ok {
+			return NewToken(LE), nil
 		}
-		l.Lexeme += string(l.peek)
-
-		// 浮点型
-		x := float64(v)
-		d := float64(10)
-		for {
-			if err := l.Readch(); err != nil {
-				break
-			}
-			num, err := strconv.Atoi(string(l.peek))
-			if err != nil {
-				if err := l.UnRead(); err != nil {
-					break
-				}
-				break
-			}
-
-			x = x + float64(num)/d
-			d *= 10
-			l.Lexeme += string(l.peek)
+		return NewToken(LT), nil
+	case '>':
+		if ok, _ := l.ReadCharacter('='); ok {
+			return NewToken(GE), nil
 		}
-		l.lexemeStack = append(l.lexemeStack, fmt.Sprint(x))
-		token := NewToken(REAL)
-		token.Raw = x
-		return token, err
+		return NewToken(GT), nil
+	case '&':
+		if ok, _ := l.ReadCharacter('&'); ok {
+			return NewToken(AND), nil
+		}
+		return NewToken(ERROR), errors.New("非法字符&")
+	case '|':
+		if ok, _ := l.ReadCharacter('|'); ok {
+			return NewToken(OR), nil
+		}
+		return NewToken(ERROR), errors.New("非法字符|")
+	}
+
+	// 判断是否是数字, -后紧跟数字时为负数
+	if unicode.IsDigit(l.peek) || (l.peek == '-' && l.peekDigit(0)) {
+		return l.ScanNumber()
 	}
 
 	// 读取变量字符串
-	if unicode.IsLetter(rune(l.peek)) {
+	if isIdentRune(l.peek) {
 		var buffer []rune
 		for {
 			buffer = append(buffer, l.peek)
@@ -308,7 +312,7 @@ func (l *Lexer) Scan() (Token, error) {
 			if err := l.Readch(); err == io.EOF {
 				break
 			}
-			if !unicode.IsLetter(rune(l.peek)) {
+			if !isIdentRune(l.peek) {
 				if err := l.UnRead(); err != nil {
 					break
 				}
@@ -322,12 +326,99 @@ func (l *Lexer) Scan() (Token, error) {
 		}
 		l.lexemeStack = append(l.lexemeStack, l.Lexeme)
 
+		switch string(buffer) {
+		case "true", "false":
+			return Token{BOOL, string(buffer) == "true"}, nil
+		case "null":
+			return Token{NULL, nil}, nil
+		}
+
 		token = NewToken(INDENTIFER)
 		token.Raw = string(buffer)
 		return token, nil // 变量字符串
 	}
 
-	return NewToken(EOF), io.EOF
+	// 例如$res.$header.Content-Type中的-, 需要写作$res.$header."Content-Type"
+	return NewToken(ERROR), fmt.Errorf("非法字符%c", l.peek)
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// 判断缓冲区中第n个字节是否为数字(不会移动读取位置)
+func (l *Lexer) peekDigit(n int) bool {
+	chars, err := l.reader.Peek(n + 1)
+	if err != nil {
+		return false
+	}
+	return chars[n] >= '0' && chars[n] <= '9'
+}
+
+// 整数或者浮点数, .后不是数字时不作为小数点, 例如 $json.items.0.id
+func (l *Lexer) ScanNumber() (Token, error) {
+	buffer := []rune{l.peek}
+	isReal := false
+	for {
+		if l.peekDigit(0) {
+			if err := l.Readch(); err != nil {
+				break
+			}
+			buffer = append(buffer, l.peek)
+			continue
+		}
+		if !isReal && l.peekDigit(1) {
+			if ok, _ := l.ReadCharacter('.'); ok {
+				isReal = true
+				buffer = append(buffer, '.')
+				continue
+			}
+		}
+		break
+	}
+	l.Lexeme = string(buffer)
+	l.lexemeStack = append(l.lexemeStack, l.Lexeme)
+
+	if isReal {
+		v, err := strconv.ParseFloat(l.Lexeme, 64)
+		if err != nil {
+			return NewToken(ERROR), err
+		}
+		token := NewToken(REAL)
+		token.Raw = v
+		return token, nil
+	}
+
+	v, err := strconv.Atoi(l.Lexeme)
+	if err != nil {
+		return NewToken(ERROR), err
+	}
+	token := NewToken(NUM)
+	token.Raw = v
+	return token, nil
+}
+
+func (l *Lexer) ScanFunction() (Token, error) {
+	var buffer []rune
+	for {
+		buffer = append(buffer, l.peek)
+		l.Lexeme += string(l.peek)
+
+		if err := l.Readch(); err == io.EOF {
+			break
+		}
+		if !isIdentRune(l.peek) {
+			if err := l.UnRead(); err != nil {
+				break
+			}
+			break
+		}
+	}
+
+	if token, ok := l.keyWords[string(buffer)]; ok {
+		return token, nil
+	}
+	return Token{FUNC, string(buffer)}, nil
 }
 
 func (l *Lexer) ScanKeyword() (KeyWord, error) {
@@ -364,11 +455,15 @@ func (l *Lexer) ScanString() (Token, error) {
 		}
 
 		if l.peek == '\\' {
-			// 转义符
+			// 转义符, 只转义\"与\\, 其他的保留\, 例如正则中的\d
 			if err := l.Readch(); err == io.EOF {
 				break
 			}
 
+			if l.peek != '"' && l.peek != '\\' {
+				buffer = append(buffer, '\\')
+				l.Lexeme += "\\"
+			}
 			buffer = append(buffer, l.peek)
 			l.Lexeme += string(l.peek)
 			continue
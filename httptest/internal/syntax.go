@@ -15,7 +15,7 @@ var ErrAst = errors.New("AST ERROR")
 type SimpleParser struct {
 	Lexer
 
-	nodeStack []*SyntaxNode
+	peeked []Token // 预读的token
 }
 
 type SyntaxNode struct {
@@ -33,110 +33,211 @@ func NewSimpleParser(l Lexer) *SimpleParser {
 	}
 }
 
+// 完整解析后返回io.EOF
 func (s *SimpleParser) Parse() (*SyntaxNode, error) {
-	return s.list()
+	node, err := s.assign()
+	if err != nil {
+		return nil, err
+	}
+
+	if token := s.peek(); token.Tag != EOF {
+		return nil, fmt.Errorf("%w: 多余的token %s", ErrAst, token.String())
+	}
+	return node, io.EOF
 }
 
-// 定义语义规则集，不同的符号有不同的规则
-// 1、. 取值符号，一个表达式中可以存在多个，将 a . b作为新的左参数
-// 2、= 赋值符号，左边的为左参数，右边的为右参数
-func (s *SimpleParser) list() (*SyntaxNode, error) {
-	for {
+func (s *SimpleParser) next() (Token, error) {
+	if len(s.peeked) > 0 {
+		token := s.peeked[0]
+		s.peeked = s.peeked[1:]
+		return token, nil
+	}
+	return s.Scan()
+}
+
+func (s *SimpleParser) peek() Token {
+	if len(s.peeked) == 0 {
 		token, err := s.Scan()
-		if token.Tag == EOF {
-			node := s.nodeStack[len(s.nodeStack)-1]
-			s.nodeStack = s.nodeStack[:len(s.nodeStack)-1]
-			return node, io.EOF
+		if err != nil && err != io.EOF {
+			token = NewToken(ERROR)
 		}
-		if err != nil {
+		s.peeked = append(s.peeked, token)
+	}
+	return s.peeked[0]
+}
+
+// 定义语义规则集，优先级从低到高
+// 1、= 赋值符号，左边的为左参数，右边的为右参数
+// 2、|| &&
+// 3、== != < <= > >=
+// 4、! 取反
+// 5、. 取值符号，一个表达式中可以存在多个，将 a . b作为新的左参数
+func (s *SimpleParser) assign() (*SyntaxNode, error) {
+	left, err := s.or()
+	if err != nil {
+		return nil, err
+	}
+	if s.peek().Tag != ASSIGN_OPERATOR {
+		return left, nil
+	}
+	if _, err := s.next(); err != nil {
+		return nil, err
+	}
+
+	right, err := s.assign()
+	if err != nil {
+		return nil, err
+	}
+	eqNode := s.builderNode(NewToken(ASSIGN_OPERATOR))
+	eqNode.Params = []*SyntaxNode{left, right}
+	return eqNode, nil
+}
+
+func (s *SimpleParser) or() (*SyntaxNode, error) {
+	return s.binary(s.and, OR)
+}
+
+func (s *SimpleParser) and() (*SyntaxNode, error) {
+	return s.binary(s.compare, AND)
+}
+
+func (s *SimpleParser) compare() (*SyntaxNode, error) {
+	return s.binary(s.unary, EQ, NE, LT, LE, GT, GE)
+}
+
+func (s *SimpleParser) binary(operand func() (*SyntaxNode, error), tags ...Tag) (*SyntaxNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token := s.peek()
+		matched := false
+		for _, tag := range tags {
+			if token.Tag == tag {
+				matched = true
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		if _, err := s.next(); err != nil {
 			return nil, err
 		}
 
-		switch token.Tag {
-		case CONTAIN:
-			if err := s.parseContain(); err != nil {
-				return nil, err
-			}
-		case DOT:
-			if err := s.parseDOT(); err != nil {
-				return nil, err
-			}
-		case COMMA, RIGHT_PATERN:
-			// 解析到逗号，当前解析的部分返回
-			node := s.nodeStack[len(s.nodeStack)-1]
-			s.nodeStack = s.nodeStack[:len(s.nodeStack)-1]
-			return node, nil
-		case ASSIGN_OPERATOR:
-			if err := s.parseASSIGN(); err != nil {
-				return nil, err
-			}
-		case ENV, BODY, REQ, RES, JSON, RAW, INDENTIFER, NUM:
-			s.nodeStack = append(s.nodeStack, s.builderNode(token))
+		right, err := operand()
+		if err != nil {
+			return nil, err
 		}
+		node := s.builderNode(token)
+		node.Params = []*SyntaxNode{left, right}
+		left = node
 	}
 }
 
-// 下一个必须为left_pate然后有一个right_pate, 并且两者之间相差两个元素
-func (s *SimpleParser) parseContain() error {
-	nextToken, err := s.Scan()
-	if nextToken.Tag != LEFT_PATREN || err != nil {
-		return fmt.Errorf("%w: contain下一个token必须为LEFT_PATREN", ErrAst)
+func (s *SimpleParser) unary() (*SyntaxNode, error) {
+	if s.peek().Tag != NOT {
+		return s.postfix()
 	}
-
-	param1, err := s.list()
+	token, err := s.next()
 	if err != nil {
-		return fmt.Errorf("%w: 解析失败", ErrAst)
+		return nil, err
 	}
 
-	param2, err := s.list()
+	operand, err := s.unary()
 	if err != nil {
-		return fmt.Errorf("%w: 解析失败", ErrAst)
+		return nil, err
 	}
-
-	call := s.builderNode(NewToken(CONTAIN))
-	call.Params = append(call.Params, param1, param2)
-	s.nodeStack = append(s.nodeStack, call)
-	return nil
+	node := s.builderNode(token)
+	node.Params = []*SyntaxNode{operand}
+	return node, nil
 }
 
-func (s *SimpleParser) parseDOT() error {
-	// 把下一个token取出来作为当前树节点的右节点
-	// stack前面的作为当前树节点的左节点
-	left := s.nodeStack[len(s.nodeStack)-1]
-	s.nodeStack = s.nodeStack[:len(s.nodeStack)-1]
-
-	nextToken, err := s.Scan()
+// 把.后面的token取出来作为当前树节点的右节点, 前面的作为当前树节点的左节点
+func (s *SimpleParser) postfix() (*SyntaxNode, error) {
+	left, err := s.primary()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	node := s.builderNode(NewToken(DOT))
+	for s.peek().Tag == DOT {
+		if _, err := s.next(); err != nil {
+			return nil, err
+		}
+		attrToken, err := s.next()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		attr := s.builderNode(attrToken)
+		if attr == nil || attr.Type == "expression" || attr.Type == "callable" {
+			return nil, fmt.Errorf("%w: .后面不能为%s", ErrAst, attrToken.String())
+		}
 
-	node.Params = []*SyntaxNode{
-		left, s.builderNode(nextToken),
+		node := s.builderNode(NewToken(DOT))
+		node.Params = []*SyntaxNode{left, attr}
+		left = node
 	}
-	s.nodeStack = append(s.nodeStack, node)
-	return nil
+	return left, nil
 }
 
-func (s *SimpleParser) parseASSIGN() error {
-	left := s.nodeStack[len(s.nodeStack)-1]
-	s.nodeStack = s.nodeStack[:len(s.nodeStack)-1]
-
-	right, err := s.list()
+func (s *SimpleParser) primary() (*SyntaxNode, error) {
+	token, err := s.next()
 	if err != nil && err != io.EOF {
-		return err
+		return nil, err
 	}
 
-	eqNode := s.builderNode(NewToken(ASSIGN_OPERATOR))
-	eqNode.Params = []*SyntaxNode{left, right}
-	s.nodeStack = append(s.nodeStack, eqNode)
-	return nil
+	switch token.Tag {
+	case CONTAIN, FUNC:
+		return s.parseCall(token)
+	case LEFT_PATREN:
+		node, err := s.assign()
+		if err != nil {
+			return nil, err
+		}
+		if next, _ := s.next(); next.Tag != RIGHT_PATERN {
+			return nil, fmt.Errorf("%w: 缺少RIGHT_PATERN", ErrAst)
+		}
+		return node, nil
+	case ENV, ROW, BODY, REQ, RES, JSON, RAW, STR, HEADER, STATUS, TIMING, INDENTIFER, NUM, REAL, BOOL, NULL:
+		return s.builderNode(token), nil
+	}
+	return nil, fmt.Errorf("%w: 非预期的token %s", ErrAst, token.String())
+}
+
+// 函数的下一个token必须为left_patren, 参数之间使用逗号分隔, 直到right_patren
+func (s *SimpleParser) parseCall(token Token) (*SyntaxNode, error) {
+	if nextToken, err := s.next(); nextToken.Tag != LEFT_PATREN || err != nil {
+		return nil, fmt.Errorf("%w: %s下一个token必须为LEFT_PATREN", ErrAst, token.String())
+	}
+
+	call := s.builderNode(token)
+	if s.peek().Tag == RIGHT_PATERN {
+		_, err := s.next()
+		return call, err
+	}
+
+	for {
+		param, err := s.assign()
+		if err != nil {
+			return nil, fmt.Errorf("%w: 解析失败", ErrAst)
+		}
+		call.Params = append(call.Params, param)
+
+		next, _ := s.next()
+		switch next.Tag {
+		case COMMA:
+			continue
+		case RIGHT_PATERN:
+			return call, nil
+		}
+		return nil, fmt.Errorf("%w: 函数参数解析失败", ErrAst)
+	}
 }
 
 func (s *SimpleParser) builderNode(token Token) *SyntaxNode {
 	switch token.Tag {
-	case ENV, BODY, REQ, RES:
+	case ENV, ROW, BODY, REQ, RES:
 		return &SyntaxNode{
 			Type: "global",
 			Name: token.String(),
@@ -146,12 +247,17 @@ func (s *SimpleParser) builderNode(token Token) *SyntaxNode {
 			Type: "callable",
 			Name: token.String(),
 		}
-	case JSON, RAW, STR:
+	case FUNC:
+		return &SyntaxNode{
+			Type: "callable",
+			Name: fmt.Sprint(token.Raw),
+		}
+	case JSON, RAW, STR, HEADER, STATUS, TIMING:
 		return &SyntaxNode{
 			Type: "attr",
 			Name: token.String(),
 		}
-	case DOT, EQ, ASSIGN_OPERATOR:
+	case DOT, EQ, NE, LT, LE, GT, GE, AND, OR, NOT, ASSIGN_OPERATOR:
 		return &SyntaxNode{
 			Type: "expression",
 			Name: token.String(),
@@ -162,7 +268,7 @@ func (s *SimpleParser) builderNode(token Token) *SyntaxNode {
 			Name:  token.String(),
 			Value: token.Raw,
 		}
-	case NUM:
+	case NUM, REAL, BOOL, NULL:
 		return &SyntaxNode{
 			Type:  "literial",
 			Name:  token.String(),
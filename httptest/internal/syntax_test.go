@@ -144,6 +144,57 @@ func TestSimpleParse(t *testing.T) {
 }
 			`,
 		},
+		{
+			source: `$res.$status == 200 && !$env.skip`,
+			target: `
+{
+	"type": "expression",
+	"name": "&&",
+	"params": [
+		{
+			"type": "expression",
+			"name": "==",
+			"params": [
+				{
+					"type": "expression",
+					"name": ".",
+					"params": [
+						{
+							"type": "global",
+							"name": "$res"
+						}, {
+							"type": "attr",
+							"name": "$status"
+						}
+					]
+				}, {
+					"type": "literial",
+					"name": "num"
+				}
+			]
+		},
+		{
+			"type": "expression",
+			"name": "!",
+			"params": [
+				{
+					"type": "expression",
+					"name": ".",
+					"params": [
+						{
+							"type": "global",
+							"name": "$env"
+						}, {
+							"type": "variable",
+							"name": "indentifer"
+						}
+					]
+				}
+			]
+		}
+	]
+}`,
+		},
 	}
 
 	for _, item := range pairs {
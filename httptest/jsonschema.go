@@ -0,0 +1,434 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+////////////////////
+// json schema(openapi中使用的子集)
+// 1、校验响应体是否满足schema
+// 2、根据schema生成示例数据
+////////////////////
+
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 SchemaType             `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Nullable             bool                   `json:"nullable,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Example              interface{}            `json:"example,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AllOf                []*JSONSchema          `json:"allOf,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+
+	deny bool // false schema, 任何值都不满足
+}
+
+type jsonSchemaAlias JSONSchema
+
+// 兼容布尔类型的schema, 例如additionalProperties: false
+func (s *JSONSchema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = JSONSchema{}
+		return nil
+	case "false":
+		*s = JSONSchema{deny: true}
+		return nil
+	}
+	return json.Unmarshal(data, (*jsonSchemaAlias)(s))
+}
+
+func (s *JSONSchema) MarshalJSON() ([]byte, error) {
+	if s.deny {
+		return []byte("false"), nil
+	}
+	return json.Marshal((*jsonSchemaAlias)(s))
+}
+
+// openapi3.0中为字符串, 3.1中可以为数组
+type SchemaType []string
+
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaType{single}
+		return nil
+	}
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*t = multi
+	return nil
+}
+
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t SchemaType) Has(name string) bool {
+	for _, item := range t {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 校验value(json解析后的数据)是否满足schema, 不支持$ref
+func (s *JSONSchema) Validate(value interface{}) error {
+	return validateSchema(s, value, nil)
+}
+
+// 最多报告的错误数量
+const maxSchemaErrors = 10
+
+type schemaValidator struct {
+	resolve func(ref string) *JSONSchema
+	errs    []string
+}
+
+// resolve用于解析$ref, 为nil时忽略带$ref的schema
+func validateSchema(s *JSONSchema, value interface{}, resolve func(ref string) *JSONSchema) error {
+	errs := schemaErrors(s, value, resolve)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
+// 每个错误的格式为: 路径: 原因
+func schemaErrors(s *JSONSchema, value interface{}, resolve func(ref string) *JSONSchema) []string {
+	v := &schemaValidator{resolve: resolve}
+	v.validate(s, value, "$")
+	if len(v.errs) > maxSchemaErrors {
+		v.errs = append(v.errs[:maxSchemaErrors], fmt.Sprintf("...等%d个错误", len(v.errs)))
+	}
+	return v.errs
+}
+
+func (v *schemaValidator) fail(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *schemaValidator) deref(s *JSONSchema) *JSONSchema {
+	for depth := 0; s != nil && s.Ref != ""; depth++ {
+		if v.resolve == nil || depth > 32 {
+			return nil
+		}
+		s = v.resolve(s.Ref)
+	}
+	return s
+}
+
+// 不修改v.errs的情况下判断是否满足
+func (v *schemaValidator) match(s *JSONSchema, value interface{}) bool {
+	sub := &schemaValidator{resolve: v.resolve}
+	sub.validate(s, value, "$")
+	return len(sub.errs) == 0
+}
+
+func (v *schemaValidator) validate(s *JSONSchema, value interface{}, path string) {
+	s = v.deref(s)
+	if s == nil {
+		return
+	}
+	if s.deny {
+		v.fail(path, "不允许出现")
+		return
+	}
+
+	for _, item := range s.AllOf {
+		v.validate(item, value, path)
+	}
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, item := range s.AnyOf {
+			if v.match(item, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "不满足anyOf中的任何一个schema")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		count := 0
+		for _, item := range s.OneOf {
+			if v.match(item, value) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "需要恰好满足oneOf中的一个schema, 实际满足%d个", count)
+		}
+	}
+
+	if value == nil {
+		if len(s.Type) > 0 && !s.Nullable && !s.Type.Has("null") {
+			v.fail(path, "期望%s, 实际为null", strings.Join(s.Type, "|"))
+		}
+		return
+	}
+	if len(s.Type) > 0 && !schemaTypeMatch(s.Type, value) {
+		v.fail(path, "期望%s, 实际为%s %s", strings.Join(s.Type, "|"), jsonTypeName(value), truncateText(compactJSON(value)))
+		return
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, item := range s.Enum {
+			if reflect.DeepEqual(normalizeJSON(item), value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "%v不在枚举%v中", value, s.Enum)
+		}
+	}
+
+	switch val := value.(type) {
+	case string:
+		v.validateString(s, val, path)
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			v.fail(path, "%v小于最小值%v", val, *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			v.fail(path, "%v大于最大值%v", val, *s.Maximum)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			v.fail(path, "元素数量%d小于%d", len(val), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			v.fail(path, "元素数量%d大于%d", len(val), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := val[key]; !ok {
+				v.fail(path, "缺少必填字段%s", key)
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := s.Properties[key]; ok {
+				v.validate(prop, val[key], path+"."+key)
+			} else if s.AdditionalProperties != nil {
+				v.validate(s.AdditionalProperties, val[key], path+"."+key)
+			}
+		}
+	}
+}
+
+var (
+	schemaUUIDReg  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	schemaEmailReg = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+)
+
+func (v *schemaValidator) validateString(s *JSONSchema, val string, path string) {
+	length := utf8.RuneCountInString(val)
+	if s.MinLength != nil && length < *s.MinLength {
+		v.fail(path, "长度%d小于%d", length, *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		v.fail(path, "长度%d大于%d", length, *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		if reg, err := regexp.Compile(s.Pattern); err == nil && !reg.MatchString(val) {
+			v.fail(path, "%q不匹配%s", val, s.Pattern)
+		}
+	}
+
+	valid := true
+	switch s.Format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, val)
+		valid = err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", val)
+		valid = err == nil
+	case "uuid":
+		valid = schemaUUIDReg.MatchString(val)
+	case "email":
+		valid = schemaEmailReg.MatchString(val)
+	}
+	if !valid {
+		v.fail(path, "%q不是合法的%s", val, s.Format)
+	}
+}
+
+func schemaTypeMatch(types SchemaType, value interface{}) bool {
+	actual := jsonTypeName(value)
+	for _, item := range types {
+		if item == actual || (item == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonTypeName(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// 统一为json.Unmarshal的结果, 例如int转为float64
+func normalizeJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var res interface{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return value
+	}
+	return res
+}
+
+// 根据schema生成示例数据, 优先使用example、default、enum
+func schemaExample(s *JSONSchema, resolve func(ref string) *JSONSchema) interface{} {
+	return exampleValue(s, resolve, map[string]bool{})
+}
+
+func exampleValue(s *JSONSchema, resolve func(ref string) *JSONSchema, seen map[string]bool) interface{} {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		// 递归引用时停止展开
+		if seen[s.Ref] || resolve == nil {
+			return nil
+		}
+		seen[s.Ref] = true
+		defer delete(seen, s.Ref)
+		return exampleValue(resolve(s.Ref), resolve, seen)
+	}
+
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.AllOf) > 0:
+		res := map[string]interface{}{}
+		for _, item := range s.AllOf {
+			if obj, ok := exampleValue(item, resolve, seen).(map[string]interface{}); ok {
+				for key, val := range obj {
+					res[key] = val
+				}
+			}
+		}
+		return res
+	case len(s.OneOf) > 0:
+		return exampleValue(s.OneOf[0], resolve, seen)
+	case len(s.AnyOf) > 0:
+		return exampleValue(s.AnyOf[0], resolve, seen)
+	}
+
+	typ := ""
+	for _, item := range s.Type {
+		if item != "null" {
+			typ = item
+			break
+		}
+	}
+	if typ == "" && s.Properties != nil {
+		typ = "object"
+	}
+
+	switch typ {
+	case "object":
+		res := map[string]interface{}{}
+		for key, prop := range s.Properties {
+			res[key] = exampleValue(prop, resolve, seen)
+		}
+		return res
+	case "array":
+		if s.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{exampleValue(s.Items, resolve, seen)}
+	case "integer":
+		if s.Minimum != nil {
+			return math.Ceil(*s.Minimum)
+		}
+		return 0
+	case "number":
+		if s.Minimum != nil {
+			return *s.Minimum
+		}
+		return 0
+	case "boolean":
+		return true
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "2006-01-02T15:04:05Z"
+		case "date":
+			return "2006-01-02"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "uri", "url":
+			return "https://example.com"
+		}
+		return "string"
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+package httptest
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+////////////////////
+// 压测: 并发地重复执行spec, 统计延迟、吞吐量以及失败的原因
+// 1、每个worker使用单独的环境变量, setup、before_all只执行一次, 之后重复执行item(包括before_each、after_each)
+// 2、停止时执行after_all、teardown, 钩子中的请求不计入统计
+// 3、环境变量worker为worker的编号, iteration为当前的执行次数, 都从1开始
+// 4、达到请求数或者持续时间时停止, RPS限制每秒发出的请求数, RampUp时从0线性增加到RPS
+////////////////////
+
+const loadHistogramBuckets = 10
+
+var (
+	errLoadStopped  = errors.New("压测已停止")
+	errLoadFailNow  = errors.New("fail now")
+	loadTitleSuffix = regexp.MustCompile(` #\d+$`)
+)
+
+type LoadOption struct {
+	Concurrency int                    // 并发的worker数量, 默认为1
+	Requests    int                    // 总的请求数, 与Duration同时设置时先达到的为准
+	Duration    time.Duration          // 持续时间
+	RPS         float64                // 每秒发出的请求数, 为0时不限制
+	RampUp      time.Duration          // 在这段时间内从0线性增加到RPS
+	Env         map[string]interface{} // 每个worker的初始环境变量
+}
+
+// 压测结果, 耗时的单位为毫秒
+type LoadReport struct {
+	Requests   int     `json:"requests"`   // 得到响应以及请求失败的数量
+	Failed     int     `json:"failed"`     // 请求失败或者断言失败的数量
+	Duration   float64 `json:"duration"`   // 总耗时
+	Throughput float64 `json:"throughput"` // 每秒完成的请求数
+
+	Fastest float64 `json:"fastest"`
+	Average float64 `json:"average"`
+	Slowest float64 `json:"slowest"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+
+	Histogram []*LoadBucket `json:"histogram"`
+	Statuses  map[int]int   `json:"statuses"` // 每个状态码的数量
+	Errors    []*LoadError  `json:"errors"`   // 失败的原因, 按照次数从多到少排序
+}
+
+// 延迟分布的一段, Mark为这一段的上限
+type LoadBucket struct {
+	Mark      float64 `json:"mark"`
+	Count     int     `json:"count"`
+	Frequency float64 `json:"frequency"`
+}
+
+type LoadError struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+type loadTest struct {
+	runner  *Runner
+	option  LoadOption
+	matcher tagMatcher
+	base    http.RoundTripper
+
+	start time.Time
+	done  chan struct{}
+	once  sync.Once
+
+	mu        sync.Mutex
+	sent      int
+	err       error
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    map[string]int
+}
+
+// 按照option压测, worker的before_all失败、spec错误时停止并返回错误, 同时返回已经完成的请求的统计
+func (r *Runner) Load(option *LoadOption) (*LoadReport, error) {
+	l := &loadTest{runner: r, option: *option, done: make(chan struct{}), statuses: map[int]int{}, errors: map[string]int{}}
+	if l.option.Concurrency <= 0 {
+		l.option.Concurrency = 1
+	}
+	if l.option.Requests <= 0 && l.option.Duration <= 0 {
+		return nil, errors.New("需要指定请求数或者持续时间")
+	}
+	if l.option.RampUp > 0 && l.option.RPS <= 0 {
+		return nil, errors.New("ramp-up需要指定rps")
+	}
+	matcher, err := r.prepare()
+	if err != nil {
+		return nil, err
+	}
+	l.matcher = matcher
+	l.base = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: l.option.Concurrency,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: r.insecure()},
+	}
+
+	l.start = time.Now()
+	if l.option.Duration > 0 {
+		timer := time.AfterFunc(l.option.Duration, l.stop)
+		defer timer.Stop()
+	}
+	var wg sync.WaitGroup
+	for i := 1; i <= l.option.Concurrency; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			l.work(id)
+		}(i)
+	}
+	wg.Wait()
+	return l.report(time.Since(l.start)), l.err
+}
+
+// 存在跳过证书校验的item时, 压测的所有请求都跳过
+func (r *Runner) insecure() bool {
+	for _, item := range r.items {
+		if item.Insecure {
+			return true
+		}
+	}
+	for _, items := range r.hooks {
+		for _, item := range items {
+			if item.Insecure {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l *loadTest) work(id int) {
+	transport := &loadTransport{load: l, base: l.base}
+	ctx := NewHttpContext()
+	for key, value := range l.option.Env {
+		ctx.Setenv(key, value)
+	}
+	ctx.Setenv("worker", id)
+	ctx.client = &http.Client{Transport: transport}
+
+	defer loadCall(func(t TestingT) error {
+		return l.runner.finish(t, ctx)
+	})
+	failures, err := loadCall(func(t TestingT) error {
+		return l.runner.begin(t, ctx)
+	})
+	if err == nil && len(failures) > 0 {
+		err = errors.New(failures[0])
+	}
+	if err != nil {
+		l.fail(fmt.Errorf("worker %d: %w", id, err))
+		return
+	}
+	// 钩子中的请求不计入统计
+	ctx.results = nil
+
+	for i := 1; !l.stopped() && !transport.stopped; i++ {
+		ctx.Setenv("iteration", i)
+		transport.gated, transport.sent = true, 0
+		_, err := loadCall(func(t TestingT) error {
+			return l.runner.runItems(t, ctx, l.matcher)
+		})
+		transport.gated = false
+		l.record(ctx.results)
+		ctx.results = nil
+
+		if err != nil {
+			l.fail(fmt.Errorf("worker %d: %w", id, err))
+			return
+		}
+		if transport.sent == 0 && !l.stopped() && !transport.stopped {
+			l.fail(errors.New("spec中没有需要执行的请求"))
+			return
+		}
+	}
+}
+
+// 执行f, FailNow、DoParser失败时中止, 返回断言失败的说明以及f的错误
+func loadCall(f func(t TestingT) error) (failures []string, err error) {
+	t := &loadT{}
+	defer func() {
+		if r := recover(); r != nil {
+			if msg, ok := r.(string); ok {
+				t.Errorf("%s", msg)
+			} else if r != errLoadFailNow {
+				panic(r)
+			}
+		}
+		failures = t.failures
+	}()
+	err = f(t)
+	return
+}
+
+// 第一个错误生效, 之后停止全部worker
+func (l *loadTest) fail(err error) {
+	l.mu.Lock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.mu.Unlock()
+	l.stop()
+}
+
+func (l *loadTest) stop() {
+	l.once.Do(func() { close(l.done) })
+}
+
+func (l *loadTest) stopped() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// 发送请求之前调用, 达到请求数或者已经停止时返回错误, 限制速率时等待到该请求的发送时间
+func (l *loadTest) acquire() error {
+	l.mu.Lock()
+	if l.stopped() {
+		l.mu.Unlock()
+		return errLoadStopped
+	}
+	// 已经分配的请求仍然发送, 不关闭done
+	if l.option.Requests > 0 && l.sent >= l.option.Requests {
+		l.mu.Unlock()
+		return errLoadStopped
+	}
+	n := l.sent
+	l.sent++
+	l.mu.Unlock()
+
+	if l.option.RPS <= 0 {
+		return nil
+	}
+	wait := time.Until(l.start.Add(l.offset(n)))
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-l.done:
+		return errLoadStopped
+	}
+}
+
+// 第n个请求(从0开始)相对于开始的发送时间, RampUp期间速率线性增加, 之后为RPS
+func (l *loadTest) offset(n int) time.Duration {
+	rps, ramp := l.option.RPS, l.option.RampUp.Seconds()
+	rampRequests := rps * ramp / 2
+	var seconds float64
+	if float64(n) < rampRequests {
+		seconds = math.Sqrt(2 * ramp * float64(n) / rps)
+	} else {
+		seconds = ramp + (float64(n)-rampRequests)/rps
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (l *loadTest) record(results []*StepResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, result := range results {
+		if result.Skipped || result.Timing == nil {
+			continue
+		}
+		l.latencies = append(l.latencies, result.Timing.Total)
+		l.statuses[result.Status]++
+		if result.Failed {
+			l.errors[loadFailure(result)]++
+		}
+	}
+}
+
+func (l *loadTest) recordError(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors["请求失败: "+err.Error()]++
+}
+
+// 失败原因的第一行, 循环执行的item按照名称合并
+func loadFailure(result *StepResult) string {
+	title := loadTitleSuffix.ReplaceAllString(result.Title, "")
+	reason := strings.TrimSuffix(strings.TrimSpace(strings.SplitN(result.Error, "\n", 2)[0]), ":")
+	if reason == "" {
+		reason = "失败"
+	}
+	return title + ": " + reason
+}
+
+func (l *loadTest) report(elapsed time.Duration) *LoadReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := &LoadReport{Duration: milliseconds(elapsed), Statuses: l.statuses, Histogram: []*LoadBucket{}, Errors: []*LoadError{}}
+	for reason, count := range l.errors {
+		report.Failed += count
+		report.Errors = append(report.Errors, &LoadError{Reason: reason, Count: count})
+	}
+	sort.Slice(report.Errors, func(i, j int) bool {
+		if report.Errors[i].Count != report.Errors[j].Count {
+			return report.Errors[i].Count > report.Errors[j].Count
+		}
+		return report.Errors[i].Reason < report.Errors[j].Reason
+	})
+	report.Requests = len(l.latencies)
+	for _, err := range report.Errors {
+		if strings.HasPrefix(err.Reason, "请求失败: ") {
+			report.Requests += err.Count
+		}
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	}
+	if len(l.latencies) == 0 {
+		return report
+	}
+
+	latencies := append([]time.Duration{}, l.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	report.Fastest = milliseconds(latencies[0])
+	report.Slowest = milliseconds(latencies[len(latencies)-1])
+	report.Average = milliseconds(total / time.Duration(len(latencies)))
+	report.P50 = milliseconds(percentile(latencies, 50))
+	report.P90 = milliseconds(percentile(latencies, 90))
+	report.P99 = milliseconds(percentile(latencies, 99))
+	report.Histogram = histogram(latencies)
+	return report
+}
+
+// latencies已经排序
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	index := int(math.Ceil(p/100*float64(len(latencies)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return latencies[index]
+}
+
+// 最快到最慢之间平均分为10段, latencies已经排序
+func histogram(latencies []time.Duration) []*LoadBucket {
+	fastest, slowest := latencies[0], latencies[len(latencies)-1]
+	size := (slowest - fastest) / loadHistogramBuckets
+	buckets := []*LoadBucket{}
+	for i := 1; i <= loadHistogramBuckets; i++ {
+		mark := fastest + size*time.Duration(i)
+		if i == loadHistogramBuckets || size == 0 {
+			mark = slowest
+		}
+		buckets = append(buckets, &LoadBucket{Mark: milliseconds(mark)})
+		if size == 0 {
+			break
+		}
+	}
+	index := 0
+	for _, latency := range latencies {
+		for latency > fromMilliseconds(buckets[index].Mark) && index < len(buckets)-1 {
+			index++
+		}
+		buckets[index].Count++
+	}
+	for _, bucket := range buckets {
+		bucket.Frequency = float64(bucket.Count) / float64(len(latencies))
+	}
+	return buckets
+}
+
+// 文本格式的结果
+func (r *LoadReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "请求: %d, 失败: %d, 耗时: %.2fs, 吞吐量: %.1f req/s\n", r.Requests, r.Failed, r.Duration/1000, r.Throughput)
+	if len(r.Histogram) > 0 {
+		fmt.Fprintf(&b, "延迟: 最快 %.1fms, 平均 %.1fms, 最慢 %.1fms\n", r.Fastest, r.Average, r.Slowest)
+		fmt.Fprintf(&b, "  p50 %.1fms, p90 %.1fms, p99 %.1fms\n", r.P50, r.P90, r.P99)
+		b.WriteString("延迟分布:\n")
+		max := 0
+		for _, bucket := range r.Histogram {
+			if bucket.Count > max {
+				max = bucket.Count
+			}
+		}
+		for _, bucket := range r.Histogram {
+			width := 0
+			if max > 0 {
+				width = bucket.Count * 40 / max
+			}
+			fmt.Fprintf(&b, "  %8.1fms [%d]\t|%s\n", bucket.Mark, bucket.Count, strings.Repeat("■", width))
+		}
+	}
+	if len(r.Statuses) > 0 {
+		b.WriteString("状态码:\n")
+		codes := make([]int, 0, len(r.Statuses))
+		for code := range r.Statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(&b, "  [%d] %d\n", code, r.Statuses[code])
+		}
+	}
+	if len(r.Errors) > 0 {
+		b.WriteString("失败:\n")
+		for _, err := range r.Errors {
+			fmt.Fprintf(&b, "  [%d] %s\n", err.Count, err.Reason)
+		}
+	}
+	return b.String()
+}
+
+// worker的请求经过该transport, 执行item时控制速率并记录请求失败
+type loadTransport struct {
+	load    *loadTest
+	base    http.RoundTripper
+	gated   bool // 执行item时为true, 钩子中的请求不受限制
+	sent    int  // 当前执行发出的请求数
+	stopped bool // 已经达到请求数或者停止
+}
+
+func (t *loadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.gated {
+		return t.base.RoundTrip(req)
+	}
+	if err := t.load.acquire(); err != nil {
+		t.stopped = true
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	t.sent++
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.load.recordError(err)
+	}
+	return resp, err
+}
+
+// 压测时不输出断言失败, 失败的原因从执行记录中统计
+type loadT struct {
+	failures []string
+}
+
+func (t *loadT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *loadT) FailNow() {
+	panic(errLoadFailNow)
+}
+
+func (t *loadT) Log(args ...interface{}) {}
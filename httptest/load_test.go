@@ -0,0 +1,124 @@
+package httptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 每个worker登录一次, 查询订单时每第3次返回500
+func newLoadServer(mu *sync.Mutex, calls map[string]int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.Method+" "+r.URL.Path]++
+		count := calls[r.Method+" "+r.URL.Path]
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/api/login":
+			body, _ := json.Marshal(map[string]interface{}{"token": "worker" + r.URL.Query().Get("worker")})
+			w.Write(body)
+		case r.Header.Get("Authorization") != "" && r.Header.Get("Authorization") != "bearer worker"+r.URL.Query().Get("worker"):
+			w.WriteHeader(401)
+		case r.Method == "GET" && count%3 == 0:
+			w.WriteHeader(500)
+		}
+	}))
+}
+
+func TestRunnerLoad(t *testing.T) {
+	mu, calls := &sync.Mutex{}, map[string]int{}
+	ts := newLoadServer(mu, calls)
+	defer ts.Close()
+
+	specInfo, err := NewBasicParserSpecInfoFromFile("./testdata/load/order.yaml", nil)
+	require.Nil(t, err)
+	report, err := specInfo.Runner().Load(&LoadOption{
+		Concurrency: 3,
+		Requests:    30,
+		Env:         map[string]interface{}{"baseUrl": ts.URL},
+	})
+	require.Nil(t, err)
+
+	// 登录在before_all中, 不计入请求数
+	assert.Equal(t, 3, calls["POST /api/login"])
+	assert.Equal(t, 30, calls["POST /api/orders"]+calls["GET /api/orders"])
+	assert.Equal(t, 30, report.Requests)
+	assert.Equal(t, calls["GET /api/orders"]/3, report.Failed)
+	assert.Equal(t, report.Requests-report.Failed, report.Statuses[200])
+	assert.Equal(t, report.Failed, report.Statuses[500])
+	require.Len(t, report.Errors, 1)
+	assert.True(t, strings.HasPrefix(report.Errors[0].Reason, "查询订单: $res.$status == 200 不成立"), report.Errors[0].Reason)
+
+	assert.True(t, report.Fastest <= report.P50 && report.P50 <= report.P90 && report.P90 <= report.P99 && report.P99 <= report.Slowest)
+	count := 0
+	for _, bucket := range report.Histogram {
+		count += bucket.Count
+	}
+	assert.Equal(t, 30, count)
+	assert.Contains(t, report.String(), "请求: 30")
+}
+
+func TestRunnerLoadRate(t *testing.T) {
+	mu, calls := &sync.Mutex{}, map[string]int{}
+	ts := newLoadServer(mu, calls)
+	defer ts.Close()
+
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/load/order.yaml", nil)
+	require.Nil(t, err)
+	start := time.Now()
+	report, err := specInfo.Runner().Only("创建订单").Load(&LoadOption{
+		Concurrency: 2,
+		Requests:    11,
+		RPS:         50,
+		Env:         map[string]interface{}{"baseUrl": ts.URL},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 11, report.Requests)
+	assert.Equal(t, 0, calls["GET /api/orders"])
+	// 第11个请求在200ms之后发出
+	assert.True(t, time.Since(start) >= 200*time.Millisecond)
+
+	report, err = specInfo.Runner().Only("创建订单").Load(&LoadOption{
+		Duration: 100 * time.Millisecond,
+		Env:      map[string]interface{}{"baseUrl": ts.URL},
+	})
+	require.Nil(t, err)
+	assert.True(t, report.Requests > 0)
+}
+
+func TestLoadOffset(t *testing.T) {
+	l := &loadTest{option: LoadOption{RPS: 10, RampUp: 2 * time.Second}}
+	// 前2秒共10个请求, 之后每秒10个
+	assert.Equal(t, time.Duration(0), l.offset(0))
+	assert.Equal(t, 1414*time.Millisecond, l.offset(5).Round(time.Millisecond))
+	assert.Equal(t, 2*time.Second, l.offset(10).Round(time.Millisecond))
+	assert.Equal(t, 3*time.Second, l.offset(20).Round(time.Millisecond))
+
+	l.option.RampUp = 0
+	assert.Equal(t, 500*time.Millisecond, l.offset(5).Round(time.Millisecond))
+}
+
+func TestRunnerLoadError(t *testing.T) {
+	specInfo, err := NewBasicSpecInfo([]byte(`[{"name": "event", "event": ["$env.a = 1"]}]`), nil)
+	require.Nil(t, err)
+	_, err = specInfo.Runner().Load(&LoadOption{})
+	assert.Error(t, err)
+	_, err = specInfo.Runner().Load(&LoadOption{Requests: 1, RampUp: time.Second})
+	assert.Error(t, err)
+	_, err = specInfo.Runner().Load(&LoadOption{Requests: 1})
+	assert.EqualError(t, err, "spec中没有需要执行的请求")
+
+	// before_all失败时停止
+	specInfo, err = NewBasicSpecInfo([]byte(`{"before_all": [{"name": "登录", "url": "http://127.0.0.1:1/login"}], "items": [{"name": "a", "url": "http://127.0.0.1:1"}]}`), nil)
+	require.Nil(t, err)
+	_, err = specInfo.Runner().Load(&LoadOption{Requests: 1, Concurrency: 2})
+	assert.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package httptest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/wwqdrh/easytest/httptest/internal"
+)
+
+////////////////////
+// item的循环执行, 每次执行作为单独的执行记录, 标题为"名称 #次数"
+// 1、data: 按照数据逐行执行, 当前行通过{{ row.x }}、$row.x读取
+// 2、foreach: 遍历表达式返回的数组, 当前元素为row.item, 下标为row.index
+// 3、repeat: 执行指定次数, 下标为row.index
+// 4、while: 先执行一次, 之后表达式成立时继续执行, 例如按照next_cursor翻页
+////////////////////
+
+// while没有设置repeat时的最大执行次数, 超过时认为是死循环
+const maxWhileIterations = 100
+
+// 按照data、foreach、repeat、while执行item, 都没有设置时执行一次
+func (item *BasicItem) iterate(ctx *HttpContext, f func(title string) error) error {
+	rows, err := item.loopRows(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", item.Name, err)
+	}
+	if rows == nil && strings.TrimSpace(item.While) == "" {
+		return f(item.Name)
+	}
+
+	defer func() { ctx.row = nil }()
+	if strings.TrimSpace(item.While) == "" {
+		for i, row := range rows {
+			ctx.row = row
+			if err := f(fmt.Sprintf("%s #%d", item.Name, i+1)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	limit := item.Repeat
+	if limit <= 0 {
+		limit = maxWhileIterations
+	}
+	for i := 0; ; i++ {
+		ctx.row = map[string]interface{}{"index": i}
+		if err := f(fmt.Sprintf("%s #%d", item.Name, i+1)); err != nil {
+			return err
+		}
+		val, err := internal.DoCaller(NewIHTTPCtx(ctx), item.While)
+		if err != nil {
+			return fmt.Errorf("%s: while表达式%s: %w", item.Name, item.While, err)
+		}
+		if !internal.Truthy(val) {
+			return nil
+		}
+		if i+1 >= limit {
+			if item.Repeat > 0 {
+				return nil
+			}
+			return fmt.Errorf("%s: 执行%d次之后while表达式仍然成立", item.Name, limit)
+		}
+	}
+}
+
+// 设置了data、foreach、repeat、while中的一个
+func (item *BasicItem) looping() bool {
+	return item.Data != nil || item.Foreach != "" || item.Repeat > 0 || item.While != ""
+}
+
+// 每次执行时的row, 没有data、foreach、repeat时为nil
+func (item *BasicItem) loopRows(ctx *HttpContext) ([]map[string]interface{}, error) {
+	foreach := strings.TrimSpace(item.Foreach)
+	count := 0
+	for _, set := range []bool{item.Data != nil, foreach != "", item.Repeat > 0 && item.While == ""} {
+		if set {
+			count++
+		}
+	}
+	if count > 1 {
+		return nil, errors.New("data、foreach、repeat只能设置一个")
+	}
+	if foreach != "" && item.While != "" {
+		return nil, errors.New("foreach不能与while一起使用")
+	}
+
+	switch {
+	case item.Data != nil:
+		return item.Data.Rows(item.dir)
+	case foreach != "":
+		val, err := internal.DoCaller(NewIHTTPCtx(ctx), foreach)
+		if err != nil {
+			return nil, fmt.Errorf("foreach表达式%s: %w", foreach, err)
+		}
+		elems, ok := toSlice(val)
+		if !ok {
+			return nil, fmt.Errorf("foreach表达式%s需要返回数组, 实际为%T", foreach, val)
+		}
+		rows := make([]map[string]interface{}, 0, len(elems))
+		for i, elem := range elems {
+			rows = append(rows, map[string]interface{}{"item": elem, "index": i})
+		}
+		return rows, nil
+	case item.Repeat > 0 && item.While == "":
+		rows := make([]map[string]interface{}, 0, item.Repeat)
+		for i := 0; i < item.Repeat; i++ {
+			rows = append(rows, map[string]interface{}{"index": i})
+		}
+		return rows, nil
+	}
+	return nil, nil
+}
+
+// 环境变量中可能是Go代码设置的[]string等切片, nil视为空数组
+func toSlice(val interface{}) ([]interface{}, bool) {
+	if val == nil {
+		return []interface{}{}, true
+	}
+	if elems, ok := val.([]interface{}); ok {
+		return elems, true
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elems := make([]interface{}, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elems = append(elems, rv.Index(i).Interface())
+	}
+	return elems, true
+}
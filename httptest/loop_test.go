@@ -0,0 +1,86 @@
+package httptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPageServer(calls *[]string) *httptest.Server {
+	pages := map[string]string{"": "a", "a": "b", "b": ""}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls = append(*calls, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Path != "/api/items" {
+			return
+		}
+		next, ok := pages[r.URL.Query().Get("cursor")]
+		if !ok {
+			w.WriteHeader(400)
+			return
+		}
+		body, _ := json.Marshal(map[string]interface{}{"next_cursor": next})
+		w.Write(body)
+	}))
+}
+
+func TestRunnerLoop(t *testing.T) {
+	calls := []string{}
+	ts := newPageServer(&calls)
+	defer ts.Close()
+
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/loop/pagination.yaml", nil)
+	require.Nil(t, err)
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	ctx.Setenv("cursor", "")
+	ctx.Setenv("createdItems", []interface{}{
+		map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2},
+	})
+	require.Nil(t, specInfo.Runner().Run(t, ctx))
+
+	assert.Equal(t, []string{
+		"GET /api/items?cursor=", "GET /api/items?cursor=a", "GET /api/items?cursor=b",
+		"DELETE /api/items/1", "DELETE /api/items/2",
+		"POST /api/refresh?index=0", "POST /api/refresh?index=1",
+	}, calls)
+	titles := []string{}
+	for _, result := range ctx.Results() {
+		titles = append(titles, result.Title)
+	}
+	assert.Equal(t, []string{"翻页 #1", "翻页 #2", "翻页 #3", "删除 #1", "删除 #2", "刷新 #1", "刷新 #2"}, titles)
+}
+
+func TestIterateLimit(t *testing.T) {
+	ctx := NewHttpContext()
+	ctx.Setenv("ids", []string{"a", "b"})
+
+	// Go代码设置的切片
+	items := []string{}
+	item := &BasicItem{Name: "foreach", Foreach: "$env.ids"}
+	require.Nil(t, item.iterate(ctx, func(title string) error {
+		items = append(items, ctx.Render("{{row.index}}:{{row.item}}"))
+		return nil
+	}))
+	assert.Equal(t, []string{"0:a", "1:b"}, items)
+	assert.Nil(t, ctx.row)
+
+	// while与repeat一起使用时repeat为最大次数
+	count := 0
+	item = &BasicItem{Name: "while", While: "true", Repeat: 3}
+	require.Nil(t, item.iterate(ctx, func(string) error { count++; return nil }))
+	assert.Equal(t, 3, count)
+
+	count = 0
+	item = &BasicItem{Name: "while", While: "true"}
+	assert.NotNil(t, item.iterate(ctx, func(string) error { count++; return nil }))
+	assert.Equal(t, maxWhileIterations, count)
+
+	item = &BasicItem{Name: "foreach", Foreach: "$env.ids", Repeat: 2}
+	assert.NotNil(t, item.iterate(ctx, func(string) error { return nil }))
+	item = &BasicItem{Name: "foreach", Foreach: "1"}
+	assert.NotNil(t, item.iterate(ctx, func(string) error { return nil }))
+}
@@ -0,0 +1,409 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+////////////////////
+// 根据spec生成mock server
+// 1、按照method与url中的路径匹配item, 路径中的{{id}}匹配任意一段, 通过{{ params.id }}读取
+// 2、example.match限制请求的header、query以及json请求体(包含这些字段即可), 多个item匹配时参数少、条件多的优先
+// 3、返回example中的状态码、header与body, 其中的{{ }}根据请求渲染: method、path、params.x、query.x、header.x、body.a.b
+// 4、没有example的item返回200与空的body, 没有匹配的请求返回404以及最接近的路由
+////////////////////
+
+const mockClosestRoutes = 3
+
+// 去掉url开头的{{baseUrl}}
+var mockBaseReg = regexp.MustCompile(`^{{[^{}]*}}`)
+
+// item的示例响应
+type MockResponse struct {
+	Status int               `json:"status,omitempty"` // 默认为200
+	Header map[string]string `json:"header,omitempty"`
+	Body   interface{}       `json:"body,omitempty"`  // 字符串原样返回, 对象、数组返回json
+	Match  *MockMatch        `json:"match,omitempty"` // 请求满足这些条件时才使用该item
+}
+
+type MockMatch struct {
+	Header map[string]string `json:"header,omitempty"`
+	Query  map[string]string `json:"query,omitempty"`
+	Body   interface{}       `json:"body,omitempty"` // 对象时请求体包含这些字段, 字符串时与请求体相同
+}
+
+type mockRoute struct {
+	item     *BasicItem
+	method   string
+	path     string
+	reg      *regexp.Regexp
+	params   []string
+	priority int // 在spec中的顺序
+}
+
+type MockHandler struct {
+	routes []*mockRoute
+}
+
+// 根据spec中有url的item(包括钩子)生成路由
+func NewMockHandler(spec *BasicSpecInfo) *MockHandler {
+	h := &MockHandler{}
+	for i, item := range *spec {
+		if item.Url == "" {
+			continue
+		}
+		route := &mockRoute{item: item, method: strings.ToUpper(item.Method), path: mockPath(item.Url), priority: i}
+		if route.method == "" {
+			route.method = http.MethodGet
+		}
+		pattern := "^"
+		last := 0
+		for _, loc := range envReg.FindAllStringSubmatchIndex(route.path, -1) {
+			pattern += regexp.QuoteMeta(route.path[last:loc[0]]) + "([^/]+)"
+			route.params = append(route.params, strings.TrimPrefix(strings.TrimSpace(route.path[loc[2]:loc[3]]), "$env."))
+			last = loc[1]
+		}
+		route.reg = regexp.MustCompile(pattern + regexp.QuoteMeta(route.path[last:]) + "$")
+		h.routes = append(h.routes, route)
+	}
+	return h
+}
+
+// 启动mock server, 使用完之后需要Close
+func NewMockServer(spec *BasicSpecInfo) *httptest.Server {
+	return httptest.NewServer(NewMockHandler(spec))
+}
+
+// url中的路径部分, 去掉协议、host或者{{baseUrl}}以及query
+func mockPath(rawUrl string) string {
+	path := strings.TrimSpace(rawUrl)
+	if index := strings.Index(path, "://"); index >= 0 {
+		path = path[index+3:]
+		if slash := strings.Index(path, "/"); slash >= 0 {
+			path = path[slash:]
+		} else {
+			path = "/"
+		}
+	} else if loc := mockBaseReg.FindStringIndex(path); loc != nil && strings.HasPrefix(path[loc[1]:], "/") {
+		path = path[loc[1]:]
+	}
+	if index := strings.IndexAny(path, "?#"); index >= 0 {
+		path = path[:index]
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+func (h *MockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &mockRequest{req: r, raw: string(data)}
+	if err := decodeJSON(data, &req.body); err != nil {
+		req.body = req.raw
+	}
+
+	route, params := h.match(req)
+	if route == nil {
+		h.notFound(w, r)
+		return
+	}
+	req.params = params
+
+	example := route.item.Example
+	if example == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var body []byte
+	contentType := ""
+	switch value := req.renderValue(example.Body).(type) {
+	case nil:
+	case string:
+		body = []byte(value)
+		if json.Valid(body) {
+			contentType = "application/json"
+		} else {
+			contentType = "text/plain; charset=utf-8"
+		}
+	default:
+		body, _ = json.Marshal(value)
+		contentType = "application/json"
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	for key, value := range example.Header {
+		w.Header().Set(key, req.render(value))
+	}
+	status := example.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// 返回优先级最高的路由以及路径参数
+func (h *MockHandler) match(req *mockRequest) (*mockRoute, map[string]interface{}) {
+	var best *mockRoute
+	var bestParams map[string]interface{}
+	for _, route := range h.routes {
+		if route.method != req.req.Method {
+			continue
+		}
+		values := route.reg.FindStringSubmatch(req.req.URL.Path)
+		if values == nil || !req.satisfy(route.item.Example) {
+			continue
+		}
+		if best != nil && !route.before(best) {
+			continue
+		}
+		best, bestParams = route, map[string]interface{}{}
+		for i, name := range route.params {
+			bestParams[name] = values[i+1]
+		}
+	}
+	return best, bestParams
+}
+
+// 路径参数少的优先, 其次是条件多的, 最后按照在spec中的顺序
+func (r *mockRoute) before(other *mockRoute) bool {
+	if len(r.params) != len(other.params) {
+		return len(r.params) < len(other.params)
+	}
+	if r.conditions() != other.conditions() {
+		return r.conditions() > other.conditions()
+	}
+	return r.priority < other.priority
+}
+
+func (r *mockRoute) conditions() int {
+	if r.item.Example == nil || r.item.Example.Match == nil {
+		return 0
+	}
+	match := r.item.Example.Match
+	count := len(match.Header) + len(match.Query)
+	if match.Body != nil {
+		count++
+	}
+	return count
+}
+
+// 404时列出最接近的路由
+func (h *MockHandler) notFound(w http.ResponseWriter, r *http.Request) {
+	target := r.Method + " " + r.URL.Path
+	routes := []string{}
+	distances := map[string]int{}
+	for _, route := range h.routes {
+		name := route.method + " " + route.path
+		if _, ok := distances[name]; ok {
+			continue
+		}
+		routes = append(routes, name)
+		distances[name] = editDistance(target, route.method+" "+route.fill(r.URL.Path))
+	}
+	writeNotFound(w, target, routes, distances)
+}
+
+// 返回404以及与请求距离最近的路由
+func writeNotFound(w http.ResponseWriter, target string, routes []string, distances map[string]int) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return distances[routes[i]] < distances[routes[j]]
+	})
+	if len(routes) > mockClosestRoutes {
+		routes = routes[:mockClosestRoutes]
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"error":   fmt.Sprintf("没有匹配%s的路由", target),
+		"closest": routes,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(body)
+}
+
+// 路径模板中的参数替换为请求路径中对应的一段, 用于计算与请求的距离
+func (r *mockRoute) fill(path string) string {
+	segments := strings.Split(r.path, "/")
+	actual := strings.Split(path, "/")
+	for i, segment := range segments {
+		if i < len(actual) && envReg.MatchString(segment) {
+			segments[i] = actual[i]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// 按照字符计算的编辑距离
+func editDistance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	prev := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		cur := make([]int, len(y)+1)
+		cur[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(y)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// 收到的请求, 用于匹配条件以及渲染响应
+type mockRequest struct {
+	req    *http.Request
+	raw    string
+	body   interface{} // json请求体, 不是json时为字符串
+	params map[string]interface{}
+}
+
+func (m *mockRequest) satisfy(example *MockResponse) bool {
+	if example == nil || example.Match == nil {
+		return true
+	}
+	match := example.Match
+	for key, value := range match.Header {
+		if m.req.Header.Get(key) != value {
+			return false
+		}
+	}
+	query := m.req.URL.Query()
+	for key, value := range match.Query {
+		if query.Get(key) != value {
+			return false
+		}
+	}
+	if text, ok := match.Body.(string); ok {
+		return strings.TrimSpace(m.raw) == strings.TrimSpace(text)
+	}
+	return match.Body == nil || containsJSON(m.body, match.Body)
+}
+
+// actual包含expected中的字段, 数组需要相同
+func containsJSON(actual, expected interface{}) bool {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range expected {
+			if !containsJSON(actual[key], value) {
+				return false
+			}
+		}
+		return true
+	case float64:
+		number, ok := actual.(json.Number)
+		if !ok {
+			return false
+		}
+		value, err := number.Float64()
+		return err == nil && value == expected
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(expected) {
+			return false
+		}
+		for i := range expected {
+			if !containsJSON(actual[i], expected[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return equalJSON(actual, expected)
+}
+
+// 模板中的变量, 不存在时为nil
+func (m *mockRequest) lookup(key string) interface{} {
+	switch {
+	case key == "method":
+		return m.req.Method
+	case key == "path":
+		return m.req.URL.Path
+	case key == "body":
+		return m.body
+	case strings.HasPrefix(key, "header."):
+		if value := m.req.Header.Get(strings.TrimPrefix(key, "header.")); value != "" {
+			return value
+		}
+		return nil
+	case strings.HasPrefix(key, "query."):
+		if values, ok := m.req.URL.Query()[strings.TrimPrefix(key, "query.")]; ok && len(values) > 0 {
+			return values[0]
+		}
+		return nil
+	case strings.HasPrefix(key, "params."):
+		return m.params[strings.TrimPrefix(key, "params.")]
+	case strings.HasPrefix(key, "body."):
+		return lookupPath(m.body, strings.TrimPrefix(key, "body."))
+	}
+	return nil
+}
+
+// 替换字符串中的{{ }}, 不存在的变量保持原样
+func (m *mockRequest) render(s string) string {
+	return envReg.ReplaceAllStringFunc(s, func(match string) string {
+		key := strings.TrimSpace(match[2 : len(match)-2])
+		if value := m.lookup(key); value != nil {
+			return renderValue(value)
+		}
+		if value, ok := dynamicVariable(key); ok {
+			return value
+		}
+		return match
+	})
+}
+
+// 渲染body中的字符串, 整个字符串为一个变量时保持变量的类型, 例如{"id": "{{ body.id }}"}中的数字
+func (m *mockRequest) renderValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		if loc := envReg.FindStringSubmatchIndex(value); loc != nil && loc[0] == 0 && loc[1] == len(value) {
+			if res := m.lookup(strings.TrimSpace(value[loc[2]:loc[3]])); res != nil {
+				return res
+			}
+		}
+		return m.render(value)
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			res[key] = m.renderValue(child)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(value))
+		for i, child := range value {
+			res[i] = m.renderValue(child)
+		}
+		return res
+	}
+	return value
+}
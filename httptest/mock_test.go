@@ -0,0 +1,95 @@
+package httptest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockCall(t *testing.T, method string, url string, header map[string]string, body string) (int, http.Header, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.Nil(t, err)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+	return resp.StatusCode, resp.Header, string(data)
+}
+
+func TestMockServer(t *testing.T) {
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/mock/user.yaml", nil)
+	require.Nil(t, err)
+	ts := NewMockServer(specInfo)
+	defer ts.Close()
+
+	status, _, body := mockCall(t, "POST", ts.URL+"/api/login", nil, `{"name": "ving", "age": 18}`)
+	assert.Equal(t, 200, status)
+	assert.JSONEq(t, `{"token": "token-ving"}`, body)
+	// 请求体不满足match
+	status, _, _ = mockCall(t, "POST", ts.URL+"/api/login", nil, `{"name": "other"}`)
+	assert.Equal(t, 404, status)
+
+	status, header, body := mockCall(t, "GET", ts.URL+"/api/users/12?verbose=1", map[string]string{"Authorization": "bearer token-ving"}, "")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "GET", header.Get("X-Request-Method"))
+	assert.JSONEq(t, `{"id": "12", "verbose": "1"}`, body)
+
+	status, header, body = mockCall(t, "GET", ts.URL+"/api/users/12", nil, "")
+	assert.Equal(t, 401, status)
+	assert.Equal(t, "text/plain; charset=utf-8", header.Get("Content-Type"))
+	assert.Equal(t, "unauthorized", body)
+
+	// 没有路径参数的路由优先
+	_, _, body = mockCall(t, "GET", ts.URL+"/api/users/me", map[string]string{"Authorization": "bearer token-ving"}, "")
+	assert.JSONEq(t, `{"id": 0}`, body)
+
+	// 整个字符串为一个变量时保持类型
+	status, _, body = mockCall(t, "POST", ts.URL+"/api/orders", nil, `{"item": "book", "count": 2}`)
+	assert.Equal(t, 201, status)
+	assert.JSONEq(t, `{"item": "book", "count": 2}`, body)
+
+	status, _, body = mockCall(t, "GET", ts.URL+"/api/order", nil, "")
+	assert.Equal(t, 404, status)
+	res := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal([]byte(body), &res))
+	assert.Equal(t, "没有匹配GET /api/order的路由", res["error"])
+	assert.Equal(t, "POST /api/orders", res["closest"].([]interface{})[0])
+	_, _, body = mockCall(t, "GET", ts.URL+"/api/user/5", nil, "")
+	require.Nil(t, json.Unmarshal([]byte(body), &res))
+	assert.Equal(t, "GET /api/users/{{ userId }}", res["closest"].([]interface{})[0])
+}
+
+func TestMockServerSpecRun(t *testing.T) {
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/mock/user.yaml", nil)
+	require.Nil(t, err)
+	ts := NewMockServer(specInfo)
+	defer ts.Close()
+
+	// spec可以直接对mock server执行
+	runSpec, err := NewBasicSpecInfo([]byte(`{
+		"before_all": [{"name": "登录", "url": "{{baseUrl}}/api/login", "method": "post", "body": {"name": "ving"},
+			"event": ["$env.token = $res.$body.$json.token"]}],
+		"items": [{"name": "用户信息", "url": "{{baseUrl}}/api/users/7?verbose=1", "header": ["Authorization: bearer {{token}}"],
+			"expect": ["$res.$status == 200", "$res.$body.$json.id == \"7\""]}]
+	}`), nil)
+	require.Nil(t, err)
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, runSpec.Runner().Run(t, ctx))
+}
+
+func TestMockPath(t *testing.T) {
+	assert.Equal(t, "/api/users/{{id}}", mockPath("{{baseUrl}}/api/users/{{id}}?a=1"))
+	assert.Equal(t, "/api", mockPath("https://example.com/api#top"))
+	assert.Equal(t, "/", mockPath("http://127.0.0.1:8000"))
+	assert.Equal(t, "/api", mockPath("api"))
+}
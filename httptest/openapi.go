@@ -0,0 +1,386 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+////////////////////
+// openapi3 文档(json、yaml)
+// 1、只解析生成spec以及校验响应需要的字段
+// 2、$ref只支持文档内的#/components/...
+////////////////////
+
+type OpenAPI struct {
+	Openapi string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Servers    []*OpenAPIServer            `json:"servers"`
+	Paths      map[string]*OpenAPIPathItem `json:"paths"`
+	Components struct {
+		Schemas       map[string]*JSONSchema         `json:"schemas"`
+		Responses     map[string]*OpenAPIResponse    `json:"responses"`
+		Parameters    map[string]*OpenAPIParameter   `json:"parameters"`
+		RequestBodies map[string]*OpenAPIRequestBody `json:"requestBodies"`
+		Headers       map[string]*OpenAPIHeader      `json:"headers"`
+	} `json:"components"`
+
+	compileOnce sync.Once
+	compiled    []*openapiMatcher // 路径模板编译后的结果, 用于匹配请求
+}
+
+type OpenAPIServer struct {
+	Url         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type OpenAPIPathItem struct {
+	Parameters []*OpenAPIParameter `json:"parameters"`
+	Get        *OpenAPIOperation   `json:"get"`
+	Put        *OpenAPIOperation   `json:"put"`
+	Post       *OpenAPIOperation   `json:"post"`
+	Delete     *OpenAPIOperation   `json:"delete"`
+	Options    *OpenAPIOperation   `json:"options"`
+	Head       *OpenAPIOperation   `json:"head"`
+	Patch      *OpenAPIOperation   `json:"patch"`
+	Trace      *OpenAPIOperation   `json:"trace"`
+}
+
+type OpenAPIOperation struct {
+	OperationId string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []*OpenAPIParameter         `json:"parameters"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Ref      string      `json:"$ref"`
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required"`
+	Schema   *JSONSchema `json:"schema"`
+	Example  interface{} `json:"example"`
+}
+
+type OpenAPIRequestBody struct {
+	Ref      string                       `json:"$ref"`
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema   *JSONSchema `json:"schema"`
+	Example  interface{} `json:"example"`
+	Examples map[string]*struct {
+		Value interface{} `json:"value"`
+	} `json:"examples"`
+}
+
+type OpenAPIResponse struct {
+	Ref         string                       `json:"$ref"`
+	Description string                       `json:"description"`
+	Headers     map[string]*OpenAPIHeader    `json:"headers"`
+	Content     map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIHeader struct {
+	Ref      string      `json:"$ref"`
+	Required bool        `json:"required"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+// data可以为json或者yaml
+func NewOpenAPI(data []byte) (*OpenAPI, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var res OpenAPI
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(res.Openapi, "3.") {
+		return nil, fmt.Errorf("只支持openapi 3.x, 当前为%q", res.Openapi)
+	}
+	return &res, nil
+}
+
+func NewOpenAPIFromFile(path string) (*OpenAPI, error) {
+	data, _, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewOpenAPI(data)
+}
+
+// yaml转为json, 非字符串的key(例如响应码200)转为字符串
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(yamlNormalize(doc))
+}
+
+func yamlNormalize(value interface{}) interface{} {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		for key, item := range val {
+			val[key] = yamlNormalize(item)
+		}
+		return val
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for key, item := range val {
+			res[fmt.Sprint(key)] = yamlNormalize(item)
+		}
+		return res
+	case []interface{}:
+		for i, item := range val {
+			val[i] = yamlNormalize(item)
+		}
+		return val
+	}
+	return value
+}
+
+// 一个接口: method + path模板
+type openapiRoute struct {
+	method     string
+	path       string
+	operation  *OpenAPIOperation
+	parameters []*OpenAPIParameter // path级别与operation级别合并后的参数
+}
+
+var openapiMethods = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH", "TRACE"}
+
+func (p *OpenAPIPathItem) operation(method string) *OpenAPIOperation {
+	switch method {
+	case "GET":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "DELETE":
+		return p.Delete
+	case "OPTIONS":
+		return p.Options
+	case "HEAD":
+		return p.Head
+	case "PATCH":
+		return p.Patch
+	case "TRACE":
+		return p.Trace
+	}
+	return nil
+}
+
+// 所有接口, 按照path、method排序
+func (doc *OpenAPI) routes() []*openapiRoute {
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	res := []*openapiRoute{}
+	for _, path := range paths {
+		item := doc.Paths[path]
+		if item == nil {
+			continue
+		}
+		for _, method := range openapiMethods {
+			operation := item.operation(method)
+			if operation == nil {
+				continue
+			}
+			res = append(res, &openapiRoute{
+				method:     method,
+				path:       path,
+				operation:  operation,
+				parameters: doc.mergeParameters(item.Parameters, operation.Parameters),
+			})
+		}
+	}
+	return res
+}
+
+// operation级别的参数覆盖path级别的同名参数
+func (doc *OpenAPI) mergeParameters(pathParams, opParams []*OpenAPIParameter) []*OpenAPIParameter {
+	res := []*OpenAPIParameter{}
+	index := map[string]int{}
+	for _, item := range append(append([]*OpenAPIParameter{}, pathParams...), opParams...) {
+		param := doc.parameter(item)
+		if param == nil {
+			continue
+		}
+		key := param.In + ":" + param.Name
+		if i, ok := index[key]; ok {
+			res[i] = param
+			continue
+		}
+		index[key] = len(res)
+		res = append(res, param)
+	}
+	return res
+}
+
+// #/components/schemas/Pet => schemas, Pet
+func splitRef(ref string) (string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+	if !strings.HasPrefix(ref, "#/components/") || len(parts) != 2 {
+		return "", "", false
+	}
+	name := strings.NewReplacer("~1", "/", "~0", "~").Replace(parts[1])
+	return parts[0], name, true
+}
+
+func (doc *OpenAPI) resolveSchema(ref string) *JSONSchema {
+	if kind, name, ok := splitRef(ref); ok && kind == "schemas" {
+		return doc.Components.Schemas[name]
+	}
+	return nil
+}
+
+func (doc *OpenAPI) parameter(p *OpenAPIParameter) *OpenAPIParameter {
+	for depth := 0; p != nil && p.Ref != "" && depth < 32; depth++ {
+		kind, name, ok := splitRef(p.Ref)
+		if !ok || kind != "parameters" {
+			return nil
+		}
+		p = doc.Components.Parameters[name]
+	}
+	return p
+}
+
+func (doc *OpenAPI) requestBody(b *OpenAPIRequestBody) *OpenAPIRequestBody {
+	for depth := 0; b != nil && b.Ref != "" && depth < 32; depth++ {
+		kind, name, ok := splitRef(b.Ref)
+		if !ok || kind != "requestBodies" {
+			return nil
+		}
+		b = doc.Components.RequestBodies[name]
+	}
+	return b
+}
+
+func (doc *OpenAPI) response(r *OpenAPIResponse) *OpenAPIResponse {
+	for depth := 0; r != nil && r.Ref != "" && depth < 32; depth++ {
+		kind, name, ok := splitRef(r.Ref)
+		if !ok || kind != "responses" {
+			return nil
+		}
+		r = doc.Components.Responses[name]
+	}
+	return r
+}
+
+func (doc *OpenAPI) header(h *OpenAPIHeader) *OpenAPIHeader {
+	for depth := 0; h != nil && h.Ref != "" && depth < 32; depth++ {
+		kind, name, ok := splitRef(h.Ref)
+		if !ok || kind != "headers" {
+			return nil
+		}
+		h = doc.Components.Headers[name]
+	}
+	return h
+}
+
+// 展开schema中的$ref, 递归引用处替换为不做限制的schema
+func (doc *OpenAPI) inlineSchema(s *JSONSchema) *JSONSchema {
+	return doc.inline(s, map[string]bool{})
+}
+
+func (doc *OpenAPI) inline(s *JSONSchema, seen map[string]bool) *JSONSchema {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		if seen[s.Ref] {
+			return &JSONSchema{}
+		}
+		seen[s.Ref] = true
+		defer delete(seen, s.Ref)
+		return doc.inline(doc.resolveSchema(s.Ref), seen)
+	}
+
+	res := *s
+	if s.Properties != nil {
+		res.Properties = make(map[string]*JSONSchema, len(s.Properties))
+		for key, item := range s.Properties {
+			res.Properties[key] = doc.inline(item, seen)
+		}
+	}
+	res.Items = doc.inline(s.Items, seen)
+	res.AdditionalProperties = doc.inline(s.AdditionalProperties, seen)
+	res.AllOf = doc.inlineList(s.AllOf, seen)
+	res.OneOf = doc.inlineList(s.OneOf, seen)
+	res.AnyOf = doc.inlineList(s.AnyOf, seen)
+	return &res
+}
+
+func (doc *OpenAPI) inlineList(list []*JSONSchema, seen map[string]bool) []*JSONSchema {
+	if list == nil {
+		return nil
+	}
+	res := make([]*JSONSchema, 0, len(list))
+	for _, item := range list {
+		res = append(res, doc.inline(item, seen))
+	}
+	return res
+}
+
+// 媒体类型的示例: example > examples > 根据schema生成
+func (doc *OpenAPI) mediaExample(media *OpenAPIMediaType) interface{} {
+	if media.Example != nil {
+		return media.Example
+	}
+	names := make([]string, 0, len(media.Examples))
+	for name := range media.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if item := media.Examples[name]; item != nil && item.Value != nil {
+			return item.Value
+		}
+	}
+	return schemaExample(media.Schema, doc.resolveSchema)
+}
+
+// 选择媒体类型, 优先使用json
+func pickMedia(content map[string]*OpenAPIMediaType) (string, *OpenAPIMediaType) {
+	types := make([]string, 0, len(content))
+	for key := range content {
+		types = append(types, key)
+	}
+	sort.Strings(types)
+	for _, key := range types {
+		if isJSONMedia(key) {
+			return key, content[key]
+		}
+	}
+	if len(types) > 0 {
+		return types[0], content[types[0]]
+	}
+	return "", nil
+}
+
+func isJSONMedia(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
@@ -0,0 +1,291 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+////////////////////
+// 根据openapi文档校验请求与响应(契约测试)
+// 1、按照method与路径模板匹配operation, 未声明的接口报错
+// 2、校验请求参数、请求体
+// 3、校验响应状态码、响应头、响应体
+////////////////////
+
+// 路径模板编译后的正则, 例如/pets/{id} => ^/pets/([^/]+)$
+type openapiMatcher struct {
+	route  *openapiRoute
+	reg    *regexp.Regexp
+	params []string
+}
+
+func (doc *OpenAPI) matchers() []*openapiMatcher {
+	doc.compileOnce.Do(func() {
+		for _, route := range doc.routes() {
+			matcher := &openapiMatcher{route: route}
+			pattern := "^"
+			last := 0
+			for _, loc := range openapiPathParamReg.FindAllStringSubmatchIndex(route.path, -1) {
+				pattern += regexp.QuoteMeta(route.path[last:loc[0]]) + "([^/]+)"
+				matcher.params = append(matcher.params, route.path[loc[2]:loc[3]])
+				last = loc[1]
+			}
+			pattern += regexp.QuoteMeta(route.path[last:]) + "$"
+			matcher.reg = regexp.MustCompile(pattern)
+			doc.compiled = append(doc.compiled, matcher)
+		}
+		// 没有路径参数的模板优先匹配, 例如/pets/mine优先于/pets/{id}
+		sort.SliceStable(doc.compiled, func(i, j int) bool {
+			return len(doc.compiled[i].params) < len(doc.compiled[j].params)
+		})
+	})
+	return doc.compiled
+}
+
+// 根据method与请求路径查找接口, 返回路径参数
+func (doc *OpenAPI) match(method string, path string) (*openapiRoute, map[string]string, error) {
+	candidates := []string{path}
+	for _, server := range doc.Servers {
+		if server == nil {
+			continue
+		}
+		u, err := url.Parse(server.Url)
+		if err != nil {
+			continue
+		}
+		base := strings.TrimSuffix(u.Path, "/")
+		if base != "" && strings.HasPrefix(path, base+"/") {
+			candidates = append(candidates, strings.TrimPrefix(path, base))
+		}
+	}
+
+	method = strings.ToUpper(method)
+	methodMismatch := ""
+	for _, candidate := range candidates {
+		for _, matcher := range doc.matchers() {
+			values := matcher.reg.FindStringSubmatch(candidate)
+			if values == nil {
+				continue
+			}
+			if matcher.route.method != method {
+				methodMismatch = matcher.route.path
+				continue
+			}
+			params := map[string]string{}
+			for i, name := range matcher.params {
+				value, err := url.PathUnescape(values[i+1])
+				if err != nil {
+					value = values[i+1]
+				}
+				params[name] = value
+			}
+			return matcher.route, params, nil
+		}
+	}
+	if methodMismatch != "" {
+		return nil, nil, fmt.Errorf("%s %s: 接口%s未声明%s方法", method, path, methodMismatch, method)
+	}
+	return nil, nil, fmt.Errorf("%s %s: 未在文档中声明的接口", method, path)
+}
+
+// 校验一次请求与响应是否满足文档, reqBody、respBody为请求体与响应体的内容
+func (doc *OpenAPI) ValidateExchange(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) error {
+	route, params, err := doc.match(req.Method, req.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	errs := []string{}
+	for _, item := range doc.validateRequest(route, params, req, reqBody) {
+		errs = append(errs, "请求"+item)
+	}
+	for _, item := range doc.validateResponse(route, resp, respBody) {
+		errs = append(errs, "响应"+item)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s %s: %s", route.method, route.path, strings.Join(errs, "; "))
+}
+
+func (doc *OpenAPI) validateRequest(route *openapiRoute, pathParams map[string]string, req *http.Request, body []byte) []string {
+	errs := []string{}
+	query := req.URL.Query()
+	for _, param := range route.parameters {
+		var value string
+		var ok bool
+		switch param.In {
+		case "path":
+			value, ok = pathParams[param.Name]
+		case "query":
+			_, ok = query[param.Name]
+			value = query.Get(param.Name)
+		case "header":
+			_, ok = req.Header[http.CanonicalHeaderKey(param.Name)]
+			value = req.Header.Get(param.Name)
+		case "cookie":
+			cookie, err := req.Cookie(param.Name)
+			if ok = err == nil; ok {
+				value = cookie.Value
+			}
+		default:
+			continue
+		}
+		if !ok {
+			if param.Required {
+				errs = append(errs, fmt.Sprintf("缺少%s参数%s", param.In, param.Name))
+			}
+			continue
+		}
+		for _, item := range schemaErrors(param.Schema, parseParamValue(value, param.Schema, doc.resolveSchema), doc.resolveSchema) {
+			errs = append(errs, fmt.Sprintf("%s参数%s%s", param.In, param.Name, strings.TrimPrefix(item, "$")))
+		}
+	}
+
+	requestBody := doc.requestBody(route.operation.RequestBody)
+	if requestBody == nil {
+		return errs
+	}
+	if len(body) == 0 {
+		if requestBody.Required {
+			errs = append(errs, "体为空")
+		}
+		return errs
+	}
+	media, ok := findMedia(requestBody.Content, req.Header.Get("Content-Type"))
+	if !ok {
+		return append(errs, fmt.Sprintf("未声明的content-type %q", req.Header.Get("Content-Type")))
+	}
+	if media != nil && media.Schema != nil && isJSONMedia(req.Header.Get("Content-Type")) {
+		for _, item := range jsonBodyErrors(media.Schema, body, doc.resolveSchema) {
+			errs = append(errs, "体"+item)
+		}
+	}
+	return errs
+}
+
+func (doc *OpenAPI) validateResponse(route *openapiRoute, resp *http.Response, body []byte) []string {
+	response, ok := findResponse(route.operation.Responses, resp.StatusCode)
+	if !ok {
+		codes := make([]string, 0, len(route.operation.Responses))
+		for code := range route.operation.Responses {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return []string{fmt.Sprintf("状态码%d未在文档中声明(%s)", resp.StatusCode, strings.Join(codes, ", "))}
+	}
+	response = doc.response(response)
+	if response == nil {
+		return nil
+	}
+
+	errs := []string{}
+	names := make([]string, 0, len(response.Headers))
+	for name := range response.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header := doc.header(response.Headers[name])
+		if header == nil || strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		values, ok := resp.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			if header.Required {
+				errs = append(errs, fmt.Sprintf("缺少头%s", name))
+			}
+			continue
+		}
+		for _, item := range schemaErrors(header.Schema, parseParamValue(values[0], header.Schema, doc.resolveSchema), doc.resolveSchema) {
+			errs = append(errs, fmt.Sprintf("头%s%s", name, strings.TrimPrefix(item, "$")))
+		}
+	}
+
+	if len(body) == 0 || len(response.Content) == 0 {
+		return errs
+	}
+	contentType := resp.Header.Get("Content-Type")
+	media, ok := findMedia(response.Content, contentType)
+	if !ok {
+		return append(errs, fmt.Sprintf("未声明的content-type %q", contentType))
+	}
+	if media != nil && media.Schema != nil && (isJSONMedia(contentType) || contentType == "") {
+		for _, item := range jsonBodyErrors(media.Schema, body, doc.resolveSchema) {
+			errs = append(errs, "体"+item)
+		}
+	}
+	return errs
+}
+
+// 状态码匹配顺序: 200 > 2XX > default
+func findResponse(responses map[string]*OpenAPIResponse, status int) (*OpenAPIResponse, bool) {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if response, ok := responses[key]; ok {
+			return response, true
+		}
+	}
+	return nil, false
+}
+
+// content-type匹配顺序: 完全匹配 > application/* > */*, 没有声明content时不做限制
+func findMedia(content map[string]*OpenAPIMediaType, contentType string) (*OpenAPIMediaType, bool) {
+	if len(content) == 0 {
+		return nil, true
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	wildcard := ""
+	if index := strings.Index(mediaType, "/"); index > 0 {
+		wildcard = mediaType[:index] + "/*"
+	}
+	for _, key := range []string{mediaType, wildcard, "*/*"} {
+		for declared, media := range content {
+			if key != "" && strings.ToLower(strings.TrimSpace(strings.Split(declared, ";")[0])) == key {
+				return media, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func jsonBodyErrors(schema *JSONSchema, body []byte, resolve func(ref string) *JSONSchema) []string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{"不是合法的json"}
+	}
+	return schemaErrors(schema, value, resolve)
+}
+
+// 参数、响应头都是字符串, 根据schema的类型转换后再校验
+func parseParamValue(value string, schema *JSONSchema, resolve func(ref string) *JSONSchema) interface{} {
+	for depth := 0; schema != nil && schema.Ref != "" && depth < 32; depth++ {
+		schema = resolve(schema.Ref)
+	}
+	if schema == nil {
+		return value
+	}
+	switch {
+	case schema.Type.Has("integer"), schema.Type.Has("number"):
+		if num, err := strconv.ParseFloat(value, 64); err == nil {
+			return num
+		}
+	case schema.Type.Has("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case schema.Type.Has("array"):
+		res := []interface{}{}
+		for _, item := range strings.Split(value, ",") {
+			res = append(res, parseParamValue(item, schema.Items, resolve))
+		}
+		return res
+	}
+	return value
+}
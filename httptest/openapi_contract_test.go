@@ -0,0 +1,96 @@
+package httptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPetServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "POST":
+			w.WriteHeader(201)
+			w.Write([]byte(`{"id": 3, "name": "旺财"}`))
+		case r.URL.Path == "/api/pets":
+			w.Write([]byte(`[{"id": "x", "name": "旺财"}]`))
+		case r.URL.Path == "/api/pets/1":
+			w.WriteHeader(500)
+			w.Write([]byte(`{"error": "boom"}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	ts := newPetServer()
+	defer ts.Close()
+
+	doc, err := NewOpenAPIFromFile("./testdata/openapi/petstore.yaml")
+	require.Nil(t, err)
+
+	spec := BasicSpecInfo{
+		{Name: "create", Url: ts.URL + "/api/pets", Method: "post", Body: `{"name": "旺财"}`, ContentType: "application/json"},
+		{Name: "list", Url: ts.URL + "/api/pets?limit=10", Method: "get"},
+		{Name: "get", Url: ts.URL + "/api/pets/1", Method: "get"},
+		{Name: "patch", Url: ts.URL + "/api/pets/1", Method: "patch"},
+		{Name: "unknown", Url: ts.URL + "/api/stores", Method: "get"},
+	}
+	ctx := NewHttpContext().WithContract(doc)
+	// 契约不满足时测试失败, 这里使用单独的T以检查具体的错误
+	require.Nil(t, spec.StartHandleWithContext(&testing.T{}, ctx))
+
+	assert.Equal(t, []string{
+		`GET /pets: 请求缺少header参数X-Token; 响应体$[0].id: 期望integer, 实际为string "x"`,
+		"GET /pets/{petId}: 响应体$: 缺少必填字段msg",
+		"PATCH /api/pets/1: 接口/pets/{petId}未声明PATCH方法",
+		"GET /api/stores: 未在文档中声明的接口",
+	}, ctx.Violations())
+}
+
+func TestOpenAPIContractPostman(t *testing.T) {
+	ts := newPetServer()
+	defer ts.Close()
+
+	doc, err := NewOpenAPIFromFile("./testdata/openapi/petstore.yaml")
+	require.Nil(t, err)
+
+	collection, err := NewPostmanSpecInfo([]byte(`{
+		"item": [
+			{"name": "create", "request": {"method": "POST", "url": "{{baseUrl}}/api/pets", "body": {"mode": "raw", "raw": "{\"tag\": \"bird\"}", "options": {"raw": {"language": "json"}}}}},
+			{"name": "list", "request": {"method": "GET", "url": "{{baseUrl}}/api/pets?limit=ten", "header": [{"key": "X-Token", "value": "1"}]}}
+		]
+	}`), nil)
+	require.Nil(t, err)
+	collection.WithEnvironment(&PostmanEnvironment{Values: []*PostmanVariable{{Key: "baseUrl", Value: ts.URL}}})
+
+	ctx := NewHttpContext().WithContract(doc)
+	require.Nil(t, collection.StartHandleWithContext(&testing.T{}, ctx))
+	require.Len(t, ctx.Violations(), 2)
+	assert.Equal(t, `POST /pets: 请求体$: 缺少必填字段name; 请求体$.tag: bird不在枚举[dog cat]中`, ctx.Violations()[0])
+	assert.True(t, strings.HasPrefix(ctx.Violations()[1], `GET /pets: 请求query参数limit: 期望integer, 实际为string`))
+}
+
+func TestOpenAPIContractDo(t *testing.T) {
+	ts := newPetServer()
+	defer ts.Close()
+
+	doc, err := NewOpenAPIFromFile("./testdata/openapi/petstore.yaml")
+	require.Nil(t, err)
+
+	ctx := NewHttpContext().WithContract(doc)
+	ctx.Do(t, "create", &HandleOption{
+		Url:         ts.URL + "/api/pets",
+		Method:      "POST",
+		ContentType: "application/json",
+		Body:        strings.NewReader(`{"name": "旺财", "tag": "cat"}`),
+		Expect:      []string{"$res.$status == 201"},
+	})
+	assert.Empty(t, ctx.Violations())
+}
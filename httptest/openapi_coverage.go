@@ -0,0 +1,163 @@
+package httptest
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+////////////////////
+// 根据openapi文档统计接口覆盖率
+// 1、每个operation是否被调用, 调用时返回的状态码
+// 2、文档中声明但没有覆盖的状态码
+// 3、未在文档中声明的请求
+////////////////////
+
+type CoverageReport struct {
+	Total        int                  `json:"total"`
+	Covered      int                  `json:"covered"`
+	Percent      float64              `json:"percent"`
+	Operations   []*OperationCoverage `json:"operations"`
+	Undocumented []string             `json:"undocumented"`
+}
+
+type OperationCoverage struct {
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	OperationId string   `json:"operationId,omitempty"`
+	Calls       int      `json:"calls"`
+	Statuses    []int    `json:"statuses"`   // 实际返回的状态码
+	Documented  []string `json:"documented"` // 文档中声明的状态码
+	Missing     []string `json:"missing"`    // 声明了但没有覆盖的状态码
+}
+
+func (o *OperationCoverage) Covered() bool {
+	return o.Calls > 0
+}
+
+// 统计results对文档中接口的覆盖情况
+func (doc *OpenAPI) Coverage(results []*StepResult) *CoverageReport {
+	report := &CoverageReport{Operations: []*OperationCoverage{}, Undocumented: []string{}}
+	index := map[string]*OperationCoverage{}
+	for _, route := range doc.routes() {
+		item := &OperationCoverage{
+			Method:      route.method,
+			Path:        route.path,
+			OperationId: route.operation.OperationId,
+			Statuses:    []int{},
+			Documented:  []string{},
+		}
+		for code := range route.operation.Responses {
+			item.Documented = append(item.Documented, code)
+		}
+		sort.Strings(item.Documented)
+		report.Operations = append(report.Operations, item)
+		index[route.method+" "+route.path] = item
+	}
+
+	undocumented := map[string]bool{}
+	for _, result := range results {
+		// 跳过的item没有发送请求
+		if result.Skipped {
+			continue
+		}
+		u, err := url.Parse(result.Url)
+		if err != nil {
+			continue
+		}
+		route, _, err := doc.match(result.Method, u.Path)
+		if err != nil {
+			key := strings.ToUpper(result.Method) + " " + u.Path
+			if !undocumented[key] {
+				undocumented[key] = true
+				report.Undocumented = append(report.Undocumented, key)
+			}
+			continue
+		}
+		item := index[route.method+" "+route.path]
+		if item == nil {
+			continue
+		}
+		item.Calls++
+		if !containsInt(item.Statuses, result.Status) {
+			item.Statuses = append(item.Statuses, result.Status)
+		}
+	}
+
+	for _, item := range report.Operations {
+		sort.Ints(item.Statuses)
+		item.Missing = []string{}
+		for _, code := range item.Documented {
+			if !statusObserved(code, item.Statuses) {
+				item.Missing = append(item.Missing, code)
+			}
+		}
+		if item.Covered() {
+			report.Covered++
+		}
+	}
+	report.Total = len(report.Operations)
+	if report.Total > 0 {
+		report.Percent = float64(report.Covered) * 100 / float64(report.Total)
+	}
+	return report
+}
+
+// default表示其他所有状态码, 不要求覆盖
+func statusObserved(code string, statuses []int) bool {
+	if code == "default" {
+		return true
+	}
+	for _, status := range statuses {
+		str := strconv.Itoa(status)
+		if str == code || strings.EqualFold(str[:1]+"XX", code) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 文本格式的报告
+func (r *CoverageReport) String() string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "接口覆盖率: %d/%d (%.1f%%)\n", r.Covered, r.Total, r.Percent)
+	for _, item := range r.Operations {
+		mark := "[ ]"
+		if item.Covered() {
+			mark = "[x]"
+		}
+		fmt.Fprintf(buf, "%s %s %s", mark, item.Method, item.Path)
+		if item.OperationId != "" {
+			fmt.Fprintf(buf, " (%s)", item.OperationId)
+		}
+		if item.Covered() {
+			statuses := make([]string, 0, len(item.Statuses))
+			for _, status := range item.Statuses {
+				statuses = append(statuses, strconv.Itoa(status))
+			}
+			fmt.Fprintf(buf, " 调用%d次, 状态码: %s", item.Calls, strings.Join(statuses, ", "))
+		}
+		if len(item.Missing) > 0 {
+			fmt.Fprintf(buf, " 未覆盖的状态码: %s", strings.Join(item.Missing, ", "))
+		}
+		buf.WriteString("\n")
+	}
+	if len(r.Undocumented) > 0 {
+		buf.WriteString("未在文档中声明的请求:\n")
+		for _, item := range r.Undocumented {
+			fmt.Fprintf(buf, "    %s\n", item)
+		}
+	}
+	return buf.String()
+}
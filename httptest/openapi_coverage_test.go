@@ -0,0 +1,61 @@
+package httptest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPICoverage(t *testing.T) {
+	ts := newPetServer()
+	defer ts.Close()
+
+	doc, err := NewOpenAPIFromFile("./testdata/openapi/petstore.yaml")
+	require.Nil(t, err)
+
+	spec := BasicSpecInfo{
+		{Name: "create", Url: ts.URL + "/api/pets", Method: "post", Body: `{"name": "旺财"}`, ContentType: "application/json"},
+		{Name: "list", Url: ts.URL + "/api/pets?limit=10", Method: "get"},
+		{Name: "list again", Url: ts.URL + "/api/pets?limit=20", Method: "get"},
+		{Name: "get", Url: ts.URL + "/api/pets/1", Method: "get"},
+		{Name: "unknown", Url: ts.URL + "/api/stores", Method: "get"},
+	}
+	ctx := NewHttpContext()
+	require.Nil(t, spec.StartHandleWithContext(t, ctx))
+	require.Len(t, ctx.Results(), 5)
+	assert.Equal(t, "get", ctx.Results()[3].Title)
+	assert.Equal(t, "GET", ctx.Results()[3].Method)
+	assert.Equal(t, ts.URL+"/api/pets/1", ctx.Results()[3].Url)
+	assert.Equal(t, 500, ctx.Results()[3].Status)
+	assert.False(t, ctx.Results()[3].Failed)
+
+	report := doc.Coverage(ctx.Results())
+	assert.Equal(t, 5, report.Total)
+	assert.Equal(t, 3, report.Covered)
+	assert.Equal(t, 60.0, report.Percent)
+	assert.Equal(t, []string{"GET /api/stores"}, report.Undocumented)
+
+	list := report.Operations[0]
+	assert.Equal(t, "listPets", list.OperationId)
+	assert.Equal(t, 2, list.Calls)
+	assert.Equal(t, []int{200}, list.Statuses)
+	assert.Equal(t, []string{"400"}, report.Operations[1].Missing)
+	assert.False(t, report.Operations[3].Covered())
+
+	assert.Equal(t, `接口覆盖率: 3/5 (60.0%)
+[x] GET /pets (listPets) 调用2次, 状态码: 200
+[x] POST /pets (createPet) 调用1次, 状态码: 201 未覆盖的状态码: 400
+[x] GET /pets/{petId} 调用1次, 状态码: 500 未覆盖的状态码: 200
+[ ] DELETE /pets/{petId} 未覆盖的状态码: 204
+[ ] PUT /pets/{petId}/photo (uploadPhoto) 未覆盖的状态码: 2XX
+未在文档中声明的请求:
+    GET /api/stores
+`, report.String())
+
+	data, err := json.Marshal(report)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"percent":60`)
+	assert.Contains(t, string(data), `{"method":"DELETE","path":"/pets/{petId}","calls":0,"statuses":[],"documented":["204"],"missing":["204"]}`)
+}
@@ -0,0 +1,181 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+////////////////////
+// 根据openapi文档生成spec
+// 1、每个operation对应一个item, 路径参数转为{{ }}变量
+// 2、请求体使用文档中的示例, 没有示例时根据schema生成
+// 3、expect检查文档中的成功状态码, schema校验响应体
+// 4、multipart中的文件字段使用占位的路径, 执行前需要替换为实际的文件
+////////////////////
+
+var openapiPathParamReg = regexp.MustCompile(`{([^{}]+)}`)
+
+// 文件字段的占位路径前缀, 例如./TODO-avatar
+const OpenAPIFilePlaceholder = "./TODO-"
+
+// baseUrl为空时使用文档中的第一个server, 都没有时使用{{baseUrl}}
+func (doc *OpenAPI) ToBasic(baseUrl string) *BasicSpecInfo {
+	if baseUrl == "" {
+		baseUrl = "{{baseUrl}}"
+		if len(doc.Servers) > 0 && doc.Servers[0] != nil {
+			if server := doc.Servers[0].Url; strings.Contains(server, "://") {
+				baseUrl = server
+			} else {
+				baseUrl += server
+			}
+		}
+	}
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+
+	res := BasicSpecInfo{}
+	for _, route := range doc.routes() {
+		res = append(res, doc.routeItem(baseUrl, route))
+	}
+	return &res
+}
+
+func (doc *OpenAPI) routeItem(baseUrl string, route *openapiRoute) *BasicItem {
+	operation := route.operation
+	item := &BasicItem{
+		Name:   operation.OperationId,
+		Url:    baseUrl + openapiPathParamReg.ReplaceAllString(route.path, "{{$1}}"),
+		Method: strings.ToLower(route.method),
+	}
+	if item.Name == "" {
+		item.Name = operation.Summary
+	}
+	if item.Name == "" {
+		item.Name = route.method + " " + route.path
+	}
+
+	query := []string{}
+	for _, param := range route.parameters {
+		if !param.Required {
+			continue
+		}
+		value := "{{" + param.Name + "}}"
+		if param.Example != nil {
+			value = fmt.Sprint(param.Example)
+		}
+		switch param.In {
+		case "query":
+			query = append(query, url.QueryEscape(param.Name)+"="+value)
+		case "header":
+			item.Header = append(item.Header, param.Name+": "+value)
+		}
+	}
+	if len(query) > 0 {
+		item.Url += "?" + strings.Join(query, "&")
+	}
+
+	if body := doc.requestBody(operation.RequestBody); body != nil {
+		doc.fillRequestBody(item, body)
+	}
+
+	code, response := doc.successResponse(operation)
+	switch {
+	case code == "":
+	case strings.HasSuffix(strings.ToUpper(code), "XX"):
+		low := int(code[0]-'0') * 100
+		item.Expect = append(item.Expect, fmt.Sprintf("$res.$status >= %d && $res.$status < %d", low, low+100))
+	default:
+		item.Expect = append(item.Expect, "$res.$status == "+code)
+	}
+	if response != nil {
+		if contentType, media := pickMedia(response.Content); media != nil && media.Schema != nil && isJSONMedia(contentType) {
+			item.Schema = doc.inlineSchema(media.Schema)
+		}
+	}
+	return item
+}
+
+// 文档中最小的2xx响应码
+func (doc *OpenAPI) successResponse(operation *OpenAPIOperation) (string, *OpenAPIResponse) {
+	codes := make([]string, 0, len(operation.Responses))
+	for code := range operation.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return "", nil
+	}
+	sort.Strings(codes)
+	return codes[0], doc.response(operation.Responses[codes[0]])
+}
+
+func (doc *OpenAPI) fillRequestBody(item *BasicItem, body *OpenAPIRequestBody) {
+	contentType, media := pickMedia(body.Content)
+	if media == nil {
+		return
+	}
+	item.ContentType = contentType
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		if mediaType == "multipart/form-data" {
+			item.BodyMode = BodyModeMultipart
+			// multipart的content-type由boundary决定
+			item.ContentType = ""
+		} else {
+			item.BodyMode = BodyModeUrlencoded
+		}
+		example, _ := doc.mediaExample(media).(map[string]interface{})
+		schema := doc.inlineSchema(media.Schema)
+		keys := make([]string, 0, len(example))
+		for key := range example {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field := &FormField{Key: key, Value: formValue(example[key])}
+			if schema != nil && item.BodyMode == BodyModeMultipart {
+				if prop := schema.Properties[key]; prop != nil && (prop.Format == "binary" || prop.Format == "base64") {
+					field.Value = ""
+					field.File = OpenAPIFilePlaceholder + key
+				}
+			}
+			item.Form = append(item.Form, field)
+		}
+	default:
+		example := doc.mediaExample(media)
+		if str, ok := example.(string); ok && !isJSONMedia(contentType) {
+			item.Body = str
+			return
+		}
+		if example == nil {
+			return
+		}
+		data, err := json.Marshal(example)
+		if err != nil {
+			return
+		}
+		item.Body = string(data)
+	}
+}
+
+func formValue(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool, int:
+		return fmt.Sprint(val)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
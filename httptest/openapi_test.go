@@ -0,0 +1,124 @@
+package httptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIToBasic(t *testing.T) {
+	doc, err := NewOpenAPIFromFile("./testdata/openapi/petstore.yaml")
+	require.Nil(t, err)
+
+	spec := *doc.ToBasic("")
+	require.Len(t, spec, 5)
+
+	list := spec[0]
+	assert.Equal(t, "listPets", list.Name)
+	assert.Equal(t, "get", list.Method)
+	assert.Equal(t, "{{baseUrl}}/api/pets?limit=10", list.Url)
+	assert.Equal(t, []string{"X-Token: {{X-Token}}"}, list.Header)
+	assert.Equal(t, []string{"$res.$status == 200"}, list.Expect)
+	require.NotNil(t, list.Schema)
+	assert.True(t, list.Schema.Type.Has("array"))
+	assert.Len(t, list.Schema.Items.AllOf, 2)
+
+	create := spec[1]
+	assert.Equal(t, "createPet", create.Name)
+	assert.Equal(t, "application/json", create.ContentType)
+	assert.JSONEq(t, `{"name": "旺财", "tag": "dog", "birthday": "2006-01-02"}`, create.Body)
+	assert.Equal(t, []string{"$res.$status == 201"}, create.Expect)
+
+	get := spec[2]
+	assert.Equal(t, "查询宠物", get.Name)
+	assert.Equal(t, "{{baseUrl}}/api/pets/{{petId}}", get.Url)
+	// 递归引用处不再展开
+	parent := get.Schema.AllOf[1].Properties["parent"]
+	require.NotNil(t, parent)
+	assert.Empty(t, parent.AllOf)
+
+	del := spec[3]
+	assert.Equal(t, "DELETE /pets/{petId}", del.Name)
+	assert.Equal(t, []string{"$res.$status == 204"}, del.Expect)
+	assert.Nil(t, del.Schema)
+
+	upload := spec[4]
+	assert.Equal(t, BodyModeMultipart, upload.BodyMode)
+	assert.Equal(t, []*FormField{{Key: "caption", Value: "晒太阳"}, {Key: "photo", File: "./TODO-photo"}}, upload.Form)
+	assert.Equal(t, []string{"$res.$status >= 200 && $res.$status < 300"}, upload.Expect)
+
+	assert.Equal(t, "http://localhost/pets?limit=10", (*doc.ToBasic("http://localhost/"))[0].Url)
+}
+
+func TestOpenAPIGeneratedSpecRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pet := map[string]interface{}{"id": 1, "name": "旺财", "tag": "dog"}
+		switch {
+		case r.Method == "DELETE":
+			w.WriteHeader(204)
+			return
+		case r.Method == "POST":
+			w.WriteHeader(201)
+		case r.URL.Path == "/api/pets":
+			pet = nil
+			w.Write([]byte(`[{"id": 1, "name": "旺财"}, {"id": 2, "name": "小白", "parent": {"id": 1}}]`))
+			return
+		}
+		body, _ := json.Marshal(pet)
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	doc, err := NewOpenAPIFromFile("./testdata/openapi/petstore.yaml")
+	require.Nil(t, err)
+	data, err := json.Marshal((*doc.ToBasic(ts.URL + "/api"))[:4])
+	require.Nil(t, err)
+
+	spec, err := NewBasicSpecInfo(data, func(item *BasicItem) {
+		item.Url = strings.Replace(item.Url, "{{petId}}", "1", 1)
+	})
+	require.Nil(t, err)
+	require.Nil(t, spec.StartHandle(t))
+}
+
+func TestJSONSchemaValidate(t *testing.T) {
+	doc, err := NewOpenAPIFromFile("./testdata/openapi/petstore.yaml")
+	require.Nil(t, err)
+	pet := doc.inlineSchema(&JSONSchema{Ref: "#/components/schemas/Pet"})
+
+	var value interface{}
+	require.Nil(t, json.Unmarshal([]byte(`{"id": 1, "name": "旺财", "birthday": "2020-01-01"}`), &value))
+	assert.Nil(t, pet.Validate(value))
+
+	require.Nil(t, json.Unmarshal([]byte(`{"id": 0.5, "name": 1, "tag": "bird", "birthday": "yesterday"}`), &value))
+	err = pet.Validate(value)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "$.id: 期望integer, 实际为number")
+	assert.Contains(t, err.Error(), "$.name: 期望string, 实际为integer")
+	assert.Contains(t, err.Error(), "$.tag: bird不在枚举[dog cat]中")
+	assert.Contains(t, err.Error(), `$.birthday: "yesterday"不是合法的date`)
+
+	require.Nil(t, json.Unmarshal([]byte(`{"name": "旺财"}`), &value))
+	assert.EqualError(t, pet.Validate(value), "$: 缺少必填字段id")
+
+	// 直接校验带$ref的schema
+	require.Nil(t, json.Unmarshal([]byte(`[{"id": 1, "name": "a", "parent": {"id": -1, "name": "b"}}]`), &value))
+	list := &JSONSchema{Type: SchemaType{"array"}, Items: &JSONSchema{Ref: "#/components/schemas/Pet"}}
+	assert.EqualError(t, validateSchema(list, value, doc.resolveSchema), "$[0].parent.id: -1小于最小值1")
+
+	strict := &JSONSchema{}
+	require.Nil(t, json.Unmarshal([]byte(`{"type": ["object", "null"], "additionalProperties": false, "properties": {"a": {"oneOf": [{"type": "string"}, {"type": "integer"}]}}}`), &strict))
+	assert.Nil(t, strict.Validate(nil))
+	assert.Nil(t, strict.Validate(map[string]interface{}{"a": "x"}))
+	assert.EqualError(t, strict.Validate(map[string]interface{}{"a": true, "b": 1}),
+		"$.a: 需要恰好满足oneOf中的一个schema, 实际满足0个; $.b: 不允许出现")
+
+	data, err := json.Marshal(strict)
+	require.Nil(t, err)
+	assert.JSONEq(t, `{"type": ["object", "null"], "additionalProperties": false, "properties": {"a": {"oneOf": [{"type": "string"}, {"type": "integer"}]}}}`, string(data))
+}
@@ -3,6 +3,8 @@ package httptest
 import (
 	"fmt"
 	"strings"
+
+	"github.com/wwqdrh/easytest/httptest/internal"
 )
 
 ////////////////////
@@ -16,7 +18,7 @@ import (
 // 语法分析
 ////////////////////
 
-// 判断c响应是否满足expect
+// 判断c响应是否满足expect, 非$contains、$status的表达式交给parser版处理
 func HandleExpect(c *HttpContext, expect []string) bool {
 	for _, item := range expect {
 		if strings.Index(item, "$contains") == 0 {
@@ -28,39 +30,103 @@ func HandleExpect(c *HttpContext, expect []string) bool {
 			}
 
 			if !HandleContains(c, parts) {
-				return true
+				return false
 			}
 		} else if strings.Index(item, "$status") == 0 {
 			statuscode := item[len("$status")+1 : len(item)-1]
-			return fmt.Sprint(c.responseStatus) == statuscode
+			if fmt.Sprint(c.responseStatus) != statuscode {
+				return false
+			}
+		} else if !ParserHandleExpect(c, []string{item}) {
+			return false
 		}
 	}
 	return true
 }
 
+// 返回不满足的expect的说明, check为HandleExpect或者ParserHandleExpect
+func expectFailures(c *HttpContext, expect []string, check func(*HttpContext, []string) bool) []string {
+	res := []string{}
+	for _, item := range expect {
+		passed := check(c, []string{item})
+		c.traceExpect(item, passed)
+		if !passed {
+			res = append(res, explainExpect(c, item))
+		}
+	}
+	return res
+}
+
+// 说明expect为什么不成立: 比较表达式输出期望值与实际值, 对象、数组按照路径输出差异
+func explainExpect(c *HttpContext, expect string) string {
+	expect = strings.TrimSpace(expect)
+	switch {
+	case strings.Index(expect, "$status") == 0:
+		return fmt.Sprintf("%s 不成立:\n  实际: %d", expect, c.responseStatus)
+	case strings.Index(expect, "$contains") == 0:
+		return fmt.Sprintf("%s 不成立:\n  响应: %s", expect, truncateText(c.responseData))
+	}
+
+	cmp, err := internal.DoCompare(NewIHTTPCtx(c), expect)
+	if err != nil {
+		return fmt.Sprintf("%s 执行失败: %s", expect, err.Error())
+	}
+	if cmp == nil {
+		return expect + " 不成立"
+	}
+
+	// 右边为期望值, 例如$res.$body.$json.msg == "ok"
+	msg := expect + " 不成立:"
+	if cmp.Op == "==" && isContainer(cmp.Left) && isContainer(cmp.Right) {
+		diff := jsonDiff("$", cmp.Right, cmp.Left)
+		if len(diff) > 0 {
+			return msg + "\n" + indentText(formatDiff(diff), "  ")
+		}
+	}
+	expected, actual := truncateText(compactJSON(cmp.Right)), truncateText(compactJSON(cmp.Left))
+	if cmp.Op == "==" {
+		expected, actual = diffValues(cmp.Right, cmp.Left)
+	} else {
+		expected = cmp.Op + " " + expected
+	}
+	return fmt.Sprintf("%s\n  期望: %s\n  实际: %s", msg, expected, actual)
+}
+
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+func indentText(text string, indent string) string {
+	return indent + strings.Replace(text, "\n", "\n"+indent, -1)
+}
+
+// $env.a=$json.b.c 形式使用简写处理, 其他的交给parser版处理
 func HandleEvent(c *HttpContext, event []string) bool {
 	for _, item := range event {
-		if strings.Index(item, "$env") == 0 {
-			pairs := strings.Split(item, "=")
-			if len(pairs) != 2 {
-				return false
-			}
-
-			left := strings.Split(pairs[0], ".")[1]
-			if strings.Index(pairs[1], "$json") == 0 {
-				right := c.responseJson[strings.Split(pairs[1], ".")[1]]
-				if right == "" {
-					return false
-				}
-				c.Setenv(left, right)
-			} else {
+		pairs := strings.SplitN(item, "=", 2)
+		if strings.Index(item, "$env") == 0 && len(pairs) == 2 && isJsonPath(strings.TrimSpace(pairs[1])) {
+			left := strings.TrimSpace(strings.Split(pairs[0], ".")[1])
+			right := lookupPath(c.responseJson, strings.TrimPrefix(strings.TrimSpace(pairs[1]), "$json."))
+			if right == nil || right == "" {
 				return false
 			}
+			c.Setenv(left, right)
+		} else if !ParserHandleEvent(c, []string{item}) {
+			return false
 		}
 	}
 	return true
 }
 
+// $json.a.b.0, 不包含运算符以及空格
+func isJsonPath(s string) bool {
+	return strings.HasPrefix(s, "$json.") && !strings.ContainsAny(s, " =!<>()&|\"")
+}
+
 // $contains
 func HandleContains(c *HttpContext, args []string) bool {
 	if len(args) != 2 {
@@ -22,6 +22,9 @@ func (c *HTTPCtx) GetRequest() *http.Request {
 	return c.ctx.request
 }
 func (c *HTTPCtx) GetResponse() *http.Response {
+	if c.ctx.response == nil {
+		return nil
+	}
 	return c.ctx.CopyResponse(c.ctx.response)
 }
 func (c *HTTPCtx) GetEnv(key string) interface{} {
@@ -30,6 +33,12 @@ func (c *HTTPCtx) GetEnv(key string) interface{} {
 func (c *HTTPCtx) SetEnv(key string, val interface{}) {
 	c.ctx.enviroment[key] = val
 }
+func (c *HTTPCtx) GetRow() map[string]interface{} {
+	return c.ctx.row
+}
+func (c *HTTPCtx) GetTiming() map[string]interface{} {
+	return c.ctx.timing.values()
+}
 
 // 判断c响应是否满足expect
 func ParserHandleExpect(c *HttpContext, expect []string) bool {
@@ -43,7 +52,9 @@ func ParserHandleExpect(c *HttpContext, expect []string) bool {
 
 		switch val := val.(type) {
 		case bool:
-			return val
+			if !val {
+				return false
+			}
 		}
 	}
 	return true
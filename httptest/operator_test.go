@@ -1 +1,106 @@
 package httptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleEvent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<form><input type="hidden" name="csrf" value="abc123"></form>`))
+		case "/orders":
+			w.Header().Set("Location", "/orders/42")
+			w.WriteHeader(201)
+			w.Write([]byte(`{"data": {"order": {"id": 42}}}`))
+		}
+	}))
+	defer ts.Close()
+
+	ctx := NewHttpContext()
+	ctx.Do(t, "登录页", &HandleOption{
+		Url:    ts.URL + "/login",
+		Method: "GET",
+		Event:  []string{`$env.csrf = @match($res.$body.$str, "name=\"csrf\" value=\"(.*?)\"", 1)`},
+	})
+	assert.Equal(t, "abc123", ctx.enviroment["csrf"])
+
+	for i := 0; i < 2; i++ {
+		ctx.Do(t, "创建订单", &HandleOption{
+			Url:    ts.URL + "/orders",
+			Method: "POST",
+			Event: []string{
+				`$env.location = $res.$header.Location`,
+				`$env.orderId = @match($env.location, "/orders/(\d+)")`,
+				`$env.id = $json.data.order.id`,
+				`@append($env.ids, $env.orderId)`,
+			},
+		})
+	}
+	assert.Equal(t, "/orders/42", ctx.enviroment["location"])
+	assert.Equal(t, float64(42), ctx.enviroment["id"])
+	assert.Equal(t, []interface{}{"42", "42"}, ctx.enviroment["ids"])
+	require.Len(t, ctx.Results(), 3)
+	for _, result := range ctx.Results() {
+		assert.False(t, result.Failed, result.Title)
+	}
+}
+
+func TestExplainExpect(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+		w.Write([]byte(`{"msg": "fail", "count": 3, "data": {"name": "a", "items": [1, 2]}, "text": "` + long + `b"}`))
+	}))
+	defer ts.Close()
+
+	ctx := NewHttpContext()
+	ctx.Setenv("data", map[string]interface{}{"name": "b", "items": []interface{}{float64(1)}, "extra": true})
+	ctx.Do(&testing.T{}, "失败的断言", &HandleOption{
+		Url:    ts.URL,
+		Method: "GET",
+		Expect: []string{
+			`$res.$status == 200`,
+			`$res.$body.$json.msg == "ok"`,
+			`$res.$body.$json.count < 2`,
+			`$res.$body.$json.data == $env.data`,
+			`$res.$body.$json.text == "` + long + `c"`,
+			`@include($res.$body.$str, "ok")`,
+			`$status(200)`,
+			`$res.$body.$json.count == 3`,
+		},
+	})
+	result := ctx.Results()[0]
+	require.True(t, result.Failed)
+	assert.Equal(t, strings.Join([]string{
+		"$res.$status == 200 不成立:\n  期望: 200\n  实际: 500",
+		"$res.$body.$json.msg == \"ok\" 不成立:\n  期望: \"ok\"\n  实际: \"fail\"",
+		"$res.$body.$json.count < 2 不成立:\n  期望: < 2\n  实际: 3",
+		"$res.$body.$json.data == $env.data 不成立:\n  - $.extra: true\n  + $.items[1]: 2\n  ~ $.name: \"b\" -> \"a\"",
+		"$res.$body.$json.text == \"" + long + "c\" 不成立:\n" +
+			"  期望: ...\"" + strings.Repeat("a", 40) + "c\"\n" +
+			"  实际: ...\"" + strings.Repeat("a", 40) + "b\"",
+		"@include($res.$body.$str, \"ok\") 不成立",
+		"$status(200) 不成立:\n  实际: 500",
+	}, "\n"), result.Error)
+}
+
+func TestFormatDiff(t *testing.T) {
+	entries := []diffEntry{}
+	for i := 0; i < maxDiffEntries+5; i++ {
+		entries = append(entries, diffEntry{kind: diffAdded, path: "$.a", actual: strings.Repeat("x", maxDiffValueLength+10)})
+	}
+	lines := strings.Split(formatDiff(entries), "\n")
+	require.Len(t, lines, maxDiffEntries+1)
+	assert.Equal(t, "... 还有5处不同", lines[maxDiffEntries])
+	assert.True(t, strings.HasSuffix(lines[0], "...(共212个字符)"), lines[0])
+
+	assert.Equal(t, "\033[31m  - $.a: 1\033[0m\n\033[32m+ $.b: 2\033[0m\n\033[33m~ $.c: 1 -> 2\033[0m\n期望", colorizeDiff("  - $.a: 1\n+ $.b: 2\n~ $.c: 1 -> 2\n期望"))
+}
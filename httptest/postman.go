@@ -0,0 +1,676 @@
+package httptest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+////////////////////
+// postman collection v2.1
+// 1、item可以嵌套(folder), 请求名称为 folder/name
+// 2、collection、folder上的variable作为{{var}}的默认值, 环境文件以及运行时设置的变量优先
+// 3、auth沿着 request -> folder -> collection 继承
+// 4、prerequest、test脚本翻译为DSL的event与expect, 见postman_script.go
+// 5、url优先使用protocol、host、port、path、query拼接, 不存在host时使用raw
+// 6、Only、Skip按照请求名称(name或者folder/name)筛选, 不执行的请求记录为跳过
+// 7、tags为扩展字段, folder的标签由其中的请求继承, FilterTags按照标签表达式筛选请求
+////////////////////
+
+type PostmanSpecInfo struct {
+	Info struct {
+		PostmanId string `json:"_postman_id,omitempty"`
+		Name      string `json:"name"`
+		Schema    string `json:"schema,omitempty"`
+	} `json:"info"`
+	Item     []*PostmanItem     `json:"item"`
+	Variable []*PostmanVariable `json:"variable,omitempty"`
+	Auth     *PostmanAuth       `json:"auth,omitempty"`
+	Event    []*PostmanEvent    `json:"event,omitempty"`
+
+	dir            string
+	environment    *PostmanEnvironment
+	exportWarnings []string // 由spec导出时无法导出的内容
+
+	onlyNames map[string]bool // 只执行这些名称的请求
+	skipNames map[string]bool // 跳过这些名称的请求
+	tagExpr   string          // 标签表达式, 为空时不筛选
+}
+
+// item存在子item时为folder, 否则为请求
+type PostmanItem struct {
+	Name     string             `json:"name"`
+	Tags     []string           `json:"tags,omitempty"` // 标签, folder的标签由其中的请求继承
+	Item     []*PostmanItem     `json:"item,omitempty"`
+	Variable []*PostmanVariable `json:"variable,omitempty"`
+	Auth     *PostmanAuth       `json:"auth,omitempty"`
+	Event    []*PostmanEvent    `json:"event,omitempty"`
+	Request  PostmanRequest     `json:"request"`
+	Response []*PostmanResponse `json:"response"`
+}
+
+type PostmanVariable struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type,omitempty"`
+	Disabled bool        `json:"disabled,omitempty"`
+	Enabled  *bool       `json:"enabled,omitempty"` // 环境文件中使用enabled
+}
+
+type PostmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec PostmanScriptExec `json:"exec"`
+		Type string            `json:"type"`
+	} `json:"script"`
+}
+
+// exec可以为字符串或者字符串数组
+type PostmanScriptExec []string
+
+type PostmanRequest struct {
+	Auth   *PostmanAuth     `json:"auth,omitempty"`
+	Method string           `json:"method"`
+	Header []*PostmanHeader `json:"header,omitempty"`
+	Body   *PostmanBody     `json:"body,omitempty"`
+	Url    PostmanUrl       `json:"url"`
+}
+
+type PostmanHeader struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Type     string `json:"type,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+type PostmanBody struct {
+	Mode       string             `json:"mode"`
+	Raw        string             `json:"raw,omitempty"`
+	Urlencoded []*PostmanFormItem `json:"urlencoded,omitempty"`
+	Formdata   []*PostmanFormItem `json:"formdata,omitempty"`
+	File       *struct {
+		Src string `json:"src,omitempty"`
+	} `json:"file,omitempty"`
+	Graphql *struct {
+		Query     string `json:"query,omitempty"`
+		Variables string `json:"variables,omitempty"`
+	} `json:"graphql,omitempty"`
+	Options *struct {
+		Raw struct {
+			Language string `json:"language,omitempty"`
+		} `json:"raw"`
+	} `json:"options,omitempty"`
+}
+
+type PostmanFormItem struct {
+	Key         string      `json:"key"`
+	Value       string      `json:"value,omitempty"`
+	Type        string      `json:"type,omitempty"`
+	Src         interface{} `json:"src,omitempty"`
+	ContentType string      `json:"contentType,omitempty"`
+	Disabled    bool        `json:"disabled,omitempty"`
+}
+
+// url可以为字符串或者对象
+type PostmanUrl struct {
+	Raw      string              `json:"raw"`
+	Protocol string              `json:"protocol,omitempty"`
+	Host     []string            `json:"host,omitempty"`
+	Port     string              `json:"port,omitempty"`
+	Path     []string            `json:"path,omitempty"`
+	Query    []*PostmanQueryItem `json:"query,omitempty"`
+	Hash     string              `json:"hash,omitempty"`
+	Variable []*PostmanVariable  `json:"variable,omitempty"`
+}
+
+type PostmanQueryItem struct {
+	Key      string  `json:"key"`
+	Value    *string `json:"value"`
+	Disabled bool    `json:"disabled,omitempty"`
+}
+
+type PostmanAuth struct {
+	Type   string              `json:"type"`
+	Bearer []*PostmanAuthParam `json:"bearer,omitempty"`
+	Basic  []*PostmanAuthParam `json:"basic,omitempty"`
+	Apikey []*PostmanAuthParam `json:"apikey,omitempty"`
+}
+
+type PostmanAuthParam struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+	Type  string      `json:"type,omitempty"`
+}
+
+// 保存的响应示例
+type PostmanResponse struct {
+	Name            string           `json:"name"`
+	OriginalRequest *PostmanRequest  `json:"originalRequest,omitempty"`
+	Status          string           `json:"status,omitempty"`
+	Code            int              `json:"code,omitempty"`
+	PreviewLanguage string           `json:"_postman_previewlanguage,omitempty"`
+	Header          []*PostmanHeader `json:"header"`
+	Body            string           `json:"body"`
+}
+
+// postman环境文件
+type PostmanEnvironment struct {
+	Name   string             `json:"name"`
+	Values []*PostmanVariable `json:"values"`
+}
+
+func (e *PostmanScriptExec) UnmarshalJSON(data []byte) error {
+	var line string
+	if err := json.Unmarshal(data, &line); err == nil {
+		*e = strings.Split(line, "\n")
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*e = lines
+	return nil
+}
+
+func (r *PostmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*r = PostmanRequest{Method: "GET", Url: PostmanUrl{Raw: raw}}
+		return nil
+	}
+	type request PostmanRequest
+	var res request
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	*r = PostmanRequest(res)
+	return nil
+}
+
+func (u *PostmanUrl) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = PostmanUrl{Raw: raw}
+		return nil
+	}
+	type postmanUrl PostmanUrl
+	var res postmanUrl
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	*u = PostmanUrl(res)
+	return nil
+}
+
+// 存在host时使用各个部分拼接, 这样disabled的query以及:var形式的路径变量可以被正确处理
+func (u *PostmanUrl) String() string {
+	if len(u.Host) == 0 {
+		return u.Raw
+	}
+
+	var b strings.Builder
+	if u.Protocol != "" {
+		b.WriteString(u.Protocol + "://")
+	}
+	b.WriteString(strings.Join(u.Host, "."))
+	if u.Port != "" {
+		b.WriteString(":" + u.Port)
+	}
+	for _, segment := range u.Path {
+		if strings.HasPrefix(segment, ":") {
+			for _, variable := range u.Variable {
+				if variable.Key == segment[1:] {
+					segment = fmt.Sprint(variable.Value)
+					break
+				}
+			}
+		}
+		b.WriteString("/" + segment)
+	}
+
+	query := []string{}
+	for _, item := range u.Query {
+		if item.Disabled {
+			continue
+		}
+		if item.Value == nil {
+			query = append(query, item.Key)
+		} else {
+			query = append(query, item.Key+"="+*item.Value)
+		}
+	}
+	if len(query) > 0 {
+		b.WriteString("?" + strings.Join(query, "&"))
+	}
+	if u.Hash != "" {
+		b.WriteString("#" + u.Hash)
+	}
+	return b.String()
+}
+
+func (v *PostmanVariable) enabled() bool {
+	if v.Enabled != nil {
+		return *v.Enabled
+	}
+	return !v.Disabled
+}
+
+func (a *PostmanAuth) param(params []*PostmanAuthParam, key string) string {
+	for _, item := range params {
+		if item.Key == key {
+			return fmt.Sprint(item.Value)
+		}
+	}
+	return ""
+}
+
+func NewPostmanSpecInfo(data []byte, patch func(item *PostmanItem)) (*PostmanSpecInfo, error) {
+	var res PostmanSpecInfo
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	if patch != nil {
+		for _, item := range res.requests() {
+			patch(item.item)
+		}
+	}
+	return &res, nil
+}
+
+// 从文件中加载, 文件上传等相对路径基于该文件所在目录
+func NewPostmanSpecInfoFromFile(path string, patch func(item *PostmanItem)) (*PostmanSpecInfo, error) {
+	data, dir, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res, err := NewPostmanSpecInfo(data, patch)
+	if err != nil {
+		return nil, err
+	}
+	res.dir = dir
+	return res, nil
+}
+
+func NewPostmanEnvironment(data []byte) (*PostmanEnvironment, error) {
+	var res PostmanEnvironment
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func NewPostmanEnvironmentFromFile(path string) (*PostmanEnvironment, error) {
+	data, _, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostmanEnvironment(data)
+}
+
+// 环境文件: json对象, 或者postman环境文件(只使用启用的变量)
+func NewEnvFromFile(path string) (map[string]interface{}, error) {
+	data, _, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := res["values"].([]interface{}); !ok {
+		return res, nil
+	}
+	env, err := NewPostmanEnvironment(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	res = map[string]interface{}{}
+	for _, item := range env.Values {
+		if item.enabled() {
+			res[item.Key] = item.Value
+		}
+	}
+	return res, nil
+}
+
+// 运行时使用的环境, 优先级高于collection中的变量
+func (s *PostmanSpecInfo) WithEnvironment(env *PostmanEnvironment) *PostmanSpecInfo {
+	s.environment = env
+	return s
+}
+
+// 只执行这些名称的请求, 其他请求记录为跳过
+func (s *PostmanSpecInfo) Only(names ...string) *PostmanSpecInfo {
+	if s.onlyNames == nil {
+		s.onlyNames = map[string]bool{}
+	}
+	for _, name := range names {
+		s.onlyNames[name] = true
+	}
+	return s
+}
+
+// 跳过这些名称的请求
+func (s *PostmanSpecInfo) Skip(names ...string) *PostmanSpecInfo {
+	if s.skipNames == nil {
+		s.skipNames = map[string]bool{}
+	}
+	for _, name := range names {
+		s.skipNames[name] = true
+	}
+	return s
+}
+
+// 按照标签表达式筛选请求, 不满足的请求不执行也不记录, 表达式错误时StartHandle返回错误
+func (s *PostmanSpecInfo) FilterTags(expr string) *PostmanSpecInfo {
+	s.tagExpr = expr
+	return s
+}
+
+// 返回跳过的原因, 执行时为空
+func (s *PostmanSpecInfo) skipReason(req *postmanRequest) string {
+	switch {
+	case s.skipNames[req.name] || s.skipNames[req.item.Name]:
+		return "被指定跳过"
+	case len(s.onlyNames) > 0 && !s.onlyNames[req.name] && !s.onlyNames[req.item.Name]:
+		return "未被指定执行"
+	}
+	return ""
+}
+
+func (s *PostmanSpecInfo) StartHandle(t TestingT) error {
+	return s.StartHandleWithContext(t, NewHttpContext())
+}
+
+// 使用指定的ctx执行, 环境文件中的变量会写入ctx
+func (s *PostmanSpecInfo) StartHandleWithContext(t TestingT, ctx *HttpContext) error {
+	matcher, err := compileTagExpr(s.tagExpr)
+	if err != nil {
+		return err
+	}
+	if s.environment != nil {
+		for _, item := range s.environment.Values {
+			if item.enabled() {
+				ctx.Setenv(item.Key, item.Value)
+			}
+		}
+	}
+
+	for _, warning := range s.Warnings() {
+		t.Log(warning)
+	}
+
+	for _, item := range s.requests() {
+		if !matcher.match(item.tags) {
+			continue
+		}
+		if reason := s.skipReason(item); reason != "" {
+			ctx.skip(item.name, &BasicItem{Method: item.item.Request.Method, Url: item.item.Request.Url.String(), Tags: item.tags}, reason)
+			continue
+		}
+		// prerequest脚本中的变量设置在构造请求前执行
+		preEvent, expect, event := item.scripts()
+		if !ParserHandleEvent(ctx, preEvent) {
+			return fmt.Errorf("%s: prerequest执行失败", item.name)
+		}
+
+		opt, err := s.specReq2option(ctx, item)
+		if err != nil {
+			return err
+		}
+		opt.Expect = expect
+		opt.Event = event
+		opt.Tags = item.tags
+		ctx.Do(t, item.name, opt)
+	}
+	return nil
+}
+
+// 脚本中无法翻译为DSL的语句, 以及由spec导出时无法导出的内容
+func (s *PostmanSpecInfo) Warnings() []string {
+	res := append([]string{}, s.exportWarnings...)
+	collect := func(name string, events []*PostmanEvent) {
+		for _, event := range events {
+			for _, warning := range translatePostmanScript(event.Listen, event.Script.Exec).Warnings {
+				res = append(res, fmt.Sprintf("%s(%s) %s", name, event.Listen, warning))
+			}
+		}
+	}
+
+	collect(s.Info.Name, s.Event)
+	var walk func(prefix string, items []*PostmanItem)
+	walk = func(prefix string, items []*PostmanItem) {
+		for _, item := range items {
+			collect(prefix+item.Name, item.Event)
+			if item.Item != nil {
+				walk(prefix+item.Name+"/", item.Item)
+			}
+		}
+	}
+	walk("", s.Item)
+	return res
+}
+
+// 展开后的请求, 携带从collection、folder继承的变量、认证信息以及脚本
+type postmanRequest struct {
+	name      string
+	item      *PostmanItem
+	auth      *PostmanAuth
+	variables map[string]interface{}
+	events    []*PostmanEvent
+	tags      []string
+}
+
+// 按collection -> folder -> request的顺序翻译脚本
+func (r *postmanRequest) scripts() (preEvent []string, expect []string, event []string) {
+	for _, item := range r.events {
+		script := translatePostmanScript(item.Listen, item.Script.Exec)
+		switch item.Listen {
+		case "prerequest":
+			preEvent = append(preEvent, script.Event...)
+		case "test":
+			expect = append(expect, script.Expect...)
+			event = append(event, script.Event...)
+		}
+	}
+	return preEvent, expect, event
+}
+
+// 深度优先展开folder
+func (s *PostmanSpecInfo) requests() []*postmanRequest {
+	variables := map[string]interface{}{}
+	for _, item := range s.Variable {
+		if item.enabled() {
+			variables[item.Key] = item.Value
+		}
+	}
+
+	res := []*postmanRequest{}
+	var walk func(prefix string, items []*PostmanItem, auth *PostmanAuth, variables map[string]interface{}, events []*PostmanEvent, tags []string)
+	walk = func(prefix string, items []*PostmanItem, auth *PostmanAuth, variables map[string]interface{}, events []*PostmanEvent, tags []string) {
+		for _, item := range items {
+			curAuth := auth
+			if item.Auth != nil {
+				curAuth = item.Auth
+			}
+			curVariables := variables
+			if len(item.Variable) > 0 {
+				curVariables = map[string]interface{}{}
+				for key, value := range variables {
+					curVariables[key] = value
+				}
+				for _, variable := range item.Variable {
+					if variable.enabled() {
+						curVariables[variable.Key] = variable.Value
+					}
+				}
+			}
+			curEvents := append(append([]*PostmanEvent{}, events...), item.Event...)
+			curTags := mergeUnique(tags, item.Tags)
+
+			if item.Item != nil {
+				walk(prefix+item.Name+"/", item.Item, curAuth, curVariables, curEvents, curTags)
+				continue
+			}
+			if item.Request.Auth != nil {
+				curAuth = item.Request.Auth
+			}
+			res = append(res, &postmanRequest{
+				name:      prefix + item.Name,
+				item:      item,
+				auth:      curAuth,
+				variables: curVariables,
+				events:    curEvents,
+				tags:      curTags,
+			})
+		}
+	}
+	walk("", s.Item, s.Auth, variables, s.Event, nil)
+	return res
+}
+
+func (s *PostmanSpecInfo) specReq2option(ctx *HttpContext, req *postmanRequest) (*HandleOption, error) {
+	request := req.item.Request
+	render := func(value string) string {
+		return ctx.render(value, req.variables)
+	}
+
+	header := map[string]string{}
+	for _, item := range request.Header {
+		if !item.Disabled {
+			header[item.Key] = render(item.Value)
+		}
+	}
+
+	rawUrl := request.Url.String()
+	if req.auth != nil {
+		switch req.auth.Type {
+		case "bearer":
+			header["Authorization"] = "Bearer " + render(req.auth.param(req.auth.Bearer, "token"))
+		case "basic":
+			userpass := render(req.auth.param(req.auth.Basic, "username")) + ":" + render(req.auth.param(req.auth.Basic, "password"))
+			header["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(userpass))
+		case "apikey":
+			key := render(req.auth.param(req.auth.Apikey, "key"))
+			value := render(req.auth.param(req.auth.Apikey, "value"))
+			if req.auth.param(req.auth.Apikey, "in") == "query" {
+				query := url.QueryEscape(key) + "=" + url.QueryEscape(value)
+				if strings.Contains(rawUrl, "?") {
+					rawUrl += "&" + query
+				} else {
+					rawUrl += "?" + query
+				}
+			} else {
+				header[key] = value
+			}
+		}
+	}
+
+	body, contentType, err := s.requestBody(request.Body, render)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", req.name, err)
+	}
+
+	method := request.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	return &HandleOption{
+		Url:         render(rawUrl),
+		Method:      method,
+		ContentType: contentType,
+		Header:      header,
+		Body:        body,
+	}, nil
+}
+
+func (s *PostmanSpecInfo) requestBody(body *PostmanBody, render func(string) string) (io.Reader, string, error) {
+	if body == nil {
+		return strings.NewReader(""), "", nil
+	}
+
+	switch body.Mode {
+	case "urlencoded":
+		fields := []*FormField{}
+		for _, field := range body.Urlencoded {
+			if !field.Disabled {
+				fields = append(fields, &FormField{Key: render(field.Key), Value: render(field.Value)})
+			}
+		}
+		reader, contentType := NewUrlencodedBody(fields)
+		return reader, contentType, nil
+	case "formdata":
+		fields := []*FormField{}
+		for _, field := range body.Formdata {
+			if field.Disabled {
+				continue
+			}
+			formField := &FormField{Key: render(field.Key), Value: render(field.Value), ContentType: field.ContentType}
+			if field.Type == "file" {
+				formField.File = postmanSrc(field.Src)
+			}
+			fields = append(fields, formField)
+		}
+		return NewMultipartBody(s.dir, fields)
+	case "file":
+		if body.File == nil {
+			return strings.NewReader(""), "", nil
+		}
+		return NewBinaryBody(s.dir, body.File.Src)
+	case "graphql":
+		if body.Graphql == nil {
+			return strings.NewReader(""), "application/json", nil
+		}
+		payload := map[string]interface{}{"query": render(body.Graphql.Query)}
+		if variables := strings.TrimSpace(render(body.Graphql.Variables)); variables != "" {
+			var v interface{}
+			if err := json.Unmarshal([]byte(variables), &v); err != nil {
+				return nil, "", err
+			}
+			payload["variables"] = v
+		}
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, "", err
+		}
+		return strings.NewReader(string(data)), "application/json", nil
+	}
+
+	contentType := ""
+	language := ""
+	if body.Options != nil {
+		language = body.Options.Raw.Language
+	}
+	switch language {
+	case "json":
+		contentType = "application/json"
+	case "xml":
+		contentType = "application/xml"
+	case "html":
+		contentType = "text/html"
+	case "javascript":
+		contentType = "application/javascript"
+	case "text":
+		contentType = "text/plain"
+	}
+	return strings.NewReader(render(body.Raw)), contentType, nil
+}
+
+// postman formdata中的src可能为字符串或者字符串数组, 只取第一个文件
+func postmanSrc(src interface{}) string {
+	switch src := src.(type) {
+	case string:
+		return src
+	case []interface{}:
+		if len(src) > 0 {
+			return fmt.Sprint(src[0])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,406 @@
+package httptest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/wwqdrh/easytest/httptest/internal"
+)
+
+////////////////////
+// 将spec导出为postman collection v2.1
+// 1、每个item对应一个请求, {{ }}变量原样保留
+// 2、expect翻译为pm.test, event翻译为pm.environment.set
+// 3、无法翻译的表达式以注释的形式保留在脚本中
+// 4、无法导出的请求体(例如二进制的base64)以及钩子记录在Warnings中
+////////////////////
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+func (s *BasicSpecInfo) ToPostman() *PostmanSpecInfo {
+	res := &PostmanSpecInfo{Item: []*PostmanItem{}}
+	res.Info.Schema = postmanSchema
+	for _, item := range *s {
+		// 钩子在postman中没有对应的执行顺序, 不导出
+		if item.Hook != "" {
+			res.exportWarnings = append(res.exportWarnings, fmt.Sprintf("%s 钩子(%s)无法导出, 导出时忽略", item.Name, item.Hook))
+			continue
+		}
+		postmanItem, warnings := item.toPostman()
+		res.Item = append(res.Item, postmanItem)
+		for _, warning := range warnings {
+			res.exportWarnings = append(res.exportWarnings, fmt.Sprintf("%s %s", item.Name, warning))
+		}
+	}
+	return res
+}
+
+func (s *BasicParserSpecInfo) ToPostman() *PostmanSpecInfo {
+	return (*BasicSpecInfo)(s).ToPostman()
+}
+
+func (item *BasicItem) toPostman() (*PostmanItem, []string) {
+	warnings := []string{}
+	request := PostmanRequest{
+		Method: strings.ToUpper(item.Method),
+		Url:    PostmanUrl{Raw: item.Url},
+	}
+	if request.Method == "" {
+		request.Method = "GET"
+	}
+
+	hasContentType := false
+	for _, header := range item.Header {
+		pairs := strings.SplitN(header, ":", 2)
+		if len(pairs) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(pairs[0])
+		if strings.EqualFold(key, "Content-Type") {
+			hasContentType = true
+		}
+		request.Header = append(request.Header, &PostmanHeader{Key: key, Value: strings.TrimSpace(pairs[1]), Type: "text"})
+	}
+
+	body := &PostmanBody{}
+	switch strings.ToLower(item.BodyMode) {
+	case BodyModeUrlencoded:
+		body.Mode = "urlencoded"
+		for _, field := range item.Form {
+			body.Urlencoded = append(body.Urlencoded, &PostmanFormItem{Key: field.Key, Value: field.Value, Type: "text"})
+		}
+	case BodyModeMultipart:
+		body.Mode = "formdata"
+		for _, field := range item.Form {
+			formItem := &PostmanFormItem{Key: field.Key, Value: field.Value, Type: "text", ContentType: field.ContentType}
+			if field.File != "" {
+				formItem.Type = "file"
+				formItem.Src = field.File
+			}
+			body.Formdata = append(body.Formdata, formItem)
+		}
+	case BodyModeBinary:
+		body.Mode = "file"
+		body.File = &struct {
+			Src string `json:"src,omitempty"`
+		}{Src: item.File}
+	case BodyModeBase64:
+		// postman不支持base64, 解码后为文本时使用raw
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(item.Body))
+		switch {
+		case err != nil:
+			warnings = append(warnings, "base64请求体解码失败, 导出时忽略: "+err.Error())
+		case !utf8.Valid(data):
+			warnings = append(warnings, "base64请求体为二进制内容, postman不支持, 导出时忽略")
+		default:
+			body.Mode = "raw"
+			body.Raw = string(data)
+		}
+	default:
+		if item.Body != "" {
+			body.Mode = "raw"
+			body.Raw = item.Body
+		}
+	}
+	if body.Mode == "raw" && strings.Contains(item.ContentType, "json") {
+		body.Options = &struct {
+			Raw struct {
+				Language string `json:"language,omitempty"`
+			} `json:"raw"`
+		}{}
+		body.Options.Raw.Language = "json"
+	}
+	if body.Mode != "" {
+		request.Body = body
+	}
+	// multipart的content-type由postman生成
+	if item.ContentType != "" && !hasContentType && body.Mode != "formdata" {
+		request.Header = append(request.Header, &PostmanHeader{Key: "Content-Type", Value: item.ContentType, Type: "text"})
+	}
+
+	res := &PostmanItem{
+		Name:     item.Name,
+		Tags:     item.Tags,
+		Request:  request,
+		Response: []*PostmanResponse{},
+	}
+	if exec := dslToPostmanScript(item.Expect, item.Event); len(exec) > 0 {
+		event := &PostmanEvent{Listen: "test"}
+		event.Script.Type = "text/javascript"
+		event.Script.Exec = exec
+		res.Event = append(res.Event, event)
+	}
+	return res, warnings
+}
+
+// expect与event翻译为postman脚本
+func dslToPostmanScript(expect []string, event []string) []string {
+	exec := []string{}
+	for _, item := range expect {
+		assertion, ok := dslExpectToJs(item)
+		if !ok {
+			exec = append(exec, "// 无法转换: "+item)
+			continue
+		}
+		exec = append(exec,
+			fmt.Sprintf("pm.test(%s, function () {", jsQuote(item)),
+			"    "+assertion+";",
+			"});",
+		)
+	}
+	for _, item := range event {
+		statement, ok := dslEventToJs(item)
+		if !ok {
+			exec = append(exec, "// 无法转换: "+item)
+			continue
+		}
+		exec = append(exec, statement)
+	}
+	return exec
+}
+
+func dslExpectToJs(source string) (string, bool) {
+	source = strings.TrimSpace(source)
+	// 简写版本
+	if strings.HasPrefix(source, "$contains(") && strings.HasSuffix(source, ")") {
+		parts := strings.SplitN(source[len("$contains("):len(source)-1], ",", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != "$res" {
+			return "", false
+		}
+		return fmt.Sprintf("pm.expect(pm.response.text()).to.include(%s)", jsQuote(strings.TrimSpace(parts[1]))), true
+	}
+	if strings.HasPrefix(source, "$status(") && strings.HasSuffix(source, ")") {
+		return fmt.Sprintf("pm.response.to.have.status(%s)", strings.TrimSpace(source[len("$status("):len(source)-1])), true
+	}
+
+	node, err := parseDsl(source)
+	if err != nil {
+		return "", false
+	}
+	if node.Type == "expression" && (node.Name == "==" || node.Name == "!=") {
+		left, okLeft := dslNodeToJs(node.Params[0])
+		right, okRight := dslNodeToJs(node.Params[1])
+		if !okLeft || !okRight {
+			return "", false
+		}
+		if node.Name == "==" && left == "pm.response.code" {
+			return fmt.Sprintf("pm.response.to.have.status(%s)", right), true
+		}
+		if node.Name == "==" {
+			return fmt.Sprintf("pm.expect(%s).to.eql(%s)", left, right), true
+		}
+		return fmt.Sprintf("pm.expect(%s).to.not.eql(%s)", left, right), true
+	}
+
+	if node.Type == "callable" && (node.Name == "@include" || node.Name == "@contain") && len(node.Params) == 2 {
+		target, okTarget := dslNodeToJs(node.Params[0])
+		sub, okSub := dslContainArg(node.Name, node.Params[1])
+		if okTarget && okSub {
+			return fmt.Sprintf("pm.expect(%s).to.include(%s)", target, sub), true
+		}
+	}
+
+	js, ok := dslNodeToJs(node)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("pm.expect(%s).to.be.ok", js), true
+}
+
+// @contain查找的是带引号的字符串形式, 字面量直接展开
+func dslContainArg(name string, node *internal.SyntaxNode) (string, bool) {
+	if name == "@contain" && (node.Type == "literial" || node.Type == "variable") {
+		return jsQuote(fmt.Sprintf("%#v", node.Value)), true
+	}
+	sub, ok := dslNodeToJs(node)
+	if ok && name == "@contain" {
+		sub = "JSON.stringify(" + sub + ")"
+	}
+	return sub, ok
+}
+
+func dslEventToJs(source string) (string, bool) {
+	source = strings.TrimSpace(source)
+	// 简写版本 $env.a=$json.b.c, 与HandleEvent的判断相同
+	pairs := strings.SplitN(source, "=", 2)
+	if strings.HasPrefix(source, "$env") && len(pairs) == 2 && isJsonPath(strings.TrimSpace(pairs[1])) {
+		left := strings.SplitN(strings.TrimSpace(pairs[0]), ".", 2)
+		if len(left) != 2 {
+			return "", false
+		}
+		js := "pm.response.json()"
+		for _, item := range strings.Split(strings.TrimPrefix(strings.TrimSpace(pairs[1]), "$json."), ".") {
+			js += jsMember(item)
+		}
+		return fmt.Sprintf("pm.environment.set(%s, %s);", jsQuote(strings.TrimSpace(left[1])), js), true
+	}
+
+	node, err := parseDsl(source)
+	if err != nil {
+		return "", false
+	}
+	return dslStatementToJs(node)
+}
+
+func dslStatementToJs(node *internal.SyntaxNode) (string, bool) {
+	if node.Type == "callable" && node.Name == "@if" && len(node.Params) == 2 {
+		cond, ok := dslNodeToJs(node.Params[0])
+		if !ok {
+			return "", false
+		}
+		statement, ok := dslStatementToJs(node.Params[1])
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("if (%s) { %s }", cond, statement), true
+	}
+	if node.Type == "expression" && node.Name == "=" {
+		key, ok := dslEnvKey(node.Params[0])
+		if !ok {
+			return "", false
+		}
+		value, ok := dslNodeToJs(node.Params[1])
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("pm.environment.set(%s, %s);", jsQuote(key), value), true
+	}
+	return "", false
+}
+
+// $env.key中的key
+func dslEnvKey(node *internal.SyntaxNode) (string, bool) {
+	if node.Type != "expression" || node.Name != "." || len(node.Params) != 2 {
+		return "", false
+	}
+	if base := node.Params[0]; base.Type != "global" || base.Name != "$env" {
+		return "", false
+	}
+	return dslAttrName(node.Params[1])
+}
+
+func dslAttrName(node *internal.SyntaxNode) (string, bool) {
+	switch node.Type {
+	case "variable", "literial":
+		return fmt.Sprint(node.Value), true
+	case "attr", "global":
+		return node.Name, true
+	}
+	return "", false
+}
+
+func dslNodeToJs(node *internal.SyntaxNode) (string, bool) {
+	switch node.Type {
+	case "literial", "variable":
+		data, err := json.Marshal(node.Value)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	case "callable":
+		params := []string{}
+		for _, item := range node.Params {
+			param, ok := dslNodeToJs(item)
+			if !ok {
+				return "", false
+			}
+			params = append(params, param)
+		}
+		switch {
+		case node.Name == "@include" && len(params) == 2:
+			return fmt.Sprintf("%s.includes(%s)", params[0], params[1]), true
+		case node.Name == "@contain" && len(params) == 2:
+			return fmt.Sprintf("%s.includes(JSON.stringify(%s))", params[0], params[1]), true
+		case node.Name == "@if" && len(params) == 2:
+			return fmt.Sprintf("(%s ? %s : undefined)", params[0], params[1]), true
+		}
+		return "", false
+	case "expression":
+		switch node.Name {
+		case ".":
+			return dslPathToJs(node)
+		case "!":
+			operand, ok := dslNodeToJs(node.Params[0])
+			return "!(" + operand + ")", ok
+		case "==", "!=", "<", "<=", ">", ">=", "&&", "||":
+			left, okLeft := dslNodeToJs(node.Params[0])
+			right, okRight := dslNodeToJs(node.Params[1])
+			op := node.Name
+			if op == "==" || op == "!=" {
+				op += "="
+			}
+			return fmt.Sprintf("(%s %s %s)", left, op, right), okLeft && okRight
+		}
+	}
+	return "", false
+}
+
+// 取值链: $res.$body.$json.a.b => pm.response.json().a.b
+func dslPathToJs(node *internal.SyntaxNode) (string, bool) {
+	segments := []string{}
+	cur := node
+	for cur.Type == "expression" && cur.Name == "." {
+		name, ok := dslAttrName(cur.Params[1])
+		if !ok {
+			return "", false
+		}
+		segments = append([]string{name}, segments...)
+		cur = cur.Params[0]
+	}
+	if cur.Type != "global" {
+		return "", false
+	}
+
+	js := ""
+	switch {
+	case cur.Name == "$env" && len(segments) > 0:
+		js = fmt.Sprintf("pm.environment.get(%s)", jsQuote(segments[0]))
+		segments = segments[1:]
+	case cur.Name == "$res" && len(segments) >= 2 && segments[0] == "$body" && segments[1] == "$json":
+		js = "pm.response.json()"
+		segments = segments[2:]
+	case cur.Name == "$res" && len(segments) >= 2 && segments[0] == "$body" && segments[1] == "$str":
+		js = "pm.response.text()"
+		segments = segments[2:]
+	case cur.Name == "$res" && len(segments) >= 1 && segments[0] == "$status":
+		js = "pm.response.code"
+		segments = segments[1:]
+	case cur.Name == "$res" && len(segments) >= 2 && segments[0] == "$header":
+		js = fmt.Sprintf("pm.response.headers.get(%s)", jsQuote(segments[1]))
+		segments = segments[2:]
+	default:
+		return "", false
+	}
+
+	for _, item := range segments {
+		js += jsMember(item)
+	}
+	return js, true
+}
+
+func jsMember(name string) string {
+	if scriptIdentReg.MatchString(name) {
+		return "." + name
+	}
+	if scriptNumberReg.MatchString(name) {
+		return "[" + name + "]"
+	}
+	return "[" + jsQuote(name) + "]"
+}
+
+func jsQuote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func parseDsl(source string) (*internal.SyntaxNode, error) {
+	node, err := internal.NewSimpleParser(internal.NewLexer(source)).Parse()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return node, nil
+}
@@ -0,0 +1,138 @@
+package httptest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicSpecToPostman(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/user/userinfo" {
+			if r.Header.Get("Authorization") != "bearer 132" {
+				w.WriteHeader(500)
+				return
+			}
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"msg":         "ok",
+			"accessToken": "132",
+		})
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	data, err := ioutil.ReadFile("./testdata/gomall.parser_collection.json")
+	require.Nil(t, err)
+	specInfo, err := NewBasicParserSpecInfo(data, func(item *BasicItem) {
+		item.Url = "{{baseUrl}}" + getPath(item.Url)
+	})
+	require.Nil(t, err)
+	(*specInfo)[2].Expect = append((*specInfo)[2].Expect, `$res.$status == 200`, `$res.$body.$json.msg != "fail"`)
+
+	collection := specInfo.ToPostman()
+	assert.Equal(t, postmanSchema, collection.Info.Schema)
+	require.Len(t, collection.Item, 3)
+
+	login := collection.Item[1]
+	assert.Equal(t, "POST", login.Request.Method)
+	assert.Equal(t, "{{baseUrl}}/api/user/login", login.Request.Url.Raw)
+	assert.Equal(t, "json", login.Request.Body.Options.Raw.Language)
+	assert.Equal(t, []string{
+		`pm.test("@contain($res.$body.$str, \"ok\")", function () {`,
+		`    pm.expect(pm.response.text()).to.include("\"ok\"");`,
+		`});`,
+		`pm.environment.set("token", pm.response.json().accessToken);`,
+	}, []string(login.Event[0].Script.Exec))
+
+	info := collection.Item[2]
+	assert.Equal(t, "Authorization", info.Request.Header[0].Key)
+	assert.Equal(t, "bearer {{ token }}", info.Request.Header[0].Value)
+	assert.Contains(t, info.Event[0].Script.Exec, `    pm.response.to.have.status(200);`)
+	assert.Contains(t, info.Event[0].Script.Exec, `    pm.expect(pm.response.json().msg).to.not.eql("fail");`)
+
+	// 导出的collection可以被重新导入并执行
+	exported, err := json.Marshal(collection)
+	require.Nil(t, err)
+	imported, err := NewPostmanSpecInfo(exported, nil)
+	require.Nil(t, err)
+	assert.Empty(t, imported.Warnings())
+	imported.WithEnvironment(&PostmanEnvironment{Values: []*PostmanVariable{{Key: "baseUrl", Value: ts.URL}}})
+	require.Nil(t, imported.StartHandle(t))
+}
+
+func TestBasicItemToPostmanBody(t *testing.T) {
+	specInfo := BasicSpecInfo{
+		{Name: "form", Url: "{{baseUrl}}/form", Method: "post", BodyMode: BodyModeUrlencoded, Form: []*FormField{{Key: "a", Value: "1"}}},
+		{Name: "upload", Url: "{{baseUrl}}/upload", Method: "post", BodyMode: BodyModeMultipart, Form: []*FormField{{Key: "avatar", File: "avatar.png"}}},
+		{Name: "binary", Url: "{{baseUrl}}/binary", Method: "put", BodyMode: BodyModeBinary, File: "avatar.png"},
+		{Name: "base64", Url: "{{baseUrl}}/base64", Method: "post", BodyMode: BodyModeBase64, Body: "aGVsbG8=", ContentType: "text/plain"},
+		{Name: "get", Url: "{{baseUrl}}/get", Expect: []string{"$status(200)", "$contains($res, ok)", "$res.$body.$json.a ="}},
+		{Name: "image", Url: "{{baseUrl}}/image", Method: "post", BodyMode: BodyModeBase64, Body: "/9j/4A=="},
+		{Name: "invalid", Url: "{{baseUrl}}/invalid", Method: "post", BodyMode: BodyModeBase64, Body: "!!"},
+	}
+
+	collection := specInfo.ToPostman()
+	assert.Equal(t, "urlencoded", collection.Item[0].Request.Body.Mode)
+	assert.Equal(t, "a", collection.Item[0].Request.Body.Urlencoded[0].Key)
+	assert.Equal(t, "formdata", collection.Item[1].Request.Body.Mode)
+	assert.Equal(t, "file", collection.Item[1].Request.Body.Formdata[0].Type)
+	assert.Equal(t, "avatar.png", collection.Item[1].Request.Body.Formdata[0].Src)
+	assert.Equal(t, "avatar.png", collection.Item[2].Request.Body.File.Src)
+	assert.Equal(t, "hello", collection.Item[3].Request.Body.Raw)
+	assert.Equal(t, "Content-Type", collection.Item[3].Request.Header[0].Key)
+	assert.Nil(t, collection.Item[4].Request.Body)
+	assert.Equal(t, "GET", collection.Item[4].Request.Method)
+	assert.Equal(t, []string{
+		`pm.test("$status(200)", function () {`,
+		`    pm.response.to.have.status(200);`,
+		`});`,
+		`pm.test("$contains($res, ok)", function () {`,
+		`    pm.expect(pm.response.text()).to.include("ok");`,
+		`});`,
+		`// 无法转换: $res.$body.$json.a =`,
+	}, []string(collection.Item[4].Event[0].Script.Exec))
+
+	// 无法导出的请求体记录为警告
+	assert.Nil(t, collection.Item[5].Request.Body)
+	assert.Nil(t, collection.Item[6].Request.Body)
+	warnings := collection.Warnings()
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "image")
+	assert.Contains(t, warnings[1], "invalid")
+}
+
+func TestBasicSpecToPostmanHooks(t *testing.T) {
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/hook/order.yaml", nil)
+	require.Nil(t, err)
+
+	// 钩子不导出, 记录为警告
+	collection := specInfo.ToPostman()
+	names := []string{}
+	for _, item := range collection.Item {
+		names = append(names, item.Name)
+	}
+	assert.Equal(t, []string{"创建订单", "失败的断言", "查询订单"}, names)
+	warnings := collection.Warnings()
+	require.Len(t, warnings, 5)
+	assert.Contains(t, warnings[0], "before_all")
+}
+
+func TestDslEventToJs(t *testing.T) {
+	cases := map[string]string{
+		"$env.id=$json.data.id":       `pm.environment.set("id", pm.response.json().data.id);`,
+		"$env.first=$json.list.0.id":  `pm.environment.set("first", pm.response.json().list[0].id);`,
+		"$env.key=$json.data.x-token": `pm.environment.set("key", pm.response.json().data["x-token"]);`,
+	}
+	for source, expected := range cases {
+		js, ok := dslEventToJs(source)
+		assert.True(t, ok, source)
+		assert.Equal(t, expected, js, source)
+	}
+}
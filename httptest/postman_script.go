@@ -0,0 +1,867 @@
+package httptest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+////////////////////
+// 将postman脚本中常用的pm.*写法翻译为DSL
+// 1、pm.response.to.have.status(200) => $res.$status == 200
+// 2、pm.expect(jsonData.x).to.eql(1) => $res.$body.$json.x == 1
+// 3、pm.environment.set("k", jsonData.x) => $env.k = $res.$body.$json.x
+// 4、if (cond) {...} 中的语句会带上条件, expect为 !(cond) || (expect), event为 @if(cond, event)
+// 无法翻译的语句作为warning返回
+////////////////////
+
+type postmanScript struct {
+	Expect   []string
+	Event    []string
+	Warnings []string
+}
+
+var (
+	scriptVarReg    = regexp.MustCompile(`^(?:var|let|const)\s+([A-Za-z_$][\w$]*)\s*=\s*(.+)$`)
+	scriptTestsReg  = regexp.MustCompile(`^tests\s*\[\s*(?:"[^"]*"|'[^']*')\s*\]\s*=\s*(.+)$`)
+	scriptSetReg    = regexp.MustCompile(`^(?:pm\.(?:environment|collectionVariables|globals|variables)\.set|postman\.set(?:Environment|Global)Variable)\(`)
+	scriptUnsetReg  = regexp.MustCompile(`^(?:pm\.(?:environment|collectionVariables|globals|variables)\.unset|postman\.clear(?:Environment|Global)Variable)\(`)
+	scriptTestReg   = regexp.MustCompile(`^pm\.test\(\s*(?:"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`[^`]*`" + `)\s*,\s*(?:function\s*\(\s*\)|\(\s*\)\s*=>)\s*`)
+	scriptIfReg     = regexp.MustCompile(`^(else\s+)?if\s*\(`)
+	scriptNumberReg = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+	scriptIdentReg  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// 语句块, cond为进入该块的条件
+type scriptBlock struct {
+	cond    string
+	invalid bool
+}
+
+type scriptTranslator struct {
+	alias  map[string]string // js变量 => DSL
+	blocks []*scriptBlock
+
+	pendingCond    string // if语句的条件, 在下一个{时入栈
+	pendingInvalid bool
+	pendingIf      bool
+	chain          string // if/else if链中已出现过的条件, 用于else
+
+	res *postmanScript
+}
+
+// listen为test时翻译expect与event, prerequest时只翻译event
+func translatePostmanScript(listen string, lines []string) *postmanScript {
+	t := &scriptTranslator{
+		alias: map[string]string{},
+		res:   &postmanScript{},
+	}
+	for _, stmt := range splitScript(strings.Join(lines, "\n")) {
+		t.statement(listen, stmt)
+	}
+	return t.res
+}
+
+func (t *scriptTranslator) warn(stmt string, reason string) {
+	t.res.Warnings = append(t.res.Warnings, fmt.Sprintf("%s: %s", reason, stmt))
+}
+
+// 当前所处的条件, invalid表示条件无法翻译
+func (t *scriptTranslator) cond() (string, bool) {
+	conds := []string{}
+	for _, block := range t.blocks {
+		if block.invalid {
+			return "", false
+		}
+		if block.cond != "" {
+			conds = append(conds, block.cond)
+		}
+	}
+	return strings.Join(conds, " && "), true
+}
+
+func (t *scriptTranslator) addExpect(listen string, stmt string, expect string) {
+	if listen != "test" {
+		t.warn(stmt, "prerequest中的断言无法翻译")
+		return
+	}
+	cond, ok := t.cond()
+	if !ok {
+		t.warn(stmt, "所在的条件无法翻译")
+		return
+	}
+	if cond != "" {
+		expect = fmt.Sprintf("!(%s) || (%s)", cond, expect)
+	}
+	t.res.Expect = append(t.res.Expect, expect)
+}
+
+func (t *scriptTranslator) addEvent(stmt string, event string) {
+	cond, ok := t.cond()
+	if !ok {
+		t.warn(stmt, "所在的条件无法翻译")
+		return
+	}
+	if cond != "" {
+		event = fmt.Sprintf("@if(%s, %s)", cond, event)
+	}
+	t.res.Event = append(t.res.Event, event)
+}
+
+func (t *scriptTranslator) statement(listen string, stmt string) {
+	switch stmt {
+	case "{":
+		t.blocks = append(t.blocks, &scriptBlock{cond: t.pendingCond, invalid: t.pendingInvalid})
+		t.pendingCond, t.pendingInvalid = "", false
+		if !t.pendingIf {
+			t.chain = ""
+		}
+		t.pendingIf = false
+		return
+	case "}":
+		if len(t.blocks) > 0 {
+			t.blocks = t.blocks[:len(t.blocks)-1]
+		}
+		return
+	case "else":
+		t.pendingIf = true
+		if t.chain == "" {
+			t.pendingInvalid = true
+			return
+		}
+		t.pendingCond = "!(" + t.chain + ")"
+		t.chain = ""
+		return
+	}
+	stmt = strings.TrimSpace(strings.TrimRight(stmt, ";"))
+	if strings.Trim(stmt, "),; ") == "" {
+		return
+	}
+	// 只有一个test包装时忽略, 箭头函数直接跟语句时翻译后面的语句
+	if loc := scriptTestReg.FindStringIndex(stmt); loc != nil {
+		rest := trimUnbalanced(strings.TrimSpace(stmt[loc[1]:]))
+		if rest != "" {
+			t.statement(listen, rest)
+		}
+		return
+	}
+	if strings.HasPrefix(stmt, "console.") {
+		return
+	}
+
+	if m := scriptIfReg.FindStringSubmatch(stmt); m != nil {
+		t.ifStatement(listen, stmt, m[1] != "", len(m[0])-1)
+		return
+	}
+
+	if m := scriptVarReg.FindStringSubmatch(stmt); m != nil {
+		val, ok := t.expr(m[2])
+		if !ok {
+			t.warn(stmt, "无法翻译的变量")
+			return
+		}
+		t.alias[m[1]] = val
+		return
+	}
+
+	if m := scriptTestsReg.FindStringSubmatch(stmt); m != nil {
+		val, ok := t.expr(m[1])
+		if !ok {
+			t.warn(stmt, "无法翻译的断言")
+			return
+		}
+		t.addExpect(listen, stmt, val)
+		return
+	}
+
+	if scriptSetReg.MatchString(stmt) {
+		args, rest, ok := callArgs(stmt[strings.Index(stmt, "("):])
+		if !ok || rest != "" || len(args) != 2 {
+			t.warn(stmt, "无法翻译的变量设置")
+			return
+		}
+		key, okKey := jsString(args[0])
+		val, okVal := t.expr(args[1])
+		if !okKey || !okVal {
+			t.warn(stmt, "无法翻译的变量设置")
+			return
+		}
+		t.addEvent(stmt, dslPath("$env", []string{key})+" = "+val)
+		return
+	}
+	if scriptUnsetReg.MatchString(stmt) {
+		args, rest, ok := callArgs(stmt[strings.Index(stmt, "("):])
+		key, okKey := "", false
+		if ok && rest == "" && len(args) == 1 {
+			key, okKey = jsString(args[0])
+		}
+		if !okKey {
+			t.warn(stmt, "无法翻译的变量设置")
+			return
+		}
+		t.addEvent(stmt, dslPath("$env", []string{key})+" = null")
+		return
+	}
+
+	if strings.HasPrefix(stmt, "pm.expect(") {
+		args, rest, ok := callArgs(stmt[len("pm.expect"):])
+		if ok && len(args) == 1 {
+			if expect, ok := t.assertion(args[0], rest); ok {
+				t.addExpect(listen, stmt, expect)
+				return
+			}
+		}
+		t.warn(stmt, "无法翻译的断言")
+		return
+	}
+	if strings.HasPrefix(stmt, "pm.response.to.") {
+		if expect, ok := t.responseAssertion(stmt[len("pm.response"):]); ok {
+			t.addExpect(listen, stmt, expect)
+			return
+		}
+		t.warn(stmt, "无法翻译的断言")
+		return
+	}
+
+	t.warn(stmt, "无法翻译的语句")
+}
+
+// if (cond) 后面可以直接跟语句, 或者在下一个{时生效
+func (t *scriptTranslator) ifStatement(listen string, stmt string, isElse bool, parenIndex int) {
+	args, rest, ok := callArgs(stmt[parenIndex:])
+	cond := ""
+	if ok && len(args) == 1 {
+		cond, ok = t.expr(args[0])
+	}
+	if !ok {
+		t.warn(stmt, "无法翻译的条件")
+		t.chain = ""
+	} else if isElse && t.chain != "" {
+		cond, t.chain = "!("+t.chain+") && ("+cond+")", t.chain+" || "+cond
+	} else if isElse {
+		ok = false
+		t.warn(stmt, "无法翻译的条件")
+	} else {
+		t.chain = cond
+	}
+
+	if rest != "" {
+		// 单行的if语句
+		t.blocks = append(t.blocks, &scriptBlock{cond: cond, invalid: !ok})
+		t.statement(listen, rest)
+		t.blocks = t.blocks[:len(t.blocks)-1]
+		return
+	}
+	t.pendingCond, t.pendingInvalid, t.pendingIf = cond, !ok, true
+}
+
+// chai风格的链式断言, 例如 .to.not.be.above(5)
+func (t *scriptTranslator) assertion(target string, chain string) (string, bool) {
+	x, ok := t.expr(target)
+	if !ok {
+		return "", false
+	}
+
+	negate := false
+	for _, word := range splitChain(chain) {
+		name, args, hasArgs := chainWord(word)
+		switch name {
+		case "to", "be", "been", "is", "that", "which", "and", "has", "have", "with", "at", "of", "same", "does", "still", "deep":
+			if !hasArgs {
+				continue
+			}
+		case "not":
+			negate = !negate
+			continue
+		}
+
+		compare := func(op string) (string, bool) {
+			if len(args) != 1 {
+				return "", false
+			}
+			y, ok := t.expr(args[0])
+			if !ok {
+				return "", false
+			}
+			if negate {
+				op = negateOperator(op)
+			}
+			return fmt.Sprintf("%s %s %s", x, op, y), true
+		}
+
+		switch name {
+		case "eql", "equal", "equals", "eq":
+			return compare("==")
+		case "above", "gt", "greaterThan":
+			return compare(">")
+		case "below", "lt", "lessThan":
+			return compare("<")
+		case "least", "gte":
+			return compare(">=")
+		case "most", "lte":
+			return compare("<=")
+		case "include", "includes", "contain", "contains", "string":
+			if len(args) != 1 {
+				return "", false
+			}
+			y, ok := t.expr(args[0])
+			if !ok {
+				return "", false
+			}
+			res := fmt.Sprintf("@include(%s, %s)", x, y)
+			if negate {
+				res = "!" + res
+			}
+			return res, true
+		case "lengthOf", "length":
+			if !hasArgs {
+				return "", false
+			}
+			x += ".length"
+			return compare("==")
+		case "true", "false", "null":
+			args = []string{name}
+			return compare("==")
+		case "undefined":
+			args = []string{"null"}
+			return compare("==")
+		case "exist":
+			args = []string{"null"}
+			return compare("!=")
+		case "ok":
+			if negate {
+				return "!(" + x + ")", true
+			}
+			return x, true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// pm.response.to.have.status(200)之类的断言
+func (t *scriptTranslator) responseAssertion(chain string) (string, bool) {
+	negate := false
+	for _, word := range splitChain(chain) {
+		name, args, _ := chainWord(word)
+		res := ""
+		switch name {
+		case "to", "be", "have", "has", "and":
+			continue
+		case "not":
+			negate = !negate
+			continue
+		case "status":
+			if len(args) != 1 || !scriptNumberReg.MatchString(args[0]) {
+				return "", false
+			}
+			res = "$res.$status == " + args[0]
+		case "ok", "success":
+			res = "$res.$status >= 200 && $res.$status < 300"
+		case "clientError":
+			res = "$res.$status >= 400 && $res.$status < 500"
+		case "serverError":
+			res = "$res.$status >= 500"
+		case "error":
+			res = "$res.$status >= 400"
+		case "header":
+			if len(args) == 0 || len(args) > 2 {
+				return "", false
+			}
+			key, ok := jsString(args[0])
+			if !ok {
+				return "", false
+			}
+			header := dslPath("$res.$header", []string{key})
+			res = header + " != null"
+			if len(args) == 2 {
+				val, ok := t.expr(args[1])
+				if !ok {
+					return "", false
+				}
+				res = header + " == " + val
+			}
+		case "json":
+			res = `@include($res.$header."Content-Type", "json")`
+		case "body":
+			if len(args) != 1 {
+				return "", false
+			}
+			val, ok := t.expr(args[0])
+			if !ok {
+				return "", false
+			}
+			res = "$res.$body.$str == " + val
+		default:
+			return "", false
+		}
+		if negate {
+			return "!(" + res + ")", true
+		}
+		return res, true
+	}
+	return "", false
+}
+
+// js表达式翻译为DSL
+func (t *scriptTranslator) expr(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", false
+	}
+
+	if strings.HasPrefix(s, "(") {
+		if args, rest, ok := callArgs(s); ok && rest == "" && len(args) == 1 {
+			inner, ok := t.expr(args[0])
+			return "(" + inner + ")", ok
+		}
+	}
+
+	for _, ops := range [][]string{{"||"}, {"&&"}, {"===", "!==", "==", "!=", ">=", "<=", ">", "<"}} {
+		if index, op := findOperator(s, ops); index > 0 {
+			left, ok := t.expr(s[:index])
+			if !ok {
+				return "", false
+			}
+			right, ok := t.expr(s[index+len(op):])
+			if !ok {
+				return "", false
+			}
+			op = strings.Replace(strings.Replace(op, "===", "==", 1), "!==", "!=", 1)
+			return fmt.Sprintf("%s %s %s", left, op, right), true
+		}
+	}
+
+	if strings.HasPrefix(s, "!") {
+		inner, ok := t.expr(s[1:])
+		return "!" + inner, ok
+	}
+
+	switch s {
+	case "true", "false", "null":
+		return s, true
+	case "undefined":
+		return "null", true
+	}
+	if scriptNumberReg.MatchString(s) {
+		return s, true
+	}
+	if str, ok := jsString(s); ok {
+		return dslString(str), true
+	}
+
+	return t.accessor(s)
+}
+
+var scriptBases = []struct {
+	prefix string
+	dsl    string
+}{
+	{"pm.response.json()", "$res.$body.$json"},
+	{"JSON.parse(responseBody)", "$res.$body.$json"},
+	{"JSON.parse(pm.response.text())", "$res.$body.$json"},
+	{"pm.response.text()", "$res.$body.$str"},
+	{"pm.response.code", "$res.$status"},
+	{"responseCode.code", "$res.$status"},
+	{"environment.", "$env."},
+	{"globals.", "$env."},
+}
+
+var scriptCalls = []struct {
+	prefix string
+	dsl    string
+}{
+	{"pm.response.headers.get(", "$res.$header"},
+	{"postman.getResponseHeader(", "$res.$header"},
+	{"pm.environment.get(", "$env"},
+	{"pm.collectionVariables.get(", "$env"},
+	{"pm.variables.get(", "$env"},
+	{"pm.globals.get(", "$env"},
+	{"postman.getEnvironmentVariable(", "$env"},
+	{"postman.getGlobalVariable(", "$env"},
+}
+
+// 取值表达式: 已知的对象或者变量后面跟 .x ["x"] [0]
+func (t *scriptTranslator) accessor(s string) (string, bool) {
+	if strings.HasPrefix(s, "responseBody.has(") {
+		args, rest, ok := callArgs(s[len("responseBody.has"):])
+		if !ok || rest != "" || len(args) != 1 {
+			return "", false
+		}
+		val, ok := t.expr(args[0])
+		if !ok {
+			return "", false
+		}
+		return "@include($res.$body.$str, " + val + ")", true
+	}
+
+	for _, item := range scriptCalls {
+		if !strings.HasPrefix(s, item.prefix) {
+			continue
+		}
+		args, rest, ok := callArgs(s[len(item.prefix)-1:])
+		if !ok || len(args) != 1 {
+			return "", false
+		}
+		key, ok := jsString(args[0])
+		if !ok {
+			return "", false
+		}
+		return t.chainPath(dslPath(item.dsl, []string{key}), rest)
+	}
+
+	for _, item := range scriptBases {
+		if strings.HasPrefix(s, item.prefix) {
+			if strings.HasSuffix(item.prefix, ".") {
+				return t.chainPath(strings.TrimSuffix(item.dsl, "."), s[len(item.prefix)-1:])
+			}
+			return t.chainPath(item.dsl, s[len(item.prefix):])
+		}
+	}
+	if s == "responseBody" || strings.HasPrefix(s, "responseBody.") {
+		return t.chainPath("$res.$body.$str", s[len("responseBody"):])
+	}
+
+	name := s
+	if index := strings.IndexAny(s, ".["); index > 0 {
+		name = s[:index]
+	}
+	if base, ok := t.alias[name]; ok {
+		return t.chainPath(base, s[len(name):])
+	}
+	return "", false
+}
+
+func (t *scriptTranslator) chainPath(base string, chain string) (string, bool) {
+	segments := []string{}
+	for chain != "" {
+		switch {
+		case strings.HasPrefix(chain, "."):
+			end := 1
+			for end < len(chain) && (chain[end] == '_' || chain[end] == '$' || isAlnum(chain[end])) {
+				end++
+			}
+			if end == 1 {
+				return "", false
+			}
+			segments = append(segments, chain[1:end])
+			chain = chain[end:]
+		case strings.HasPrefix(chain, "["):
+			end := strings.Index(chain, "]")
+			if end < 0 {
+				return "", false
+			}
+			key := strings.TrimSpace(chain[1:end])
+			if str, ok := jsString(key); ok {
+				key = str
+			} else if !scriptNumberReg.MatchString(key) {
+				return "", false
+			}
+			segments = append(segments, key)
+			chain = chain[end+1:]
+		default:
+			return "", false
+		}
+	}
+	return dslPath(base, segments), true
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// 标识符直接拼接, 其他的使用字符串形式, 例如 $res.$header."Content-Type"
+func dslPath(base string, segments []string) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, item := range segments {
+		if scriptIdentReg.MatchString(item) || scriptNumberReg.MatchString(item) && !strings.Contains(item, ".") && !strings.HasPrefix(item, "-") {
+			b.WriteString("." + item)
+		} else {
+			b.WriteString("." + dslString(item))
+		}
+	}
+	return b.String()
+}
+
+func dslString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func negateOperator(op string) string {
+	switch op {
+	case "==":
+		return "!="
+	case "!=":
+		return "=="
+	case ">":
+		return "<="
+	case "<":
+		return ">="
+	case ">=":
+		return "<"
+	case "<=":
+		return ">"
+	}
+	return op
+}
+
+// js字符串字面量
+func jsString(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return "", false
+	}
+	quote := s[0]
+	if (quote != '"' && quote != '\'' && quote != '`') || s[len(s)-1] != quote {
+		return "", false
+	}
+	inner := s[1 : len(s)-1]
+	if quote == '`' && strings.Contains(inner, "${") {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+			switch inner[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(inner[i])
+			}
+			continue
+		}
+		if inner[i] == quote {
+			return "", false
+		}
+		b.WriteByte(inner[i])
+	}
+	return b.String(), true
+}
+
+// 解析以(开头的参数列表, 返回参数以及)之后剩余的部分
+func callArgs(s string) ([]string, string, bool) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", false
+	}
+	args := []string{}
+	depth := 0
+	start := 1
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if arg := strings.TrimSpace(s[start:i]); arg != "" {
+					args = append(args, arg)
+				}
+				return args, strings.TrimSpace(s[i+1:]), true
+			}
+		case ',':
+			if depth == 1 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// 查找括号、字符串之外的运算符, 返回最后一次出现的位置(左结合)
+func findOperator(s string, ops []string) (int, string) {
+	index, found := -1, ""
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+			continue
+		case '(', '[', '{':
+			depth++
+			continue
+		case ')', ']', '}':
+			depth--
+			continue
+		}
+		if depth != 0 {
+			continue
+		}
+		for _, op := range ops {
+			if !strings.HasPrefix(s[i:], op) {
+				continue
+			}
+			// 排除 => 以及更长的运算符中的一部分
+			next := byte(0)
+			if i+len(op) < len(s) {
+				next = s[i+len(op)]
+			}
+			prev := byte(0)
+			if i > 0 {
+				prev = s[i-1]
+			}
+			if (op == ">" || op == "<") && (next == '=' || prev == '=') {
+				continue
+			}
+			if (op == "==" || op == "!=") && next == '=' {
+				continue
+			}
+			if op == "==" && (prev == '=' || prev == '!' || prev == '<' || prev == '>') {
+				continue
+			}
+			index, found = i, op
+			i += len(op) - 1
+			break
+		}
+	}
+	return index, found
+}
+
+// 链式调用按.拆分, 括号中的.不拆分
+func splitChain(s string) []string {
+	res := []string{}
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				if word := strings.TrimSpace(s[start:i]); word != "" {
+					res = append(res, word)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if word := strings.TrimSpace(s[start:]); word != "" {
+		res = append(res, word)
+	}
+	return res
+}
+
+func chainWord(word string) (string, []string, bool) {
+	index := strings.Index(word, "(")
+	if index < 0 {
+		return word, nil, false
+	}
+	args, _, ok := callArgs(word[index:])
+	if !ok {
+		return word[:index], nil, true
+	}
+	return word[:index], args, true
+}
+
+// 去掉多余的右括号, 例如 pm.test("x", () => pm.response.to.have.status(200))
+func trimUnbalanced(s string) string {
+	for strings.HasSuffix(s, ")") && strings.Count(s, ")") > strings.Count(s, "(") {
+		s = strings.TrimSpace(s[:len(s)-1])
+	}
+	return s
+}
+
+// 按语句拆分, ;与换行为分隔符(括号中的除外), {与}单独作为一条语句
+func splitScript(src string) []string {
+	res := []string{}
+	var cur strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(cur.String()); stmt != "" {
+			res = append(res, stmt)
+		}
+		cur.Reset()
+	}
+
+	depth := 0
+	depthStack := []int{}
+	var quote rune
+	runes := []rune(src)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if quote != 0 {
+			cur.WriteRune(r)
+			if r == '\\' && i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+			cur.WriteRune(r)
+		case r == '/' && next == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			i--
+		case r == '/' && next == '*':
+			end := strings.Index(string(runes[i+2:]), "*/")
+			if end < 0 {
+				i = len(runes)
+			} else {
+				i += 2 + len([]rune(string(runes[i+2:])[:end])) + 1
+			}
+		case r == '(' || r == '[':
+			depth++
+			cur.WriteRune(r)
+		case r == ')' || r == ']':
+			depth--
+			cur.WriteRune(r)
+		case r == '{':
+			flush()
+			res = append(res, "{")
+			depthStack = append(depthStack, depth)
+			depth = 0
+		case r == '}':
+			flush()
+			res = append(res, "}")
+			if len(depthStack) > 0 {
+				depth = depthStack[len(depthStack)-1]
+				depthStack = depthStack[:len(depthStack)-1]
+			}
+		case (r == ';' || r == '\n' || r == '\r') && depth == 0:
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return res
+}
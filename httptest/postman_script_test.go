@@ -0,0 +1,161 @@
+package httptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslatePostmanScript(t *testing.T) {
+	var pairs = []struct {
+		listen   string
+		source   []string
+		expect   []string
+		event    []string
+		warnings int
+	}{
+		{
+			listen: "test",
+			source: []string{
+				`pm.test("Status code is 200", function () {`,
+				`    pm.response.to.have.status(200);`,
+				`});`,
+			},
+			expect: []string{`$res.$status == 200`},
+		},
+		{
+			listen: "test",
+			source: []string{
+				`var jsonData = pm.response.json();`,
+				`pm.test("value", () => pm.expect(jsonData.data.items[0]["user-id"]).to.eql('u1'));`,
+				`pm.expect(jsonData.total).to.be.above(1);`,
+				`pm.expect(jsonData.msg).to.not.equal("fail");`,
+				`pm.expect(jsonData.ok).to.be.true;`,
+				`pm.expect(pm.response.text()).to.include("ok");`,
+				`pm.response.to.have.header("Content-Type");`,
+				`pm.environment.set("token", jsonData.accessToken);`,
+				`pm.collectionVariables.set("uid", jsonData.data.id);`,
+			},
+			expect: []string{
+				`$res.$body.$json.data.items.0."user-id" == "u1"`,
+				`$res.$body.$json.total > 1`,
+				`$res.$body.$json.msg != "fail"`,
+				`$res.$body.$json.ok == true`,
+				`@include($res.$body.$str, "ok")`,
+				`$res.$header."Content-Type" != null`,
+			},
+			event: []string{
+				`$env.token = $res.$body.$json.accessToken`,
+				`$env.uid = $res.$body.$json.data.id`,
+			},
+		},
+		{
+			listen: "test",
+			source: []string{
+				`if(responseCode.code === 200 && responseBody.has("accessToken")){`,
+				`    var jsonData = JSON.parse(responseBody)`,
+				`    pm.collectionVariables.set("accessToken", jsonData.accessToken);`,
+				`} else {`,
+				`    tests["failed"] = responseCode.code !== 200;`,
+				`}`,
+			},
+			expect: []string{
+				`!(!($res.$status == 200 && @include($res.$body.$str, "accessToken"))) || ($res.$status != 200)`,
+			},
+			event: []string{
+				`@if($res.$status == 200 && @include($res.$body.$str, "accessToken"), $env.accessToken = $res.$body.$json.accessToken)`,
+			},
+		},
+		{
+			listen: "prerequest",
+			source: []string{
+				`// 请求前设置变量`,
+				`pm.environment.set("page", 1);`,
+				`pm.environment.set("ts", Date.now());`,
+				`pm.expect(1).to.eql(1);`,
+			},
+			event:    []string{`$env.page = 1`},
+			warnings: 2,
+		},
+		{
+			listen: "test",
+			source: []string{
+				`if (pm.response.json().items.some(x => x.id)) {`,
+				`    pm.environment.set("ok", true);`,
+				`}`,
+				`pm.expect(jsonData).to.be.a("object");`,
+			},
+			warnings: 3,
+		},
+	}
+
+	for _, item := range pairs {
+		res := translatePostmanScript(item.listen, item.source)
+		assert.Equal(t, item.expect, res.Expect)
+		assert.Equal(t, item.event, res.Event)
+		assert.Len(t, res.Warnings, item.warnings, res.Warnings)
+	}
+}
+
+func TestPostmanScriptRun(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			body, _ := json.Marshal(map[string]interface{}{
+				"accessToken": "123456",
+				"data":        map[string]interface{}{"id": 7},
+			})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+		case "/info":
+			if r.Header.Get("Authorization") != "Bearer 123456" || r.URL.Query().Get("uid") != "7" || r.URL.Query().Get("page") != "2" {
+				w.WriteHeader(401)
+				return
+			}
+			w.Write([]byte(`{"name": "ving"}`))
+		}
+	}))
+	defer ts.Close()
+
+	collection := `{
+		"info": {"name": "script"},
+		"item": [
+			{
+				"name": "login",
+				"event": [{
+					"listen": "test",
+					"script": {"exec": [
+						"var jsonData = pm.response.json();",
+						"pm.test('ok', function () { pm.response.to.have.status(200); pm.response.to.be.json; });",
+						"pm.environment.set('token', jsonData.accessToken);",
+						"pm.environment.set('uid', jsonData.data.id);"
+					]}
+				}],
+				"request": {"method": "POST", "url": "{{baseUrl}}/login"}
+			},
+			{
+				"name": "info",
+				"event": [
+					{"listen": "prerequest", "script": {"exec": "pm.variables.set(\"page\", 2)"}},
+					{"listen": "test", "script": {"exec": ["pm.expect(pm.response.json().name).to.eql(\"ving\")"]}}
+				],
+				"request": {
+					"method": "GET",
+					"auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}"}]},
+					"url": "{{baseUrl}}/info?uid={{uid}}&page={{page}}"
+				}
+			}
+		]
+	}`
+
+	specInfo, err := NewPostmanSpecInfo([]byte(collection), nil)
+	require.Nil(t, err)
+	assert.Empty(t, specInfo.Warnings())
+
+	specInfo.WithEnvironment(&PostmanEnvironment{Values: []*PostmanVariable{{Key: "baseUrl", Value: ts.URL}}})
+	require.Nil(t, specInfo.StartHandle(t))
+}
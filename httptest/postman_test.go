@@ -0,0 +1,187 @@
+package httptest
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostmanCollectionV21(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]string{}
+	record := func(key, value string) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[key] = value
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/goods":
+			record("goods.query", r.URL.RawQuery)
+			record("goods.auth", r.Header.Get("Authorization"))
+			record("goods.trace", r.Header.Get("X-Trace"))
+			record("goods.disabled", r.Header.Get("X-Disabled"))
+		case "/health":
+			record("health.method", r.Method)
+		case "/api/goods/42":
+			record("detail.auth", r.Header.Get("Authorization"))
+		case "/api/user/avatar":
+			record("avatar.query", r.URL.RawQuery)
+			if err := r.ParseMultipartForm(1 << 20); err == nil {
+				record("avatar.uid", r.FormValue("uid"))
+				record("avatar.ignored", r.FormValue("ignored"))
+				if _, header, err := r.FormFile("avatar"); err == nil {
+					record("avatar.file", header.Filename)
+				}
+			}
+		case "/api/user/login":
+			record("login.auth", r.Header.Get("Authorization"))
+			record("login.mobile", r.PostFormValue("mobile"))
+		case "/graphql":
+			data, _ := ioutil.ReadAll(r.Body)
+			record("graphql.body", string(data))
+			record("graphql.type", r.Header.Get("Content-Type"))
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	env, err := NewPostmanEnvironmentFromFile("./testdata/postman/shop.postman_environment.json")
+	require.Nil(t, err)
+	env.Values = append(env.Values, &PostmanVariable{Key: "baseUrl", Value: ts.URL})
+
+	specInfo, err := NewPostmanSpecInfoFromFile("./testdata/postman/shop.postman_collection.json", nil)
+	require.Nil(t, err)
+	require.Len(t, specInfo.requests(), 6)
+	assert.Equal(t, "public/商品列表", specInfo.requests()[0].name)
+	assert.Len(t, specInfo.Item[0].Item[0].Response, 1)
+	assert.Equal(t, 200, specInfo.Item[0].Item[0].Response[0].Code)
+
+	require.Nil(t, specInfo.WithEnvironment(env).StartHandle(t))
+
+	assert.Equal(t, "page=1&size=20", received["goods.query"])
+	assert.Equal(t, "", received["goods.auth"])
+	assert.Equal(t, "trace-1", received["goods.trace"])
+	assert.Equal(t, "", received["goods.disabled"])
+	assert.Equal(t, "GET", received["health.method"])
+	assert.Equal(t, "Bearer env-token", received["detail.auth"])
+	assert.Equal(t, "api_key=secret", received["avatar.query"])
+	assert.Equal(t, "7", received["avatar.uid"])
+	assert.Equal(t, "", received["avatar.ignored"])
+	assert.Equal(t, "avatar.png", received["avatar.file"])
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("ving:123456")), received["login.auth"])
+	assert.Equal(t, "15212230311", received["login.mobile"])
+	assert.JSONEq(t, `{"query": "query { user(id: 7) { name } }", "variables": {"uid": 7}}`, received["graphql.body"])
+	assert.Equal(t, "application/json", received["graphql.type"])
+}
+
+func TestPostmanOnlySkip(t *testing.T) {
+	var mu sync.Mutex
+	paths := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	specInfo, err := NewPostmanSpecInfoFromFile("./testdata/postman/shop.postman_collection.json", nil)
+	require.Nil(t, err)
+	env, err := NewPostmanEnvironmentFromFile("./testdata/postman/shop.postman_environment.json")
+	require.Nil(t, err)
+	env.Values = append(env.Values, &PostmanVariable{Key: "baseUrl", Value: ts.URL})
+	specInfo.WithEnvironment(env)
+
+	// 名称可以带folder, 也可以只是请求名
+	ctx := NewHttpContext()
+	require.Nil(t, specInfo.Only("public/商品列表", "健康检查").Skip("商品列表").StartHandleWithContext(t, ctx))
+	assert.Equal(t, []string{"/health"}, paths)
+	require.Len(t, ctx.Results(), 6)
+	assert.True(t, ctx.Results()[0].Skipped)
+	assert.Equal(t, "被指定跳过", ctx.Results()[0].SkipReason)
+	assert.False(t, ctx.Results()[1].Skipped)
+	assert.Equal(t, "未被指定执行", ctx.Results()[2].SkipReason)
+}
+
+func TestPostmanFilterTags(t *testing.T) {
+	var mu sync.Mutex
+	paths := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	collection := `{
+		"info": {"name": "tags"},
+		"item": [
+			{"name": "user", "tags": ["smoke"], "item": [
+				{"name": "login", "request": {"method": "POST", "url": "{{baseUrl}}/login"}},
+				{"name": "export", "tags": ["slow"], "request": {"url": "{{baseUrl}}/export"}}
+			]},
+			{"name": "health", "request": {"url": "{{baseUrl}}/health"}}
+		]
+	}`
+	specInfo, err := NewPostmanSpecInfo([]byte(collection), nil)
+	require.Nil(t, err)
+	require.Len(t, specInfo.requests(), 3)
+	assert.Equal(t, []string{"smoke", "slow"}, specInfo.requests()[1].tags)
+
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, specInfo.FilterTags("smoke && !slow").StartHandleWithContext(t, ctx))
+	assert.Equal(t, []string{"/login"}, paths)
+	require.Len(t, ctx.Results(), 1)
+	assert.Equal(t, []string{"smoke"}, ctx.Results()[0].Tags)
+
+	assert.NotNil(t, specInfo.FilterTags("smoke &&").StartHandle(t))
+}
+
+func TestPostmanApikeyQuery(t *testing.T) {
+	received := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query().Get("api key")
+	}))
+	defer ts.Close()
+
+	collection := `{
+		"info": {"name": "apikey"},
+		"auth": {"type": "apikey", "apikey": [
+			{"key": "key", "value": "api key"},
+			{"key": "value", "value": "{{apiKey}}"},
+			{"key": "in", "value": "query"}
+		]},
+		"item": [{"name": "list", "request": {"url": "{{baseUrl}}/list?page=1"}}]
+	}`
+	specInfo, err := NewPostmanSpecInfo([]byte(collection), nil)
+	require.Nil(t, err)
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	ctx.Setenv("apiKey", "a&b=c d+e")
+	require.Nil(t, specInfo.StartHandleWithContext(t, ctx))
+	assert.Equal(t, "a&b=c d+e", received)
+}
+
+func TestPostmanUrlString(t *testing.T) {
+	var u PostmanUrl
+	require.Nil(t, u.UnmarshalJSON([]byte(`"http://127.0.0.1:8000/api?a=1"`)))
+	assert.Equal(t, "http://127.0.0.1:8000/api?a=1", u.String())
+
+	require.Nil(t, u.UnmarshalJSON([]byte(`{
+		"raw": "http://127.0.0.1:8000/api/user/register",
+		"protocol": "http",
+		"host": ["127", "0", "0", "1"],
+		"port": "8000",
+		"path": ["api", "user", "register"]
+	}`)))
+	assert.Equal(t, "http://127.0.0.1:8000/api/user/register", u.String())
+}
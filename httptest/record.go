@@ -0,0 +1,202 @@
+package httptest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+////////////////////
+// 录制: 作为反向代理转发到目标服务, 记录每次请求与响应
+// 1、Spec按照HAR导入的规则生成spec: 响应中的值在之后的请求中出现时转为环境变量, 生成状态码与响应体的断言
+// 2、Fixtures为回放使用的请求与响应, 通过NewReplayHandler作为stub返回
+// 3、转发时去掉Accept-Encoding, 记录未压缩的响应体
+////////////////////
+
+type Recorder struct {
+	proxy    *httputil.ReverseProxy
+	onRecord []func()
+
+	mu       sync.Mutex
+	entries  []*HAREntry
+	fixtures []*Fixture
+}
+
+// 回放使用的一次请求与响应
+type Fixture struct {
+	Method   string           `json:"method"`
+	Url      string           `json:"url"` // 路径以及query
+	Body     string           `json:"body,omitempty"`
+	Response *FixtureResponse `json:"response"`
+}
+
+type FixtureResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     string      `json:"body,omitempty"`
+	Encoding string      `json:"encoding,omitempty"` // 为base64时body为二进制内容的base64编码
+}
+
+// 代理之后重新计算或者由服务端生成的响应头, 不写入fixture
+var recordSkipHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Connection":        true,
+	"Date":              true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+}
+
+type recordContextKey struct{}
+
+// 转发之前保存的请求信息
+type recordRequest struct {
+	uri  string
+	body []byte
+}
+
+// target为目标服务的地址, 例如http://127.0.0.1:8000
+func NewRecorder(target string) (*Recorder, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("target需要为完整的地址, 例如http://127.0.0.1:8000")
+	}
+	r := &Recorder{}
+	r.proxy = httputil.NewSingleHostReverseProxy(u)
+	director := r.proxy.Director
+	r.proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = u.Host
+		req.Header.Del("Accept-Encoding")
+	}
+	r.proxy.ModifyResponse = r.record
+	return r, nil
+}
+
+// 每记录一次请求之后调用, 例如保存到文件
+func (r *Recorder) OnRecord(f func()) *Recorder {
+	r.onRecord = append(r.onRecord, f)
+	return r
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	ctx := context.WithValue(req.Context(), recordContextKey{}, &recordRequest{uri: req.URL.RequestURI(), body: body})
+	r.proxy.ServeHTTP(w, req.WithContext(ctx))
+}
+
+func (r *Recorder) record(resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	origin, ok := resp.Request.Context().Value(recordContextKey{}).(*recordRequest)
+	if !ok {
+		return nil
+	}
+
+	entry := &HAREntry{StartedDateTime: time.Now().Format(time.RFC3339Nano)}
+	entry.Request.Method = resp.Request.Method
+	entry.Request.Url = resp.Request.URL.String()
+	header := resp.Request.Header.Clone()
+	// 代理添加的header
+	for _, key := range []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+		header.Del(key)
+	}
+	entry.Request.Headers = harHeaders(header)
+	for key, values := range resp.Request.URL.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, &HARNameValue{Name: key, Value: value})
+		}
+	}
+	if len(origin.body) > 0 {
+		// PostData为匿名结构体, 通过json分配
+		if err := json.Unmarshal([]byte("{}"), &entry.Request.PostData); err != nil {
+			return err
+		}
+		entry.Request.PostData.MimeType = resp.Request.Header.Get("Content-Type")
+		entry.Request.PostData.Text = string(origin.body)
+	}
+	entry.Response.Status = resp.StatusCode
+	entry.Response.Headers = harHeaders(resp.Header)
+	entry.Response.Content.MimeType = resp.Header.Get("Content-Type")
+
+	fixture := &Fixture{
+		Method:   resp.Request.Method,
+		Url:      origin.uri,
+		Body:     string(origin.body),
+		Response: &FixtureResponse{Status: resp.StatusCode, Header: http.Header{}},
+	}
+	for key, values := range resp.Header {
+		if !recordSkipHeaders[http.CanonicalHeaderKey(key)] {
+			fixture.Response.Header[key] = append([]string{}, values...)
+		}
+	}
+	if utf8.Valid(body) {
+		entry.Response.Content.Text = string(body)
+		fixture.Response.Body = string(body)
+	} else {
+		entry.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+		entry.Response.Content.Encoding = "base64"
+		fixture.Response.Body = entry.Response.Content.Text
+		fixture.Response.Encoding = "base64"
+	}
+
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.fixtures = append(r.fixtures, fixture)
+	r.mu.Unlock()
+	for _, f := range r.onRecord {
+		f()
+	}
+	return nil
+}
+
+// 按照名称排序, 保证生成的spec稳定
+func harHeaders(header http.Header) []*HARNameValue {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	res := []*HARNameValue{}
+	for _, key := range keys {
+		for _, value := range header[key] {
+			res = append(res, &HARNameValue{Name: key, Value: value})
+		}
+	}
+	return res
+}
+
+// 已经录制的请求生成的spec, 包含状态码与响应体的断言
+func (r *Recorder) Spec() *BasicSpecInfo {
+	r.mu.Lock()
+	har := &HAR{}
+	har.Log.Version = "1.2"
+	har.Log.Entries = append([]*HAREntry{}, r.entries...)
+	r.mu.Unlock()
+	return har.ToBasic(&HAROption{KeepAssets: true, ExpectBody: true})
+}
+
+// 已经录制的请求与响应
+func (r *Recorder) Fixtures() []*Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Fixture{}, r.fixtures...)
+}
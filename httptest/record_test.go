@@ -0,0 +1,122 @@
+package httptest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLegacyServer() *httptest.Server {
+	const token = "tk-1234567890"
+	count := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/login":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"code":0,"token":"` + token + `"}`))
+		case "/api/counter":
+			count++
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"count": count})
+		case "/api/avatar":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0xff})
+		default:
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(401)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":"ving","vip":true}`))
+		}
+	}))
+}
+
+func TestRecordReplay(t *testing.T) {
+	backend := newLegacyServer()
+	defer backend.Close()
+	recorder, err := NewRecorder(backend.URL)
+	require.Nil(t, err)
+	records := 0
+	recorder.OnRecord(func() { records++ })
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+
+	call := func(method, path, header, body string) (int, string) {
+		req, err := http.NewRequest(method, proxy.URL+path, strings.NewReader(body))
+		require.Nil(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp.StatusCode, string(data)
+	}
+	call("POST", "/api/login", "", `{"name": "ving"}`)
+	call("GET", "/api/user?id=1", "Bearer tk-1234567890", "")
+	call("GET", "/api/counter", "", "")
+	call("GET", "/api/counter", "", "")
+	call("GET", "/api/avatar", "", "")
+	assert.Equal(t, 5, records)
+
+	spec := *recorder.Spec()
+	require.Len(t, spec, 5)
+	assert.Equal(t, backend.URL+"/api/login", spec[0].Url)
+	assert.Equal(t, []string{"$env.token = $res.$body.$json.token"}, spec[0].Event)
+	assert.Equal(t, []string{"$res.$status == 200", "$res.$body.$json.code == 0"}, spec[0].Expect)
+	assert.Contains(t, spec[1].Header, "Authorization: Bearer {{token}}")
+	assert.Equal(t, []string{"$res.$status == 200", `$res.$body.$json.name == "ving"`, "$res.$body.$json.vip == true"}, spec[1].Expect)
+	for _, header := range spec[1].Header {
+		assert.False(t, strings.HasPrefix(header, "X-Forwarded"), header)
+	}
+
+	// 生成的spec可以直接对目标服务执行
+	ctx := NewHttpContext()
+	require.Nil(t, newRunner(spec[:2], false).Run(t, ctx))
+
+	fixtures := recorder.Fixtures()
+	require.Len(t, fixtures, 5)
+	assert.Equal(t, "/api/user?id=1", fixtures[1].Url)
+	assert.Equal(t, "base64", fixtures[4].Response.Encoding)
+
+	replay := NewReplayServer(fixtures)
+	defer replay.Close()
+	status, _, body := mockCall(t, "POST", replay.URL+"/api/login", map[string]string{"Content-Type": "application/json"}, `{"name":"ving"}`)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, `{"code":0,"token":"tk-1234567890"}`, body)
+	_, header, body := mockCall(t, "GET", replay.URL+"/api/avatar", nil, "")
+	assert.Equal(t, "image/png", header.Get("Content-Type"))
+	assert.Equal(t, string([]byte{0x89, 'P', 'N', 'G', 0xff}), body)
+
+	// 按照录制的顺序返回, 之后重复最后一次
+	for _, expected := range []string{`{"count":1}`, `{"count":2}`, `{"count":2}`} {
+		_, _, body = mockCall(t, "GET", replay.URL+"/api/counter", nil, "")
+		assert.JSONEq(t, expected, body)
+	}
+	// query不同时使用路径相同的fixture
+	status, _, _ = mockCall(t, "GET", replay.URL+"/api/user?id=2", nil, "")
+	assert.Equal(t, 200, status)
+
+	status, _, body = mockCall(t, "GET", replay.URL+"/api/users", nil, "")
+	assert.Equal(t, 404, status)
+	res := map[string]interface{}{}
+	require.Nil(t, json.Unmarshal([]byte(body), &res))
+	assert.Equal(t, "GET /api/user?id=1", res["closest"].([]interface{})[0])
+}
+
+func TestNewRecorder(t *testing.T) {
+	_, err := NewRecorder("127.0.0.1:8000")
+	assert.Error(t, err)
+}
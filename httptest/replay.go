@@ -0,0 +1,135 @@
+package httptest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+////////////////////
+// 回放录制的fixture
+// 1、按照method、路径、query以及请求体(json按照内容比较)匹配, 没有完全匹配时使用method与路径相同的fixture
+// 2、相同的请求录制了多次时按照录制的顺序返回, 之后重复返回最后一次的响应
+// 3、没有匹配的请求返回404以及最接近的请求
+////////////////////
+
+type ReplayHandler struct {
+	fixtures []*Fixture
+
+	mu     sync.Mutex
+	served map[*Fixture]int // 按照第一个匹配的fixture记录返回的次数
+}
+
+func NewReplayHandler(fixtures []*Fixture) *ReplayHandler {
+	return &ReplayHandler{fixtures: fixtures, served: map[*Fixture]int{}}
+}
+
+// 启动回放的server, 使用完之后需要Close
+func NewReplayServer(fixtures []*Fixture) *httptest.Server {
+	return httptest.NewServer(NewReplayHandler(fixtures))
+}
+
+func NewFixturesFromFile(path string) ([]*Fixture, error) {
+	data, _, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := []*Fixture{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (h *ReplayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	strict, loose := []*Fixture{}, []*Fixture{}
+	for _, fixture := range h.fixtures {
+		u, err := url.Parse(fixture.Url)
+		if err != nil || !strings.EqualFold(fixture.Method, r.Method) || u.Path != r.URL.Path {
+			continue
+		}
+		loose = append(loose, fixture)
+		if reflect.DeepEqual(u.Query(), r.URL.Query()) && replayBodyEqual(fixture.Body, body) {
+			strict = append(strict, fixture)
+		}
+	}
+	candidates := strict
+	if len(candidates) == 0 {
+		candidates = loose
+	}
+	if len(candidates) == 0 {
+		h.notFound(w, r)
+		return
+	}
+
+	h.mu.Lock()
+	index := h.served[candidates[0]]
+	h.served[candidates[0]]++
+	h.mu.Unlock()
+	if index >= len(candidates) {
+		index = len(candidates) - 1
+	}
+	h.write(w, candidates[index].Response)
+}
+
+func (h *ReplayHandler) write(w http.ResponseWriter, resp *FixtureResponse) {
+	body := []byte(resp.Body)
+	if resp.Encoding == "base64" {
+		data, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			http.Error(w, "fixture的body不是合法的base64", http.StatusInternalServerError)
+			return
+		}
+		body = data
+	}
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// json按照内容比较, 其他按照文本比较
+func replayBodyEqual(expected string, actual []byte) bool {
+	if strings.TrimSpace(expected) == strings.TrimSpace(string(actual)) {
+		return true
+	}
+	var x, y interface{}
+	if decodeJSON([]byte(expected), &x) != nil || decodeJSON(actual, &y) != nil {
+		return false
+	}
+	return len(jsonDiff("$", x, y)) == 0
+}
+
+func (h *ReplayHandler) notFound(w http.ResponseWriter, r *http.Request) {
+	target := r.Method + " " + r.URL.RequestURI()
+	routes := []string{}
+	distances := map[string]int{}
+	for _, fixture := range h.fixtures {
+		name := strings.ToUpper(fixture.Method) + " " + fixture.Url
+		if _, ok := distances[name]; ok {
+			continue
+		}
+		routes = append(routes, name)
+		distances[name] = editDistance(target, name)
+	}
+	writeNotFound(w, target, routes, distances)
+}
@@ -0,0 +1,276 @@
+package httptest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wwqdrh/easytest/httptest/internal"
+)
+
+////////////////////
+// 执行spec
+// 1、before_all、before_each在item之前执行, after_each、after_all在item之后执行, item失败时after_*仍然执行
+// 2、Go代码中的钩子: OnSetup在before_all之前执行, OnTeardown在after_all之后执行
+// 3、没有url的item只执行event, 例如钩子中准备环境变量
+// 4、skip、only、if以及Only、Skip指定的item不执行, 记录为跳过
+// 5、FilterTags按照标签表达式筛选item, 不满足的item不执行也不记录
+////////////////////
+
+const (
+	HookBeforeAll  = "before_all"
+	HookAfterAll   = "after_all"
+	HookBeforeEach = "before_each"
+	HookAfterEach  = "after_each"
+)
+
+type Runner struct {
+	parser bool // 使用DoParser执行, 失败时中止
+	items  []*BasicItem
+	hooks  map[string][]*BasicItem
+
+	hasOnly   bool            // 存在设置了only的item
+	onlyNames map[string]bool // 只执行这些名称的item
+	skipNames map[string]bool // 跳过这些名称的item
+	tagExpr   string          // 标签表达式, 为空时不筛选
+
+	setup    []func(*HttpContext) error
+	teardown []func(*HttpContext) error
+}
+
+func newRunner(items []*BasicItem, parser bool) *Runner {
+	r := &Runner{parser: parser, hooks: map[string][]*BasicItem{}, onlyNames: map[string]bool{}, skipNames: map[string]bool{}}
+	for _, item := range items {
+		if item.Hook != "" {
+			r.hooks[item.Hook] = append(r.hooks[item.Hook], item)
+			continue
+		}
+		r.items = append(r.items, item)
+		r.hasOnly = r.hasOnly || item.Only
+	}
+	return r
+}
+
+func (s *BasicSpecInfo) Runner() *Runner {
+	return newRunner(*s, false)
+}
+
+func (s *BasicParserSpecInfo) Runner() *Runner {
+	return newRunner(*s, true)
+}
+
+// 在before_all之前执行, 返回错误时不再执行item, teardown仍然执行
+func (r *Runner) OnSetup(f func(*HttpContext) error) *Runner {
+	r.setup = append(r.setup, f)
+	return r
+}
+
+// 在after_all之后执行, 无论之前是否失败
+func (r *Runner) OnTeardown(f func(*HttpContext) error) *Runner {
+	r.teardown = append(r.teardown, f)
+	return r
+}
+
+// 只执行这些名称的item, 其他item记录为跳过
+func (r *Runner) Only(names ...string) *Runner {
+	for _, name := range names {
+		r.onlyNames[name] = true
+	}
+	return r
+}
+
+// 跳过这些名称的item
+func (r *Runner) Skip(names ...string) *Runner {
+	for _, name := range names {
+		r.skipNames[name] = true
+	}
+	return r
+}
+
+// 按照标签表达式筛选item, 例如smoke && !slow, 表达式错误时Run返回错误
+func (r *Runner) FilterTags(expr string) *Runner {
+	r.tagExpr = expr
+	return r
+}
+
+func (r *Runner) Run(t TestingT, ctx *HttpContext) (err error) {
+	matcher, err := r.prepare()
+	if err != nil {
+		return err
+	}
+
+	// item失败(DoParser)时通过panic中止, 清理放在defer中
+	defer func() {
+		if finishErr := r.finish(t, ctx); err == nil {
+			err = finishErr
+		}
+	}()
+	if err := r.begin(t, ctx); err != nil {
+		return err
+	}
+	return r.runItems(t, ctx, matcher)
+}
+
+// 校验钩子名称以及标签表达式
+func (r *Runner) prepare() (tagMatcher, error) {
+	for name := range r.hooks {
+		switch name {
+		case HookBeforeAll, HookAfterAll, HookBeforeEach, HookAfterEach:
+		default:
+			return nil, fmt.Errorf("未知的钩子%s", name)
+		}
+	}
+	return compileTagExpr(r.tagExpr)
+}
+
+// 执行setup以及before_all
+func (r *Runner) begin(t TestingT, ctx *HttpContext) error {
+	for _, f := range r.setup {
+		if err := f(ctx); err != nil {
+			return fmt.Errorf("setup: %w", err)
+		}
+	}
+	return r.runHooks(t, ctx, HookBeforeAll)
+}
+
+// 执行after_all以及teardown, 返回第一个teardown的错误
+func (r *Runner) finish(t TestingT, ctx *HttpContext) (err error) {
+	if hookErr := r.runHooks(t, ctx, HookAfterAll); hookErr != nil {
+		t.Errorf("%s: %s", HookAfterAll, hookErr.Error())
+	}
+	for _, f := range r.teardown {
+		if teardownErr := f(ctx); teardownErr != nil {
+			t.Errorf("teardown: %s", teardownErr.Error())
+			if err == nil {
+				err = teardownErr
+			}
+		}
+	}
+	return err
+}
+
+func (r *Runner) runItems(t TestingT, ctx *HttpContext, matcher tagMatcher) error {
+	for _, item := range r.items {
+		if !matcher.match(item.Tags) {
+			continue
+		}
+		if reason := r.skipReason(item); reason != "" {
+			ctx.skip(item.Name, item, reason)
+			continue
+		}
+		err := item.iterate(ctx, func(title string) error {
+			return r.runItem(t, ctx, item, title)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) runItem(t TestingT, ctx *HttpContext, item *BasicItem, title string) error {
+	// 数据驱动时每一行单独判断
+	if ok, err := item.condition(ctx); err != nil {
+		return fmt.Errorf("%s: %w", title, err)
+	} else if !ok {
+		ctx.skip(title, item, "条件不成立: "+item.If)
+		return nil
+	}
+
+	defer func() {
+		if err := r.runHooks(t, ctx, HookAfterEach); err != nil {
+			t.Errorf("%s: %s", HookAfterEach, err.Error())
+		}
+	}()
+	if err := r.runHooks(t, ctx, HookBeforeEach); err != nil {
+		return err
+	}
+	return r.step(t, ctx, item, title, r.parser)
+}
+
+// after_*钩子不会中止, 保证全部执行
+func (r *Runner) runHooks(t TestingT, ctx *HttpContext, name string) error {
+	parser := r.parser && (name == HookBeforeAll || name == HookBeforeEach)
+	for _, item := range r.hooks[name] {
+		if item.Skip {
+			continue
+		}
+		err := item.iterate(ctx, func(title string) error {
+			if ok, err := item.condition(ctx); err != nil || !ok {
+				return err
+			}
+			return r.step(t, ctx, item, title, parser)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) step(t TestingT, ctx *HttpContext, item *BasicItem, title string, parser bool) error {
+	if item.Url == "" {
+		env := ctx.traceEnvBefore()
+		defer ctx.traceEnvChanges(title, env)
+		if parser {
+			if !ParserHandleEvent(ctx, item.Event) {
+				panic(title + "执行失败")
+			}
+		} else if !HandleEvent(ctx, item.Event) {
+			t.Errorf("%s: event执行失败", title)
+		}
+		return nil
+	}
+
+	opt, err := item.option(ctx)
+	if err != nil {
+		return err
+	}
+	if item.Snapshot {
+		opt.Snapshot = item.snapshot(title)
+	}
+	if parser {
+		ctx.DoParser(t, title, opt)
+	} else {
+		ctx.Do(t, title, opt)
+	}
+	return nil
+}
+
+// 返回跳过的原因, 执行时为空
+func (r *Runner) skipReason(item *BasicItem) string {
+	switch {
+	case item.Skip:
+		return "skip"
+	case r.skipNames[item.Name]:
+		return "被指定跳过"
+	case len(r.onlyNames) > 0 && !r.onlyNames[item.Name]:
+		return "未被指定执行"
+	case r.hasOnly && !item.Only:
+		return "其他item设置了only"
+	}
+	return ""
+}
+
+// 根据当前的环境变量计算if表达式, 没有if时成立
+func (item *BasicItem) condition(ctx *HttpContext) (bool, error) {
+	if strings.TrimSpace(item.If) == "" {
+		return true, nil
+	}
+	val, err := internal.DoCaller(NewIHTTPCtx(ctx), item.If)
+	if err != nil {
+		return false, fmt.Errorf("if表达式%s: %w", item.If, err)
+	}
+	return internal.Truthy(val), nil
+}
+
+// 记录跳过的item
+func (c *HttpContext) skip(title string, item *BasicItem, reason string) {
+	c.results = append(c.results, &StepResult{
+		Title:      title,
+		Method:     strings.ToUpper(item.Method),
+		Url:        c.Render(item.Url),
+		Skipped:    true,
+		SkipReason: reason,
+		Tags:       item.Tags,
+	})
+}
@@ -0,0 +1,163 @@
+package httptest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOrderServer(calls *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := r.Method + " " + r.URL.Path
+		if step := r.URL.Query().Get("step"); step != "" {
+			call += " " + step
+		}
+		*calls = append(*calls, call)
+
+		if r.URL.Path == "/api/orders" && r.Header.Get("Authorization") != "bearer 123" {
+			w.WriteHeader(401)
+			return
+		}
+		if r.Method == "DELETE" && r.URL.Query().Get("prefix") != "test" {
+			w.WriteHeader(400)
+			return
+		}
+		body, _ := json.Marshal(map[string]interface{}{"token": "123"})
+		w.Write(body)
+	}))
+}
+
+func TestRunnerHooks(t *testing.T) {
+	calls := []string{}
+	ts := newOrderServer(&calls)
+	defer ts.Close()
+
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/hook/order.yaml", nil)
+	require.Nil(t, err)
+
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	teardown := 0
+	// 失败的断言不影响当前测试
+	err = specInfo.Runner().OnTeardown(func(c *HttpContext) error {
+		teardown++
+		return nil
+	}).Run(&testing.T{}, ctx)
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"POST /api/login",
+		"GET /api/log before", "POST /api/orders", "GET /api/log after",
+		"GET /api/log before", "GET /api/orders", "GET /api/log after",
+		"GET /api/log before", "GET /api/orders", "GET /api/log after",
+		"DELETE /api/orders",
+	}, calls)
+	assert.Equal(t, 1, teardown)
+	results := ctx.Results()
+	assert.Equal(t, "清理订单", results[len(results)-1].Title)
+	assert.Equal(t, 200, results[len(results)-1].Status)
+}
+
+func TestRunnerHooksAbort(t *testing.T) {
+	calls := []string{}
+	ts := newOrderServer(&calls)
+	defer ts.Close()
+
+	// parser版失败时中止, after_each、after_all仍然执行
+	specInfo, err := NewBasicParserSpecInfoFromFile("./testdata/hook/order.yaml", nil)
+	require.Nil(t, err)
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	teardown := 0
+	func() {
+		defer func() {
+			assert.NotNil(t, recover())
+		}()
+		specInfo.Runner().OnTeardown(func(c *HttpContext) error {
+			teardown++
+			return nil
+		}).Run(t, ctx)
+	}()
+	assert.Equal(t, []string{
+		"POST /api/login",
+		"GET /api/log before", "POST /api/orders", "GET /api/log after",
+		"GET /api/log before", "GET /api/orders", "GET /api/log after",
+		"DELETE /api/orders",
+	}, calls)
+	assert.Equal(t, 1, teardown)
+
+	// setup失败时不执行item
+	calls = calls[:0]
+	err = specInfo.Runner().OnSetup(func(c *HttpContext) error {
+		return errors.New("数据库连接失败")
+	}).Run(t, ctx)
+	assert.EqualError(t, err, "setup: 数据库连接失败")
+	assert.Equal(t, []string{"DELETE /api/orders"}, calls)
+
+	unknown, err := NewBasicSpecInfo([]byte(`[{"name": "a", "url": "http://127.0.0.1", "hook": "before"}]`), nil)
+	require.Nil(t, err)
+	assert.EqualError(t, unknown.StartHandle(t), "未知的钩子before")
+}
+
+func TestRunnerSkip(t *testing.T) {
+	calls := []string{}
+	ts := newOrderServer(&calls)
+	defer ts.Close()
+
+	specInfo, err := NewBasicSpecInfo([]byte(`
+- name: 登录
+  url: "{{baseUrl}}/api/login"
+  method: post
+- name: 新版下单
+  url: "{{baseUrl}}/api/v2/orders"
+  if: $env.featureX == true
+- name: 旧版下单
+  url: "{{baseUrl}}/api/v1/orders"
+  if: "!$env.featureX"
+- name: 待修复
+  url: "{{baseUrl}}/api/broken"
+  skip: true
+- name: 按行判断
+  url: "{{baseUrl}}/api/rows/{{ row.id }}"
+  if: $row.enabled == true
+  data: [{"id": 1, "enabled": true}, {"id": 2, "enabled": false}]
+`), nil)
+	require.Nil(t, err)
+
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	ctx.Setenv("featureX", true)
+	require.Nil(t, specInfo.StartHandleWithContext(t, ctx))
+	assert.Equal(t, []string{"POST /api/login", "GET /api/v2/orders", "GET /api/rows/1"}, calls)
+
+	skipped := map[string]string{}
+	for _, result := range ctx.Results() {
+		if result.Skipped {
+			skipped[result.Title] = result.SkipReason
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"旧版下单":    "条件不成立: !$env.featureX",
+		"待修复":     "skip",
+		"按行判断 #2": "条件不成立: $row.enabled == true",
+	}, skipped)
+
+	// Only、Skip按照名称选择
+	calls = calls[:0]
+	ctx = NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, specInfo.Runner().Only("登录", "新版下单", "旧版下单").Skip("新版下单").Run(t, ctx))
+	assert.Equal(t, []string{"POST /api/login", "GET /api/v1/orders"}, calls)
+	assert.Len(t, ctx.Results(), 5)
+
+	// 设置了only时只执行这些item
+	calls = calls[:0]
+	(*specInfo)[2].Only = true
+	require.Nil(t, specInfo.StartHandleWithContext(t, ctx))
+	assert.Equal(t, []string{"GET /api/v1/orders"}, calls)
+}
@@ -0,0 +1,285 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+////////////////////
+// 响应快照
+// 1、第一次执行时将状态码、响应头以及响应体保存在__snapshots__目录中, 之后的执行与其比较
+// 2、忽略变化的字段: $.data.createdAt、$..id、$.items[*].time, 忽略的字段保存为<ignored>
+// 3、HttpContext.WithUpdateSnapshots(etcli --update-snapshots)时使用当前响应更新快照
+////////////////////
+
+const (
+	snapshotDir     = "__snapshots__"
+	snapshotIgnored = "<ignored>"
+)
+
+type Snapshot struct {
+	Path    string   // 快照文件
+	Ignore  []string // 忽略的字段, 相对于响应体
+	Headers []string // 保存的响应头, 为空时只保存Content-Type
+}
+
+// 快照文件的内容
+type snapshotData struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body"`
+}
+
+// item的快照文件: spec所在目录/__snapshots__/标题.json
+func (item *BasicItem) snapshot(title string) *Snapshot {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, title)
+	return &Snapshot{
+		Path:    filepath.Join(item.dir, snapshotDir, name+".json"),
+		Ignore:  item.SnapshotIgnore,
+		Headers: item.SnapshotHeaders,
+	}
+}
+
+// 设置后快照与响应不同时使用当前响应更新快照
+func (c *HttpContext) WithUpdateSnapshots() *HttpContext {
+	c.updateSnapshots = true
+	return c
+}
+
+// 比较当前响应与快照, 快照不存在时保存当前响应
+func (c *HttpContext) MatchSnapshot(snapshot *Snapshot) error {
+	if c.response == nil {
+		return errors.New("没有响应")
+	}
+	actual, err := c.currentSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(snapshot.Path)
+	if os.IsNotExist(err) || (err == nil && c.updateSnapshots) {
+		return writeSnapshot(snapshot.Path, actual)
+	}
+	if err != nil {
+		return err
+	}
+	var expected snapshotData
+	if err := decodeJSON(data, &expected); err != nil {
+		return fmt.Errorf("快照%s: %w", snapshot.Path, err)
+	}
+	// 快照保存之后新增的忽略规则同样生效
+	if expected.Body, err = ignoreJSONPaths(expected.Body, snapshot.Ignore); err != nil {
+		return err
+	}
+
+	diff := []diffEntry{}
+	if expected.Status != actual.Status {
+		diff = append(diff, diffEntry{kind: diffChanged, path: "status", expected: expected.Status, actual: actual.Status})
+	}
+	diff = append(diff, jsonDiff("header", toJSONValue(expected.Headers), toJSONValue(actual.Headers))...)
+	diff = append(diff, jsonDiff("$", expected.Body, actual.Body)...)
+	if len(diff) > 0 {
+		return fmt.Errorf("与快照%s不一致:\n%s", snapshot.Path, formatDiff(diff))
+	}
+	return nil
+}
+
+func (c *HttpContext) currentSnapshot(snapshot *Snapshot) (*snapshotData, error) {
+	res := &snapshotData{Status: c.responseStatus, Headers: map[string]string{}}
+	headers := snapshot.Headers
+	if len(headers) == 0 {
+		headers = []string{"Content-Type"}
+	}
+	for _, name := range headers {
+		if value := c.response.Header.Get(name); value != "" {
+			res.Headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = value
+		}
+	}
+
+	// 非json的响应体保存为字符串
+	var body interface{} = c.responseData
+	if err := decodeJSON([]byte(c.responseData), &body); err != nil {
+		body = c.responseData
+	}
+	body, err := ignoreJSONPaths(body, snapshot.Ignore)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = body
+	return res, nil
+}
+
+func writeSnapshot(path string, data *snapshotData) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// 数字解析为json.Number, 保持原样写入快照
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("json之后存在多余的内容")
+	}
+	return nil
+}
+
+func toJSONValue(headers map[string]string) map[string]interface{} {
+	res := make(map[string]interface{}, len(headers))
+	for key, value := range headers {
+		res[key] = value
+	}
+	return res
+}
+
+// jsonpath中的一段: .name、[0]、[*]、..name
+type jsonPathSegment struct {
+	key       string
+	index     int // 数组下标, 不是下标时为-1
+	wildcard  bool
+	recursive bool
+}
+
+func (s jsonPathSegment) matchKey(key string) bool {
+	return s.wildcard || (s.index < 0 && s.key == key)
+}
+
+func (s jsonPathSegment) matchIndex(index int) bool {
+	return s.wildcard || s.index == index
+}
+
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("jsonpath%q需要以$开头", path)
+	}
+	res := []jsonPathSegment{}
+	rest := path[1:]
+	for rest != "" {
+		seg := jsonPathSegment{index: -1}
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			seg.recursive = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath%q缺少]", path)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if index, err := strconv.Atoi(inner); err == nil {
+				seg.index = index
+			} else if inner == "*" {
+				seg.wildcard = true
+			} else if unquoted, err := strconv.Unquote(strings.Replace(inner, "'", `"`, -1)); err == nil {
+				seg.key = unquoted
+			} else {
+				return nil, fmt.Errorf("jsonpath%q: 不支持的[%s]", path, inner)
+			}
+			res = append(res, seg)
+			continue
+		default:
+			return nil, fmt.Errorf("jsonpath%q: 非预期的%s", path, rest)
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		seg.key, rest = rest[:end], rest[end:]
+		if seg.key == "" {
+			return nil, fmt.Errorf("jsonpath%q: 缺少字段名", path)
+		}
+		seg.wildcard = seg.key == "*"
+		res = append(res, seg)
+	}
+	return res, nil
+}
+
+// 将匹配的字段替换为<ignored>, 修改value本身
+func ignoreJSONPaths(value interface{}, paths []string) (interface{}, error) {
+	for _, path := range paths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(segments) == 0 {
+			return snapshotIgnored, nil
+		}
+		ignoreJSONPath(value, segments)
+	}
+	return value, nil
+}
+
+func ignoreJSONPath(value interface{}, segments []jsonPathSegment) {
+	seg, rest := segments[0], segments[1:]
+	if seg.recursive {
+		// 当前层级按照非递归匹配, 再对每个子节点递归匹配
+		current := seg
+		current.recursive = false
+		ignoreJSONPath(value, append([]jsonPathSegment{current}, rest...))
+		switch value := value.(type) {
+		case map[string]interface{}:
+			for _, child := range value {
+				ignoreJSONPath(child, segments)
+			}
+		case []interface{}:
+			for _, child := range value {
+				ignoreJSONPath(child, segments)
+			}
+		}
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if !seg.matchKey(key) {
+				continue
+			}
+			if len(rest) == 0 {
+				value[key] = snapshotIgnored
+			} else {
+				ignoreJSONPath(child, rest)
+			}
+		}
+	case []interface{}:
+		for i, child := range value {
+			if !seg.matchIndex(i) {
+				continue
+			}
+			if len(rest) == 0 {
+				value[i] = snapshotIgnored
+			} else {
+				ignoreJSONPath(child, rest)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package httptest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreJSONPaths(t *testing.T) {
+	var body interface{}
+	require.Nil(t, decodeJSON([]byte(`{
+		"id": 1,
+		"data": {"createdAt": "2022", "name": "a", "items": [{"id": 2, "time": 1}, {"id": 3, "time": 2}]},
+		"a b": {"id": 4}
+	}`), &body))
+
+	body, err := ignoreJSONPaths(body, []string{"$.data.createdAt", "$..id", "$.data.items[*].time", "$['a b']"})
+	require.Nil(t, err)
+	assert.Equal(t, `{"a b":"<ignored>","data":{"createdAt":"<ignored>","items":[{"id":"<ignored>","time":"<ignored>"},{"id":"<ignored>","time":"<ignored>"}],"name":"a"},"id":"<ignored>"}`, compactJSON(body))
+
+	for _, path := range []string{"data.id", "$.", "$[a", "$[a]"} {
+		_, err := ignoreJSONPaths(body, []string{path})
+		assert.NotNil(t, err, path)
+	}
+}
+
+func TestJSONDiff(t *testing.T) {
+	var expected, actual interface{}
+	require.Nil(t, decodeJSON([]byte(`{"name": "a", "count": 1, "items": [1, 2], "old": true}`), &expected))
+	require.Nil(t, decodeJSON([]byte(`{"name": "b", "count": 1.0, "items": [1], "new-key": null}`), &actual))
+
+	diff := []string{}
+	for _, entry := range jsonDiff("$", expected, actual) {
+		diff = append(diff, entry.String())
+	}
+	assert.Equal(t, []string{
+		`- $.items[1]: 2`,
+		`~ $.name: "a" -> "b"`,
+		`+ $.new-key: null`,
+		`- $.old: true`,
+	}, diff)
+}
+
+func TestMatchSnapshot(t *testing.T) {
+	name := "a"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Version", "1")
+		body, _ := json.Marshal(map[string]interface{}{
+			"data": map[string]interface{}{"id": time.Now().UnixNano(), "name": name, "createdAt": time.Now().String()},
+		})
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	item := &BasicItem{
+		Name:            "订单/详情",
+		Url:             ts.URL,
+		Method:          "get",
+		Snapshot:        true,
+		SnapshotIgnore:  []string{"$..id", "$.data.createdAt"},
+		SnapshotHeaders: []string{"content-type", "X-Version"},
+		dir:             dir,
+	}
+	run := func(ctx *HttpContext) *StepResult {
+		require.Nil(t, newRunner([]*BasicItem{item}, false).Run(&testing.T{}, ctx))
+		return ctx.Results()[0]
+	}
+
+	// 第一次执行时保存快照
+	assert.False(t, run(NewHttpContext()).Failed)
+	path := filepath.Join(dir, snapshotDir, "订单_详情.json")
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"X-Version": "1"`)
+	assert.Contains(t, string(data), `"id": "<ignored>"`)
+	assert.False(t, run(NewHttpContext()).Failed)
+
+	name = "b"
+	ctx := NewHttpContext()
+	assert.True(t, run(ctx).Failed)
+	err = ctx.MatchSnapshot(item.snapshot(item.Name))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), `~ $.data.name: "a" -> "b"`)
+
+	// 更新快照之后通过
+	assert.False(t, run(NewHttpContext().WithUpdateSnapshots()).Failed)
+	assert.False(t, run(NewHttpContext()).Failed)
+}
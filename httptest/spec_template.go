@@ -0,0 +1,267 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+////////////////////
+// spec的复用
+// 1、include: 引入其他spec文件, 其中的item在当前文件的item之前执行, 模板、钩子可以在当前文件中使用
+// 2、templates: 命名的item片段, item通过extends继承, 模板也可以继承其他模板
+// 3、before_all、after_all、before_each、after_each: 钩子, 转为设置了hook的item
+////////////////////
+
+// spec文件的对象形式
+type specDocument struct {
+	Include   []string              `json:"include,omitempty"`
+	Templates map[string]*BasicItem `json:"templates,omitempty"`
+	Data      *SpecData             `json:"data,omitempty"` // 当前文件中没有设置data的item都使用该数据
+	Tags      []string              `json:"tags,omitempty"` // 当前文件中所有item的标签
+	Items     []*BasicItem          `json:"items"`
+
+	BeforeAll  specHooks `json:"before_all,omitempty"`
+	AfterAll   specHooks `json:"after_all,omitempty"`
+	BeforeEach specHooks `json:"before_each,omitempty"`
+	AfterEach  specHooks `json:"after_each,omitempty"`
+}
+
+// 钩子中的每一项为请求, 或者只执行event的表达式
+type specHooks []*BasicItem
+
+func (h *specHooks) UnmarshalJSON(data []byte) error {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+	for _, raw := range raws {
+		var event string
+		if err := json.Unmarshal(raw, &event); err == nil {
+			*h = append(*h, &BasicItem{Name: event, Event: []string{event}})
+			continue
+		}
+		var item BasicItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return err
+		}
+		*h = append(*h, &item)
+	}
+	return nil
+}
+
+type specLoader struct {
+	templates map[string]*BasicItem
+	loading   map[string]bool // 正在加载的文件, 用于发现循环引用
+}
+
+// 解析spec并展开include、extends, dir为spec文件所在目录
+func loadSpec(data []byte, ext string, dir string) ([]*BasicItem, error) {
+	l := &specLoader{templates: map[string]*BasicItem{}, loading: map[string]bool{}}
+	items, err := l.load(data, ext, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if err := l.resolve(item, []string{}); err != nil {
+			return nil, fmt.Errorf("%s: %w", item.Name, err)
+		}
+	}
+	return items, nil
+}
+
+func (l *specLoader) load(data []byte, ext string, dir string) ([]*BasicItem, error) {
+	doc, err := unmarshalSpec(data, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []*BasicItem{}
+	for _, include := range doc.Include {
+		path := include
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		included, err := l.include(path)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, included...)
+	}
+
+	// 当前文件中的模板覆盖引入文件中的同名模板
+	for name, template := range doc.Templates {
+		if template == nil {
+			continue
+		}
+		template.dir = dir
+		l.templates[name] = template
+	}
+	hooks := []struct {
+		name  string
+		items specHooks
+	}{
+		{HookBeforeAll, doc.BeforeAll},
+		{HookAfterAll, doc.AfterAll},
+		{HookBeforeEach, doc.BeforeEach},
+		{HookAfterEach, doc.AfterEach},
+	}
+	for _, hook := range hooks {
+		for _, item := range hook.items {
+			item.dir = dir
+			item.Hook = hook.name
+			items = append(items, item)
+		}
+	}
+	for _, item := range doc.Items {
+		item.dir = dir
+		if !item.looping() {
+			item.Data = doc.Data
+		}
+		item.Tags = mergeUnique(doc.Tags, item.Tags)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (l *specLoader) include(path string) ([]*BasicItem, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	if l.loading[key] {
+		return nil, fmt.Errorf("循环引用%s", path)
+	}
+	l.loading[key] = true
+	defer delete(l.loading, key)
+
+	data, dir, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	items, err := l.load(data, filepath.Ext(path), dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return items, nil
+}
+
+// 展开item的extends, 模板展开后清空extends, 再次展开时直接使用, stack用于发现循环继承
+func (l *specLoader) resolve(item *BasicItem, stack []string) error {
+	if item.Extends == "" {
+		return nil
+	}
+	name := item.Extends
+	for _, seen := range stack {
+		if seen == name {
+			return fmt.Errorf("循环继承%s", strings.Join(append(stack, name), " -> "))
+		}
+	}
+
+	base, ok := l.templates[name]
+	if !ok {
+		return fmt.Errorf("模板%s不存在", name)
+	}
+	if err := l.resolve(base, append(stack, name)); err != nil {
+		return err
+	}
+	item.inherit(base)
+	return nil
+}
+
+// 继承模板: 未设置的字段使用模板的值, 以/或者?开头的url拼接在模板url之后,
+// header按照名称覆盖, expect、event追加在模板之后
+func (item *BasicItem) inherit(base *BasicItem) {
+	switch {
+	case item.Url == "":
+		item.Url = base.Url
+	case base.Url != "" && (strings.HasPrefix(item.Url, "/") || strings.HasPrefix(item.Url, "?")):
+		item.Url = strings.TrimSuffix(base.Url, "/") + item.Url
+	}
+	if item.Method == "" {
+		item.Method = base.Method
+	}
+	// 请求体相关的字段作为一个整体继承, 避免模板的body-mode与item的body混用
+	if item.Body == "" && item.BodyMode == "" && item.Form == nil && item.File == "" {
+		item.Body, item.BodyMode, item.File = base.Body, base.BodyMode, base.File
+		if len(base.Form) > 0 {
+			item.Form = append([]*FormField{}, base.Form...)
+		}
+		// 上传的文件相对于模板所在的目录
+		item.dir = base.dir
+	}
+	if item.ContentType == "" {
+		item.ContentType = base.ContentType
+	}
+
+	names := map[string]bool{}
+	for _, header := range item.Header {
+		names[strings.ToLower(strings.TrimSpace(strings.SplitN(header, ":", 2)[0]))] = true
+	}
+	header := []string{}
+	for _, item := range base.Header {
+		if !names[strings.ToLower(strings.TrimSpace(strings.SplitN(item, ":", 2)[0]))] {
+			header = append(header, item)
+		}
+	}
+	if header = append(header, item.Header...); len(header) > 0 {
+		item.Header = header
+	}
+
+	if len(base.Expect) > 0 {
+		item.Expect = append(append([]string{}, base.Expect...), item.Expect...)
+	}
+	if len(base.Event) > 0 {
+		item.Event = append(append([]string{}, base.Event...), item.Event...)
+	}
+	if item.Schema == nil {
+		item.Schema = base.Schema
+	}
+	item.Insecure = item.Insecure || base.Insecure
+	item.Tags = mergeUnique(base.Tags, item.Tags)
+	item.Skip = item.Skip || base.Skip
+	item.Snapshot = item.Snapshot || base.Snapshot
+	item.SnapshotIgnore = mergeUnique(base.SnapshotIgnore, item.SnapshotIgnore)
+	if item.SnapshotHeaders == nil {
+		item.SnapshotHeaders = base.SnapshotHeaders
+	}
+	if item.If == "" {
+		item.If = base.If
+	}
+	if item.Example == nil {
+		item.Example = base.Example
+	}
+	// data、foreach、repeat、while作为一个整体继承
+	if !item.looping() {
+		item.Foreach, item.Repeat, item.While = base.Foreach, base.Repeat, base.While
+		if base.Data != nil {
+			// 数据文件相对于模板所在的目录
+			data := *base.Data
+			if data.File != "" && !filepath.IsAbs(data.File) {
+				if path, err := filepath.Abs(filepath.Join(base.dir, data.File)); err == nil {
+					data.File = path
+				}
+			}
+			item.Data = &data
+		}
+	}
+	item.Extends = ""
+}
+
+// 合并标签、快照的忽略规则等, 去掉重复
+func mergeUnique(base []string, tags []string) []string {
+	if len(base) == 0 {
+		return tags
+	}
+	res := []string{}
+	seen := map[string]bool{}
+	for _, tag := range append(append([]string{}, base...), tags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			res = append(res, tag)
+		}
+	}
+	return res
+}
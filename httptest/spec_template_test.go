@@ -0,0 +1,71 @@
+package httptest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecTemplate(t *testing.T) {
+	specInfo, err := NewBasicSpecInfoFromFile("./testdata/template/user.yaml", nil)
+	require.Nil(t, err)
+	require.Len(t, *specInfo, 3)
+
+	login, userinfo, profile := (*specInfo)[0], (*specInfo)[1], (*specInfo)[2]
+	assert.Equal(t, "用户登录", login.Name)
+	assert.Equal(t, "{{baseUrl}}/api/user/login", login.Url)
+	assert.Equal(t, "post", login.Method)
+	assert.Equal(t, []string{"$res.$status == 200"}, login.Expect)
+	assert.Equal(t, []string{"$env.token = $res.$body.$json.accessToken"}, login.Event)
+
+	assert.Equal(t, "{{baseUrl}}/api/user/userinfo", userinfo.Url)
+	assert.Equal(t, "post", userinfo.Method)
+	assert.Equal(t, "application/json", userinfo.ContentType)
+	assert.Equal(t, []string{"Authorization: bearer {{token}}", "X-Client: easytest"}, userinfo.Header)
+	assert.Equal(t, []string{"$res.$status == 200", `@contain($res.$body.$str, "ok")`}, userinfo.Expect)
+	assert.Empty(t, userinfo.Extends)
+
+	assert.Equal(t, "put", profile.Method)
+	assert.Equal(t, []string{"Authorization: bearer {{token}}", "X-Client: etcli"}, profile.Header)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/user/login" && r.Header.Get("Authorization") != "bearer 132" {
+			w.WriteHeader(401)
+			return
+		}
+		body, _ := json.Marshal(map[string]interface{}{
+			"msg":         "ok",
+			"accessToken": "132",
+		})
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, specInfo.StartHandleWithContext(t, ctx))
+	require.Len(t, ctx.Results(), 3)
+}
+
+func TestSpecTemplateError(t *testing.T) {
+	for _, content := range []string{
+		`{"items": [{"name": "a", "extends": "missing"}]}`,
+		`{"templates": {"a": {"extends": "b"}, "b": {"extends": "a"}}, "items": [{"name": "a", "extends": "a"}]}`,
+		`{"include": ["./testdata/template/missing.yaml"], "items": []}`,
+	} {
+		_, err := NewBasicSpecInfo([]byte(content), nil)
+		assert.NotNil(t, err, content)
+	}
+
+	// 循环引用
+	path := filepath.Join(t.TempDir(), "loop.yaml")
+	require.Nil(t, ioutil.WriteFile(path, []byte("include: [loop.yaml]\nitems: []\n"), 0644))
+	_, err := NewBasicSpecInfoFromFile(path, nil)
+	assert.Contains(t, err.Error(), "循环引用")
+}
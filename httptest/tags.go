@@ -0,0 +1,196 @@
+package httptest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+////////////////////
+// 标签
+// 1、标签表达式: smoke && !slow、(auth || user) && !wip
+// 2、执行结果按照标签分组统计
+////////////////////
+
+// 编译后的标签表达式
+type tagMatcher func(tags map[string]bool) bool
+
+type tagParser struct {
+	tokens []string
+	pos    int
+}
+
+func compileTagExpr(expr string) (tagMatcher, error) {
+	tokens, err := tagTokens(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return func(map[string]bool) bool { return true }, nil
+	}
+
+	p := &tagParser{tokens: tokens}
+	matcher, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("标签表达式%q: %w", expr, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("标签表达式%q: 非预期的%s", expr, p.tokens[p.pos])
+	}
+	return matcher, nil
+}
+
+func tagTokens(expr string) ([]string, error) {
+	tokens := []string{}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')' || c == '!':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(runes) || runes[i+1] != c {
+				return nil, fmt.Errorf("标签表达式%q: %c需要写作%c%c", expr, c, c, c)
+			}
+			tokens = append(tokens, string([]rune{c, c}))
+			i += 2
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()!&|", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		}
+	}
+	return tokens, nil
+}
+
+func (p *tagParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *tagParser) or() (tagMatcher, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tags map[string]bool) bool { return l(tags) || right(tags) }
+	}
+	return left, nil
+}
+
+func (p *tagParser) and() (tagMatcher, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(tags map[string]bool) bool { return l(tags) && right(tags) }
+	}
+	return left, nil
+}
+
+func (p *tagParser) unary() (tagMatcher, error) {
+	token := p.peek()
+	p.pos++
+	switch token {
+	case "":
+		return nil, fmt.Errorf("表达式不完整")
+	case "!":
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(tags map[string]bool) bool { return !operand(tags) }, nil
+	case "(":
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("缺少)")
+		}
+		p.pos++
+		return inner, nil
+	case ")", "&&", "||":
+		return nil, fmt.Errorf("非预期的%s", token)
+	}
+	return func(tags map[string]bool) bool { return tags[token] }, nil
+}
+
+func (m tagMatcher) match(tags []string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return m(set)
+}
+
+// 一个标签的执行结果统计, 没有标签的结果归入空标签
+type TagSummary struct {
+	Tag     string `json:"tag"`
+	Total   int    `json:"total"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+}
+
+// 按照标签分组统计, 一个结果有多个标签时计入每个标签
+func SummarizeByTag(results []*StepResult) []*TagSummary {
+	index := map[string]*TagSummary{}
+	for _, result := range results {
+		tags := result.Tags
+		if len(tags) == 0 {
+			tags = []string{""}
+		}
+		for _, tag := range tags {
+			summary, ok := index[tag]
+			if !ok {
+				summary = &TagSummary{Tag: tag}
+				index[tag] = summary
+			}
+			summary.Total++
+			switch {
+			case result.Skipped:
+				summary.Skipped++
+			case result.Failed:
+				summary.Failed++
+			default:
+				summary.Passed++
+			}
+		}
+	}
+
+	res := make([]*TagSummary, 0, len(index))
+	for _, summary := range index {
+		res = append(res, summary)
+	}
+	// 没有标签的放在最后
+	sort.Slice(res, func(i, j int) bool {
+		if (res[i].Tag == "") != (res[j].Tag == "") {
+			return res[j].Tag == ""
+		}
+		return res[i].Tag < res[j].Tag
+	})
+	return res
+}
@@ -0,0 +1,86 @@
+package httptest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileTagExpr(t *testing.T) {
+	var pairs = []struct {
+		expr   string
+		tags   []string
+		target bool
+	}{
+		{"", nil, true},
+		{"smoke", []string{"smoke", "auth"}, true},
+		{"smoke && !slow", []string{"smoke", "slow"}, false},
+		{"smoke && !slow", []string{"smoke"}, true},
+		{"auth || user", []string{"user"}, true},
+		{"(auth || user) && !wip", []string{"auth", "wip"}, false},
+		{"!(auth || user)", []string{"order"}, true},
+		{"smoke || auth && slow", []string{"smoke"}, true},
+		{"team:pay && p0", []string{"team:pay", "p0"}, true},
+	}
+	for _, item := range pairs {
+		matcher, err := compileTagExpr(item.expr)
+		require.Nil(t, err, item.expr)
+		assert.Equal(t, item.target, matcher.match(item.tags), item.expr)
+	}
+
+	for _, expr := range []string{"smoke &", "smoke &&", "(smoke", "smoke)", "&& smoke", "smoke slow"} {
+		_, err := compileTagExpr(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestRunnerFilterTags(t *testing.T) {
+	calls := []string{}
+	ts := newOrderServer(&calls)
+	defer ts.Close()
+
+	specInfo, err := NewBasicSpecInfo([]byte(`
+tags: [order]
+templates:
+  slow:
+    tags: [slow]
+items:
+  - name: 登录
+    url: "{{baseUrl}}/api/login"
+    method: post
+    tags: [smoke, auth]
+  - name: 订单列表
+    url: "{{baseUrl}}/api/orders"
+    tags: [smoke]
+  - name: 导出订单
+    extends: slow
+    url: "{{baseUrl}}/api/orders/export"
+    tags: [smoke]
+    expect:
+      - $res.$status == 201
+  - name: 未完成
+    url: "{{baseUrl}}/api/wip"
+    skip: true
+`), nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"slow", "order", "smoke"}, (*specInfo)[2].Tags)
+
+	ctx := NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, specInfo.Runner().FilterTags("smoke && !slow").Run(&testing.T{}, ctx))
+	assert.Equal(t, []string{"POST /api/login", "GET /api/orders"}, calls)
+
+	ctx = NewHttpContext()
+	ctx.Setenv("baseUrl", ts.URL)
+	require.Nil(t, specInfo.Runner().FilterTags("order").Run(&testing.T{}, ctx))
+	summary := SummarizeByTag(ctx.Results())
+	assert.Equal(t, []*TagSummary{
+		{Tag: "auth", Total: 1, Passed: 1},
+		{Tag: "order", Total: 4, Passed: 2, Failed: 1, Skipped: 1},
+		{Tag: "slow", Total: 1, Failed: 1},
+		{Tag: "smoke", Total: 3, Passed: 2, Failed: 1},
+	}, summary)
+
+	assert.NotNil(t, specInfo.Runner().FilterTags("smoke &&").Run(t, NewHttpContext()))
+}
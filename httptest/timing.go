@@ -0,0 +1,147 @@
+package httptest
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+////////////////////
+// 请求耗时, 通过net/http/httptrace记录
+// 1、dns、connect、tls: 建立连接的各个阶段, 复用连接时为0
+// 2、ttfb: 从发送请求到收到响应的第一个字节, total: 到读取完响应体
+// 3、表达式中通过$res.$timing.total读取(毫秒), 执行记录中包含每次请求的耗时
+////////////////////
+
+type Timing struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+	Total   time.Duration
+}
+
+type timingJSON struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	TLS     float64 `json:"tls"`
+	TTFB    float64 `json:"ttfb"`
+	Total   float64 `json:"total"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// 以毫秒输出
+func (t *Timing) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&timingJSON{
+		DNS:     milliseconds(t.DNS),
+		Connect: milliseconds(t.Connect),
+		TLS:     milliseconds(t.TLS),
+		TTFB:    milliseconds(t.TTFB),
+		Total:   milliseconds(t.Total),
+	})
+}
+
+func (t *Timing) UnmarshalJSON(data []byte) error {
+	var ms timingJSON
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return err
+	}
+	*t = Timing{
+		DNS:     fromMilliseconds(ms.DNS),
+		Connect: fromMilliseconds(ms.Connect),
+		TLS:     fromMilliseconds(ms.TLS),
+		TTFB:    fromMilliseconds(ms.TTFB),
+		Total:   fromMilliseconds(ms.Total),
+	}
+	return nil
+}
+
+// 表达式中使用的值, 单位为毫秒
+func (t *Timing) values() map[string]interface{} {
+	if t == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"dns":     milliseconds(t.DNS),
+		"connect": milliseconds(t.Connect),
+		"tls":     milliseconds(t.TLS),
+		"ttfb":    milliseconds(t.TTFB),
+		"total":   milliseconds(t.Total),
+	}
+}
+
+// 记录一次请求的耗时, httptrace的回调可能并发执行(例如同时尝试ipv4与ipv6)
+type timingRecorder struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       Timing
+}
+
+// 返回带有httptrace的请求, 调用之后开始计时
+func (r *timingRecorder) trace(req *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timing.DNS = time.Since(r.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.connectStart.IsZero() {
+				r.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if err == nil && r.timing.Connect == 0 {
+				r.timing.Connect = time.Since(r.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timing.TLS = time.Since(r.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.timing.TTFB = time.Since(r.start)
+		},
+	}
+	r.start = time.Now()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// 读取完响应体之后调用
+func (r *timingRecorder) finish() *Timing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	timing := r.timing
+	timing.Total = time.Since(r.start)
+	return &timing
+}
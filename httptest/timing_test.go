@@ -0,0 +1,47 @@
+package httptest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiming(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"msg": "ok"}`))
+	}))
+	defer ts.Close()
+
+	ctx := NewHttpContext()
+	ctx.Do(t, "慢接口", &HandleOption{
+		Url:    ts.URL,
+		Method: "GET",
+		Expect: []string{
+			`$res.$timing.ttfb >= 20`,
+			`$res.$timing.total >= $res.$timing.ttfb`,
+			`$res.$timing.total < 5000`,
+			`$res.$timing.dns == 0`,
+		},
+	})
+	result := ctx.Results()[0]
+	require.NotNil(t, result.Timing)
+	assert.False(t, result.Failed)
+	assert.True(t, result.Timing.TTFB >= 20*time.Millisecond)
+
+	ctx.Do(&testing.T{}, "超时", &HandleOption{Url: ts.URL, Method: "GET", Expect: []string{`$res.$timing.total < 1`}})
+	assert.True(t, ctx.Results()[1].Failed)
+
+	// 报告中以毫秒输出
+	data, err := json.Marshal(&StepResult{Title: "a", Timing: &Timing{TTFB: 1500 * time.Microsecond, Total: 2 * time.Millisecond}})
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"timing":{"dns":0,"connect":0,"tls":0,"ttfb":1.5,"total":2}`)
+	var decoded StepResult
+	require.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, 1500*time.Microsecond, decoded.Timing.TTFB)
+}
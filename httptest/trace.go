@@ -0,0 +1,370 @@
+package httptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/wwqdrh/easytest/httptest/internal"
+)
+
+////////////////////
+// 请求追踪, 用于排查失败的spec
+// 1、输出每次请求的请求行、状态码、event修改的环境变量以及expect的结果和比较的两边的值
+// 2、Verbose时还输出请求头、响应头以及body, body超过MaxBody时截断
+// 3、Authorization、Cookie以及名称中包含token、password、secret等的header、参数、字段输出为***
+// 4、文本格式或者每行一个json(TraceEvent)
+////////////////////
+
+const (
+	defaultTraceMaxBody = 4096
+	traceRedacted       = "***"
+)
+
+// 名称包含这些内容时认为是敏感信息
+var traceSensitiveWords = []string{"token", "password", "passwd", "secret", "api-key", "apikey", "api_key", "session"}
+
+type Tracer struct {
+	Writer  io.Writer
+	Verbose bool     // 输出请求头、响应头以及body
+	JSON    bool     // 每行输出一个json
+	MaxBody int      // body的最大字节数, 为0时使用4096
+	Redact  []string // 额外需要隐藏的header、参数、字段名称, 不区分大小写
+
+	mu sync.Mutex
+}
+
+// 追踪的一个事件, JSON格式时每行输出一个
+type TraceEvent struct {
+	Type   string            `json:"type"` // request、response、error、env、expect
+	Title  string            `json:"title,omitempty"`
+	Method string            `json:"method,omitempty"`
+	Url    string            `json:"url,omitempty"`
+	Status int               `json:"status,omitempty"`
+	Timing *Timing           `json:"timing,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body,omitempty"`
+	Key    string            `json:"key,omitempty"` // 修改的环境变量
+	Value  interface{}       `json:"value,omitempty"`
+	Expr   string            `json:"expr,omitempty"`
+	Passed *bool             `json:"passed,omitempty"`
+	Op     string            `json:"op,omitempty"` // 比较表达式的运算符以及两边的值
+	Left   interface{}       `json:"left,omitempty"`
+	Right  interface{}       `json:"right,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// 输出到w, 默认为文本格式、不输出header与body
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{Writer: w}
+}
+
+// 设置后追踪每次请求, 为nil时关闭
+func (c *HttpContext) WithTrace(tracer *Tracer) *HttpContext {
+	c.tracer = tracer
+	return c
+}
+
+func (t *Tracer) sensitive(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie":
+		return true
+	}
+	for _, word := range traceSensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	for _, item := range t.Redact {
+		if strings.EqualFold(item, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tracer) header(header http.Header) map[string]string {
+	if !t.Verbose || len(header) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(header))
+	for key, values := range header {
+		if t.sensitive(key) {
+			res[key] = traceRedacted
+		} else {
+			res[key] = strings.Join(values, ", ")
+		}
+	}
+	return res
+}
+
+// 隐藏query中的敏感参数
+func (t *Tracer) url(u *url.URL) string {
+	query := u.Query()
+	changed := false
+	for key := range query {
+		if t.sensitive(key) {
+			query.Set(key, traceRedacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	copied := *u
+	copied.RawQuery = query.Encode()
+	return copied.String()
+}
+
+// 隐藏json、表单中的敏感字段, 超过MaxBody时截断
+func (t *Tracer) body(data []byte, contentType string) string {
+	if !t.Verbose || len(data) == 0 {
+		return ""
+	}
+	if !utf8.Valid(data) {
+		return fmt.Sprintf("<二进制数据, 共%d字节>", len(data))
+	}
+
+	text := string(data)
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err == nil {
+		if t.redactValue(value) {
+			text = compactJSON(value)
+		}
+	} else if strings.Contains(contentType, "x-www-form-urlencoded") {
+		if form, err := url.ParseQuery(text); err == nil {
+			changed := false
+			for key := range form {
+				if t.sensitive(key) {
+					form.Set(key, traceRedacted)
+					changed = true
+				}
+			}
+			if changed {
+				text = form.Encode()
+			}
+		}
+	}
+
+	max := t.MaxBody
+	if max <= 0 {
+		max = defaultTraceMaxBody
+	}
+	if len(text) > max {
+		cut := max
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + fmt.Sprintf("...(共%d字节)", len(text))
+	}
+	return text
+}
+
+// 返回是否修改了value
+func (t *Tracer) redactValue(value interface{}) bool {
+	changed := false
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if t.sensitive(key) {
+				value[key] = traceRedacted
+				changed = true
+			} else if t.redactValue(child) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range value {
+			if t.redactValue(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func (t *Tracer) emit(event *TraceEvent) {
+	if t == nil || t.Writer == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.JSON {
+		data, err := json.Marshal(event)
+		if err == nil {
+			fmt.Fprintln(t.Writer, string(data))
+		}
+		return
+	}
+	fmt.Fprint(t.Writer, event.text())
+}
+
+func (e *TraceEvent) text() string {
+	var b strings.Builder
+	switch e.Type {
+	case "request":
+		fmt.Fprintf(&b, "--> %s %s [%s]\n", e.Method, e.Url, e.Title)
+	case "response":
+		fmt.Fprintf(&b, "<-- %d %s (%.0fms)\n", e.Status, http.StatusText(e.Status), milliseconds(e.Timing.Total))
+		if e.Header != nil || e.Body != "" {
+			// Verbose时输出各个阶段的耗时
+			fmt.Fprintf(&b, "    timing: dns %.0fms, connect %.0fms, tls %.0fms, ttfb %.0fms\n",
+				milliseconds(e.Timing.DNS), milliseconds(e.Timing.Connect), milliseconds(e.Timing.TLS), milliseconds(e.Timing.TTFB))
+		}
+	case "error":
+		fmt.Fprintf(&b, "<-- 请求失败: %s\n", e.Error)
+	case "env":
+		fmt.Fprintf(&b, "    env %s = %s\n", e.Key, compactJSON(e.Value))
+	case "expect":
+		mark := "✓"
+		if e.Passed != nil && !*e.Passed {
+			mark = "✗"
+		}
+		fmt.Fprintf(&b, "    expect %s %s", mark, e.Expr)
+		switch {
+		case e.Error != "":
+			fmt.Fprintf(&b, " (%s)", e.Error)
+		case e.Op != "":
+			fmt.Fprintf(&b, " (%s %s %s)", truncateText(compactJSON(e.Left)), e.Op, truncateText(compactJSON(e.Right)))
+		}
+		b.WriteString("\n")
+	}
+
+	keys := make([]string, 0, len(e.Header))
+	for key := range e.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "    %s: %s\n", key, e.Header[key])
+	}
+	if e.Body != "" {
+		b.WriteString(indentText(strings.TrimRight(e.Body, "\r\n"), "    ") + "\n")
+	}
+	return b.String()
+}
+
+func (c *HttpContext) traceRequest(title string, req *http.Request, body []byte) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.emit(&TraceEvent{
+		Type:   "request",
+		Title:  title,
+		Method: req.Method,
+		Url:    c.tracer.url(req.URL),
+		Header: c.tracer.header(req.Header),
+		Body:   c.tracer.body(body, req.Header.Get("Content-Type")),
+	})
+}
+
+func (c *HttpContext) traceResponse(title string, resp *http.Response, body []byte, timing *Timing) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.emit(&TraceEvent{
+		Type:   "response",
+		Title:  title,
+		Status: resp.StatusCode,
+		Timing: timing,
+		Header: c.tracer.header(resp.Header),
+		Body:   c.tracer.body(body, resp.Header.Get("Content-Type")),
+	})
+}
+
+func (c *HttpContext) traceError(title string, err error) {
+	if c.tracer == nil {
+		return
+	}
+	c.tracer.emit(&TraceEvent{Type: "error", Title: title, Error: err.Error()})
+}
+
+// expect的结果, 比较表达式时输出两边的值
+func (c *HttpContext) traceExpect(expect string, passed bool) {
+	if c.tracer == nil {
+		return
+	}
+	event := &TraceEvent{Type: "expect", Title: c.traceTitle(), Expr: expect, Passed: &passed}
+	cmp, err := internal.DoCompare(NewIHTTPCtx(c), expect)
+	if err != nil {
+		event.Error = err.Error()
+	} else if cmp != nil {
+		event.Op = cmp.Op
+		event.Left, event.Right = c.tracer.redactOperand(cmp.Left), c.tracer.redactOperand(cmp.Right)
+	}
+	c.tracer.emit(event)
+}
+
+func (t *Tracer) redactOperand(value interface{}) interface{} {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		// 不修改响应中的数据
+		copied, err := deepCopyJSON(value)
+		if err != nil {
+			return value
+		}
+		t.redactValue(copied)
+		return copied
+	}
+	return value
+}
+
+func deepCopyJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var res interface{}
+	err = json.Unmarshal(data, &res)
+	return res, err
+}
+
+// 执行event之前的环境变量, 没有开启追踪时为nil
+func (c *HttpContext) traceEnvBefore() map[string]interface{} {
+	if c.tracer == nil {
+		return nil
+	}
+	res := make(map[string]interface{}, len(c.enviroment))
+	for key, value := range c.enviroment {
+		res[key] = value
+	}
+	return res
+}
+
+// 输出event修改的环境变量
+func (c *HttpContext) traceEnvChanges(title string, before map[string]interface{}) {
+	if c.tracer == nil || before == nil {
+		return
+	}
+	keys := []string{}
+	for key, value := range c.enviroment {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := c.enviroment[key]
+		if c.tracer.sensitive(key) {
+			value = traceRedacted
+		}
+		c.tracer.emit(&TraceEvent{Type: "env", Title: title, Key: key, Value: value})
+	}
+}
+
+// 当前请求的标题
+func (c *HttpContext) traceTitle() string {
+	if len(c.results) == 0 {
+		return ""
+	}
+	return c.results[len(c.results)-1].Title
+}
@@ -0,0 +1,99 @@
+package httptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTraceFailNow = errors.New("fail now")
+
+type traceT struct{}
+
+func (t *traceT) Errorf(format string, args ...interface{}) {}
+func (t *traceT) FailNow()                                  { panic(errTraceFailNow) }
+func (t *traceT) Log(args ...interface{})                   {}
+
+func newTraceServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "sid=1")
+		w.Write([]byte(`{"accessToken": "abc", "user": {"name": "ving", "password": "123"}, "msg": "` + strings.Repeat("x", 100) + `"}`))
+	}))
+}
+
+func TestTraceText(t *testing.T) {
+	ts := newTraceServer()
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	ctx := NewHttpContext().WithTrace(&Tracer{Writer: &buf, Verbose: true, MaxBody: 80})
+	// 第二个expect失败
+	ctx.Do(&traceT{}, "登录", &HandleOption{
+		Url:         ts.URL + "/login?access_token=abc&page=1",
+		Method:      "POST",
+		ContentType: "application/x-www-form-urlencoded",
+		Header:      map[string]string{"Authorization": "bearer abc"},
+		Body:        strings.NewReader("name=ving&password=123"),
+		Expect:      []string{`$res.$status == 200`, `$res.$body.$json.user.name != "ving"`},
+		Event:       []string{`$env.name = $res.$body.$json.user.name`, `$env.token = $res.$body.$json.accessToken`},
+	})
+
+	out := buf.String()
+	assert.Contains(t, out, "--> POST "+ts.URL+"/login?access_token=%2A%2A%2A&page=1 [登录]\n")
+	assert.Contains(t, out, "    Authorization: ***\n")
+	assert.Contains(t, out, "    name=ving&password=%2A%2A%2A\n")
+	assert.Contains(t, out, "<-- 200 OK (")
+	assert.Contains(t, out, "    Set-Cookie: ***\n")
+	assert.Contains(t, out, `"accessToken":"***"`)
+	assert.Contains(t, out, "...(共")
+	assert.NotContains(t, out, "abc")
+	assert.Contains(t, out, "    expect ✓ $res.$status == 200 (200 == 200)\n")
+	assert.Contains(t, out, "    expect ✗ $res.$body.$json.user.name != \"ving\" (\"ving\" != \"ving\")\n")
+	assert.Contains(t, out, "    env name = \"ving\"\n    env token = \"***\"\n")
+}
+
+func TestTraceJSON(t *testing.T) {
+	ts := newTraceServer()
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	ctx := NewHttpContext().WithTrace(&Tracer{Writer: &buf, JSON: true})
+	ctx.Do(t, "查询", &HandleOption{
+		Url:    ts.URL,
+		Method: "GET",
+		Expect: []string{`$res.$status == 200`},
+	})
+	// 请求失败时FailNow中止
+	func() {
+		defer func() { assert.Equal(t, errTraceFailNow, recover()) }()
+		ctx.Do(&traceT{}, "连接失败", &HandleOption{Url: "http://127.0.0.1:1", Method: "GET"})
+	}()
+
+	events := []*TraceEvent{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		event := &TraceEvent{}
+		require.Nil(t, json.Unmarshal([]byte(line), event), line)
+		events = append(events, event)
+	}
+	require.Len(t, events, 5)
+	assert.Equal(t, "request", events[0].Type)
+	assert.Equal(t, "GET", events[0].Method)
+	// 没有开启Verbose时不输出header与body
+	assert.Nil(t, events[0].Header)
+	assert.Equal(t, "response", events[1].Type)
+	assert.Equal(t, 200, events[1].Status)
+	assert.Empty(t, events[1].Body)
+	assert.Equal(t, "expect", events[2].Type)
+	assert.True(t, *events[2].Passed)
+	assert.Equal(t, "==", events[2].Op)
+	assert.Equal(t, float64(200), events[2].Left)
+	assert.Equal(t, "error", events[4].Type)
+	assert.Equal(t, "连接失败", events[4].Title)
+}